@@ -3,12 +3,16 @@
 package main
 
 import (
-    "log"
+    "fmt"
+    "os"
+
     "gosper/internal/adapter/inbound/cli"
+    herr "gosper/pkg/errors"
 )
 
 func main() {
     if err := cli.Execute(); err != nil {
-        log.Fatalf("gosper: %v", err)
+        fmt.Fprintf(os.Stderr, "gosper: %v\n", err)
+        os.Exit(herr.ExitCode(err))
     }
 }
@@ -2,62 +2,315 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	httpAdapter "gosper/internal/adapter/inbound/http"
+	"gosper/internal/adapter/outbound/auth"
+	logadapter "gosper/internal/adapter/outbound/log"
 	"gosper/internal/adapter/outbound/model"
 	"gosper/internal/adapter/outbound/storage"
 	"gosper/internal/adapter/outbound/whispercpp"
 	"gosper/internal/config"
+	"gosper/internal/infrastructure/clock"
+	"gosper/internal/port"
 	"gosper/internal/usecase"
+	"gosper/pkg/metrics"
+	"gosper/pkg/trace"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.FromEnv()
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	// Load configuration: defaults < config file < env < -set flags
+	configPath := flag.String("config", "", "config file (default $GOSPER_CONFIG or the user config dir)")
+	overrides := map[string]string{}
+	flag.Func("set", "override a setting, key=value (repeatable)", func(kv string) error {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("want key=value, got %q", kv)
+		}
+		overrides[k] = v
+		return nil
+	})
+	flag.Parse()
+	cfg, err := config.Load(config.Options{Path: *configPath, Flags: overrides})
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	format := cfg.LogFormat
+	if format == "" {
+		format = "json"
+	}
+	logger, err := logadapter.NewSlog(os.Stdout, cfg.LogLevel, format)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
 
-	// Initialize use case with dependencies
+	// Initialize use cases with shared dependencies
+	sources, err := model.ParseSources(cfg.Models.Sources)
+	if err != nil {
+		log.Fatalf("models.sources: %v", err)
+	}
+	repo := &model.FSRepo{
+		Sources:       sources,
+		BaseURL:       cfg.Models.BaseURL,
+		CacheDir:      cfg.Models.CacheDir,
+		MaxCacheBytes: cfg.Models.CacheMaxBytes,
+		Pinned:        append([]string{cfg.Model}, cfg.Models.Pinned...), // never evict the default model
+		Progress:      logProgress(logger),
+	}
+	trans := &whispercpp.Transcriber{}
+	events := logadapter.NewSlogLogger(logger)
 	transcribeUC := &usecase.TranscribeFile{
-		Repo:  &model.FSRepo{BaseURL: cfg.ModelBaseURL},
-		Trans: &whispercpp.Transcriber{},
+		Repo:  repo,
+		Trans: trans,
 		Store: storage.FS{},
+		Log:   events,
+		Clock: clock.SystemClock{},
+	}
+	detectUC := &usecase.DetectLanguage{
+		Repo:     repo,
+		Detector: trans,
+		Log:      events,
+		Clock:    clock.SystemClock{},
+	}
+	modelsUC := &usecase.Models{
+		Catalog: repo,
+		Cache:   repo,
+		Loader:  trans,
+		Allowed: cfg.Server.AllowedModels,
+		Default: cfg.Model,
+	}
+
+	authn, err := authenticator(cfg.Server)
+	if err != nil {
+		log.Fatalf("authentication: %v", err)
+	}
+	if authn == nil {
+		logger.Warn("no API keys or JWKS configured, the API is open to anyone who can reach it")
+	}
+
+	proxies, err := httpAdapter.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Fatalf("server.trusted_proxies: %v", err)
+	}
+	rl := cfg.Server.RateLimit
+
+	// Metrics observed by the model repository and transcriber are
+	// recorded through hooks, the rest by the HTTP server itself
+	var m *httpAdapter.Metrics
+	if cfg.Server.Metrics {
+		reg := metrics.NewRegistry()
+		m = httpAdapter.NewMetrics(reg)
+		repo.CacheLookup = m.CacheLookup
+		trans.OnLoad = m.ModelLoaded
+		reg.GaugeFunc("gosper_queue_depth", "Requests waiting for a model instance.", func() float64 { return float64(trans.Waiting()) })
+	}
+	tracer := newTracer(cfg.Server.Tracing, logger)
+
+	// Uploads go to a directory of their own, cleared of any a crashed run
+	// left behind
+	tempDir := cfg.Server.TempDir
+	if tempDir == "" {
+		tempDir = filepath.Join(os.TempDir(), "gosper")
+	}
+	removed, err := httpAdapter.PrepareTempDir(tempDir)
+	if err != nil {
+		log.Fatalf("server.temp_dir: %v", err)
+	}
+	if removed > 0 {
+		logger.Info("removed leftover uploads", "dir", tempDir, "files", removed)
 	}
 
 	// Create HTTP server
 	httpServer := httpAdapter.NewServer(
 		transcribeUC,
-		logger,
+		detectUC,
+		modelsUC,
+		events,
 		httpAdapter.Config{
-			Addr:            cfg.Addr,
-			ModelDefault:    cfg.Model,
+			Addr:            cfg.Server.Addr,
 			LanguageDefault: cfg.Language,
+			Auth:            authn,
+			Usage:           &usecase.Usage{Window: cfg.Server.QuotaWindow},
+			RateLimit: &usecase.RateLimiter{
+				Requests: usecase.Limit{N: float64(rl.Requests), Window: rl.Window},
+				Audio:    usecase.Limit{N: rl.AudioSeconds, Window: rl.Window},
+			},
+			TrustedProxies: proxies,
+			CORS:           httpAdapter.CORSConfig(cfg.Server.CORS),
+			Metrics:        m,
+			Tracer:         tracer,
+
+			MaxUpload:        cfg.Server.MaxUpload,
+			MaxAudioDuration: cfg.Server.MaxAudioDuration,
+			TempDir:          tempDir,
+			AudioURL:         httpAdapter.AudioURLConfig(cfg.Server.AudioURL),
 		},
 	)
 
-	// Start HTTP server in goroutine
+	// Start HTTP server in goroutine; /livez answers at once, /readyz once
+	// the configured models are loaded
 	go func() {
 		if err := httpServer.Start(); err != nil {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 	}()
 
+	startCtx, cancelStart := context.WithCancel(context.Background())
+	defer cancelStart()
+	preloadUC := &usecase.Preload{Repo: repo, Trans: trans, Loader: trans}
+	go preload(startCtx, preloadUC, usecase.PreloadInput{Models: cfg.PreloadModels(), Warmup: cfg.Server.Warmup, Threads: uint(cfg.Threads)}, httpServer, logger)
+
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
+	cancelStart()
 
-	logger.Println("Shutting down servers...")
+	logger.Info("shutting down servers")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Fatalf("shutdown error: %v", err)
 	}
+	if tracer != nil {
+		if err := tracer.Shutdown(ctx); err != nil {
+			logger.Error("flush traces", "error", err)
+		}
+	}
+
+	if err := trans.Close(); err != nil {
+		logger.Error("free models", "error", err)
+	}
+	logger.Info("server stopped gracefully")
+}
 
-	logger.Println("Server stopped gracefully")
+// authenticator returns what verifies API credentials: the API key store
+// built from the key file and inline keys, and the JWT verifier when a
+// JWKS is configured. It returns nil when neither is configured.
+func authenticator(cfg config.ServerConfig) (port.Authenticator, error) {
+	var keys []auth.APIKey
+	if cfg.APIKeysFile != "" {
+		k, err := auth.LoadKeyFile(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k...)
+	}
+	k, err := auth.ParseKeys(strings.Join(cfg.APIKeys, ","))
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, k...)
+
+	var creds auth.Credentials
+	if len(keys) > 0 {
+		store, err := auth.NewKeyStore(keys)
+		if err != nil {
+			return nil, err
+		}
+		creds.Keys = store
+	}
+	if j := cfg.JWT; j.JWKS != "" {
+		creds.Tokens = &auth.JWTVerifier{
+			Keys:     &auth.JWKS{Source: j.JWKS, Refresh: j.Refresh},
+			Issuer:   j.Issuer,
+			Audience: j.Audience,
+			Subject:  j.SubjectClaim,
+			Leeway:   time.Minute,
+		}
+	}
+	if creds.Keys == nil && creds.Tokens == nil {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+// newTracer returns the tracer for the configured exporter, or nil when
+// tracing is off.
+func newTracer(cfg config.TracingConfig, logger *slog.Logger) *trace.Tracer {
+	var exp trace.Exporter
+	switch cfg.Exporter {
+	case "otlp":
+		exp = &trace.OTLPExporter{
+			Endpoint: cfg.Endpoint,
+			Service:  cfg.ServiceName,
+			OnError:  func(err error) { logger.Warn("export traces", "error", err) },
+		}
+	case "stdout":
+		exp = &trace.WriterExporter{W: os.Stdout}
+	default:
+		return nil
+	}
+	return &trace.Tracer{Exporter: exp, SampleRatio: cfg.SampleRatio}
+}
+
+// preload loads and warms up the configured models, then marks the server
+// ready. Failures are logged and retried with backoff, so a transient
+// download error does not take the pod down; /readyz only reports that
+// preloading is being retried.
+func preload(ctx context.Context, uc *usecase.Preload, in usecase.PreloadInput, srv *httpAdapter.Server, logger *slog.Logger) {
+	if len(in.Models) == 0 {
+		srv.SetReady(true, "")
+		return
+	}
+	srv.SetReady(false, "loading models")
+	delay := 5 * time.Second
+	for {
+		start := time.Now()
+		err := uc.Execute(ctx, in)
+		if err == nil {
+			logger.Info("models ready", "models", in.Models, "duration_ms", time.Since(start).Milliseconds())
+			srv.SetReady(true, "")
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("preload failed", "retry_in", delay.String(), "error", err)
+		srv.SetReady(false, "retrying model preload")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(2*delay, time.Minute)
+	}
+}
+
+// logProgress returns a download progress callback that logs every 10%,
+// or every 100 MiB when the size is unknown.
+func logProgress(logger *slog.Logger) func(file string, done, total int64) {
+	var mu sync.Mutex
+	last := map[string]int64{}
+	return func(file string, done, total int64) {
+		step := done / (100 << 20)
+		if total > 0 {
+			step = done * 10 / total
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if prev, ok := last[file]; ok && step == prev {
+			return
+		}
+		last[file] = step
+		if total > 0 {
+			logger.Info("downloading model", "file", file, "percent", step*10, "bytes", done, "total", total)
+		} else {
+			logger.Info("downloading model", "file", file, "bytes", done)
+		}
+		if total > 0 && done >= total {
+			delete(last, file)
+		}
+	}
 }
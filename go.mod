@@ -3,10 +3,12 @@ module gosper
 go 1.23
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/gen2brain/malgo v0.11.24
 	github.com/ggerganov/whisper.cpp/bindings/go v0.0.0-00010101000000-000000000000
 	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/spf13/cobra v1.8.1 // used under build tag 'cli'
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,191 @@
+//go:build cli
+
+package cli
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "text/tabwriter"
+
+    "github.com/spf13/cobra"
+    logadapter "gosper/internal/adapter/outbound/log"
+    "gosper/internal/config"
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+var configFlags = struct{
+    path string
+    set  []string
+}{}
+
+// appConfig is the configuration for the running command, resolved before
+// it runs; configErr holds any problem with it.
+var (
+    appConfig *config.Config
+    configErr error
+)
+
+// flagKeys maps command flags to the settings they override. A flag only
+// counts when given on the command line, so its default never hides a value
+// from the config file or the environment.
+var flagKeys = map[string]string{
+    "model":          "model",
+    "lang":           "language",
+    "threads":        "threads",
+    "device":         "audio.device",
+    "audio-feedback": "audio.feedback",
+    "output-device":  "audio.output_device",
+    "beep-volume":    "audio.beep_volume",
+    "base-url":       "models.base_url",
+    "max-size":       "models.cache_max",
+    "log-level":      "log_level",
+    "log-format":     "log_format",
+}
+
+// loadConfig resolves the configuration for cmd. Invalid configuration fails
+// every command except the config commands, which exist to inspect and fix
+// it, and version.
+func loadConfig(cmd *cobra.Command, args []string) error {
+    overrides := map[string]string{}
+    for _, kv := range configFlags.set {
+        k, v, ok := strings.Cut(kv, "=")
+        if !ok { return herr.Newf(herr.InvalidArgument, "--set %q: want key=value", kv) }
+        overrides[k] = v
+    }
+    for name, key := range flagKeys {
+        if f := cmd.Flags().Lookup(name); f != nil && f.Changed { overrides[key] = f.Value.String() }
+    }
+    appConfig, configErr = config.Load(config.Options{Path: configFlags.path, Flags: overrides})
+    if configErr == nil || cmd == versionCmd || isConfigCmd(cmd) { return nil }
+    return herr.Newf(herr.InvalidArgument, "invalid configuration:\n%w", configErr)
+}
+
+// newLogger returns the logger the use cases report to, writing to stderr so
+// it never mixes with transcripts on stdout.
+func newLogger() port.Logger {
+    format := appConfig.LogFormat
+    if format == "" { format = "text" }
+    logger, err := logadapter.NewSlog(os.Stderr, appConfig.LogLevel, format)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "warning: logging disabled: %v\n", err)
+        return nil
+    }
+    return logadapter.NewSlogLogger(logger)
+}
+
+func isConfigCmd(cmd *cobra.Command) bool {
+    for c := cmd; c != nil; c = c.Parent() {
+        if c == configCmd { return true }
+    }
+    return false
+}
+
+// rememberFlags saves the named flags, when given, to the config file as
+// defaults for later runs.
+func rememberFlags(cmd *cobra.Command, names ...string) {
+    for _, name := range names {
+        f := cmd.Flags().Lookup(name)
+        if f == nil || !f.Changed { continue }
+        if err := config.SetInFile(appConfig.Path(), flagKeys[name], f.Value.String()); err != nil {
+            fmt.Fprintf(os.Stderr, "warning: could not save %s: %v\n", name, err)
+        }
+    }
+}
+
+var configCmd = &cobra.Command{
+    Use:   "config",
+    Short: "Show, change and check configuration",
+    Long: `Settings come from, in increasing precedence: built-in defaults, the config
+file (YAML, TOML or JSON, chosen by extension), environment variables and
+command-line flags, including --set key=value.`,
+}
+
+var configShowCmd = &cobra.Command{
+    Use:   "show",
+    Short: "Show every setting with its value and where it came from",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+        for _, k := range config.Keys() {
+            v, _ := appConfig.Get(k)
+            fmt.Fprintf(tw, "%s\t%s\t%s\n", k, orDash(v), appConfig.Origin(k))
+        }
+        if err := tw.Flush(); err != nil { return err }
+        fmt.Printf("\nconfig file: %s\n", appConfig.Path())
+        if configErr != nil { fmt.Fprintf(os.Stderr, "\n%v\n", configErr) }
+        return nil
+    },
+}
+
+var configGetCmd = &cobra.Command{
+    Use:   "get <key>",
+    Short: "Print the value of a setting",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        v, err := appConfig.Get(args[0])
+        if err != nil { return herr.Newf(herr.InvalidArgument, "%w", err) }
+        fmt.Println(v)
+        return nil
+    },
+}
+
+var configSetCmd = &cobra.Command{
+    Use:   "set <key> <value>",
+    Short: "Save a setting to the config file",
+    Long:  "Save a setting to the config file. Lists are comma-separated; sizes accept units such as 2G.",
+    Args:  cobra.ExactArgs(2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if err := config.SetInFile(appConfig.Path(), args[0], args[1]); err != nil {
+            return herr.Newf(herr.InvalidArgument, "%w", err)
+        }
+        fmt.Printf("%s saved to %s\n", args[0], appConfig.Path())
+        for _, name := range envFor(args[0]) {
+            if os.Getenv(name) != "" { fmt.Fprintf(os.Stderr, "note: %s is set and overrides the file\n", name) }
+        }
+        return nil
+    },
+}
+
+var configValidateCmd = &cobra.Command{
+    Use:   "validate",
+    Short: "Check the configuration and report every problem",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if configErr != nil { return herr.Newf(herr.InvalidArgument, "invalid configuration:\n%w", configErr) }
+        fmt.Printf("ok (%s)\n", appConfig.Path())
+        return nil
+    },
+}
+
+var configKeysCmd = &cobra.Command{
+    Use:   "keys",
+    Short: "List settings with their environment variables",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        keys := config.Keys()
+        sort.Strings(keys)
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(tw, "KEY\tENV\tDESCRIPTION")
+        for _, k := range keys {
+            doc, env, _ := config.Describe(k)
+            fmt.Fprintf(tw, "%s\t%s\t%s\n", k, orDash(strings.Join(env, ", ")), doc)
+        }
+        return tw.Flush()
+    },
+}
+
+func envFor(key string) []string {
+    _, env, _ := config.Describe(key)
+    return env
+}
+
+func init() {
+    rootCmd.PersistentFlags().StringVar(&configFlags.path, "config", "", "Config file (default $GOSPER_CONFIG or config.{yaml,toml,json} in the user config dir)")
+    rootCmd.PersistentFlags().StringArrayVar(&configFlags.set, "set", nil, "Override a setting, key=value (repeatable)")
+    rootCmd.PersistentFlags().String("log-level", "info", "Log level: debug, info, warn or error")
+    rootCmd.PersistentFlags().String("log-format", "text", "Log format: text or json")
+    rootCmd.PersistentPreRunE = loadConfig
+    configCmd.AddCommand(configShowCmd, configGetCmd, configSetCmd, configValidateCmd, configKeysCmd)
+    rootCmd.AddCommand(configCmd)
+}
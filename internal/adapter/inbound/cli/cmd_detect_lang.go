@@ -0,0 +1,52 @@
+//go:build cli
+
+package cli
+
+import (
+    "fmt"
+    "github.com/spf13/cobra"
+    "gosper/internal/adapter/outbound/whispercpp"
+    "gosper/internal/infrastructure/clock"
+    "gosper/internal/usecase"
+)
+
+var detectLangFlags = struct{
+    model string
+    threads uint
+    all bool
+}{}
+
+var detectLangCmd = &cobra.Command{
+    Use:   "detect-lang <audiofile>",
+    Short: "Identify the spoken language of an audio file (first 30 s)",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        uc := &usecase.DetectLanguage{
+            Repo: newModelRepo(),
+            Detector: &whispercpp.Transcriber{},
+            Log: newLogger(),
+            Clock: clock.SystemClock{},
+        }
+        det, err := uc.Execute(cmd.Context(), usecase.DetectInput{
+            Path: args[0],
+            ModelName: appConfig.Model,
+            Threads: uint(appConfig.Threads),
+        })
+        if err != nil { return fmt.Errorf("language detection failed: %w", err) }
+        if !detectLangFlags.all {
+            fmt.Println(det.Language)
+            return nil
+        }
+        for _, p := range det.Probabilities {
+            fmt.Printf("%s\t%.4f\n", p.Language, p.Probability)
+        }
+        return nil
+    },
+}
+
+func init() {
+    rootCmd.AddCommand(detectLangCmd)
+    detectLangCmd.Flags().StringVar(&detectLangFlags.model, "model", "", "Multilingual model name or local path (default: config model)")
+    detectLangCmd.Flags().UintVar(&detectLangFlags.threads, "threads", 0, "Number of threads to use (default: config threads)")
+    detectLangCmd.Flags().BoolVar(&detectLangFlags.all, "all", false, "Print every candidate language with its probability")
+}
@@ -7,7 +7,7 @@ import (
     "strings"
     "github.com/spf13/cobra"
     "gosper/internal/adapter/outbound/audio"
-    "gosper/internal/infrastructure/config"
+    "gosper/internal/config"
     "gosper/internal/usecase"
 )
 
@@ -25,10 +25,10 @@ var devicesListCmd = &cobra.Command{
         devs, err := uc.Execute(cmd.Context())
         if err != nil { return err }
         if len(devs) == 0 { fmt.Println("No input devices found"); return nil }
-        cur, _ := config.LoadFile(config.DefaultPath())
+        cur := appConfig.Audio.Device
         for _, d := range devs {
             mark := ""
-            if cur.LastDeviceID != "" && (d.ID == cur.LastDeviceID || strings.EqualFold(d.Name, cur.LastDeviceID)) {
+            if cur != "" && (d.ID == cur || strings.EqualFold(d.Name, cur)) {
                 mark = "*"
             }
             fmt.Printf("%s\t%s\t%s\n", d.ID, d.Name, mark)
@@ -50,9 +50,7 @@ var devicesSelectCmd = &cobra.Command{
         // resolve selection robustly (exact id, name, prefix, substring, fuzzy)
         store := audio.ResolveDeviceID(devs, sel)
         if store == "" { store = sel }
-        c, _ := config.LoadFile(config.DefaultPath())
-        c.LastDeviceID = store
-        return config.SaveFile(config.DefaultPath(), c)
+        return config.SetInFile(appConfig.Path(), "audio.device", store)
     },
 }
 
@@ -0,0 +1,234 @@
+//go:build cli
+
+package cli
+
+import (
+    "fmt"
+    "os"
+    "text/tabwriter"
+
+    "github.com/spf13/cobra"
+    "gosper/internal/adapter/outbound/model"
+)
+
+// newModelRepo builds the model repository used by all commands from the
+// models.* settings. Downloads report progress on stderr.
+func newModelRepo() *model.FSRepo {
+    cfg := appConfig.Models
+    sources, err := model.ParseSources(cfg.Sources)
+    if err != nil { fmt.Fprintf(os.Stderr, "warning: ignoring models.sources: %v\n", err) }
+    bar := newProgressBar(os.Stderr, "")
+    return &model.FSRepo{
+        Sources:       sources,
+        CacheDir:      cfg.CacheDir,
+        BaseURL:       cfg.BaseURL,
+        MaxCacheBytes: cfg.CacheMaxBytes,
+        Pinned:        cfg.Pinned,
+        Progress: func(file string, done, total int64) {
+            bar.label = "downloading " + file
+            bar.Update(done, total)
+        },
+    }
+}
+
+var modelsFlags = struct{
+    baseURL string
+    cached bool
+    maxSize string
+}{}
+
+var modelsCmd = &cobra.Command{
+    Use:   "models",
+    Short: "Manage cached whisper models",
+}
+
+var modelsListCmd = &cobra.Command{
+    Use:   "list",
+    Short: "List cached models and models available from the manifest",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        repo := newModelRepo()
+        cached, err := repo.Cached()
+        if err != nil { return err }
+        m, err := repo.Manifest()
+        if err != nil { return err }
+        have := map[string]bool{}
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(tw, "NAME\tFILE\tSIZE\tQUANT\tLANG\tCACHED")
+        for _, c := range cached {
+            have[c.Entry.File] = true
+            fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Entry.Name, c.Entry.File, humanBytes(c.Size), orDash(c.Entry.Quantization), langLabel(c.Entry, c.Known), "yes")
+        }
+        if !modelsFlags.cached {
+            for _, e := range m.Models {
+                if have[e.File] { continue }
+                fmt.Fprintf(tw, "%s\t%s\t~%s\t%s\t%s\t%s\n", e.Name, e.File, humanBytes(e.Size), orDash(e.Quantization), langLabel(e, true), "")
+            }
+        }
+        if err := tw.Flush(); err != nil { return err }
+        fmt.Printf("\ncache: %s\n", repo.Dir())
+        return nil
+    },
+}
+
+var modelsPullCmd = &cobra.Command{
+    Use:   "pull <name>",
+    Short: "Download a model into the cache and verify it",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        repo := newModelRepo()
+        if repo.BaseURL == "" && len(repo.Sources) == 0 { repo.BaseURL = model.DefaultBaseURL }
+        bar := newProgressBar(os.Stderr, args[0])
+        repo.Progress = func(file string, done, total int64) { bar.Update(done, total) }
+        path, err := repo.Ensure(cmd.Context(), args[0])
+        bar.Done()
+        if err != nil { return fmt.Errorf("pull %s: %w", args[0], err) }
+        fmt.Println(path)
+        return nil
+    },
+}
+
+var modelsVerifyCmd = &cobra.Command{
+    Use:   "verify",
+    Short: "Check the SHA-256 of every cached model",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        res, err := newModelRepo().VerifyCached()
+        if err != nil { return err }
+        bad := 0
+        for _, r := range res {
+            switch {
+            case r.Err != nil:
+                bad++
+                fmt.Printf("%s\terror: %v\n", r.Model.Entry.File, r.Err)
+            default:
+                if r.Status == "mismatch" { bad++ }
+                fmt.Printf("%s\t%s\t%s\n", r.Model.Entry.File, r.Status, r.SHA256)
+            }
+        }
+        if bad > 0 { return fmt.Errorf("%d model(s) failed verification", bad) }
+        return nil
+    },
+}
+
+var modelsRmCmd = &cobra.Command{
+    Use:   "rm <name>...",
+    Short: "Remove cached models",
+    Args:  cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        repo := newModelRepo()
+        for _, name := range args {
+            if err := repo.Remove(name); err != nil { return err }
+            fmt.Printf("removed %s\n", name)
+        }
+        return nil
+    },
+}
+
+var modelsInfoCmd = &cobra.Command{
+    Use:   "info <name|path>",
+    Short: "Show model metadata read from the ggml header",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        repo := newModelRepo()
+        path := args[0]
+        if _, err := os.Stat(path); err != nil {
+            cm, cached, err := repo.Lookup(args[0])
+            if err != nil { return err }
+            if !cached { return fmt.Errorf("model %q is not cached; run 'gosper models pull %s'", args[0], args[0]) }
+            path = cm.Path
+        }
+        h, err := model.ReadHeader(path)
+        if err != nil { return err }
+        fi, err := os.Stat(path)
+        if err != nil { return err }
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintf(tw, "path\t%s\n", path)
+        fmt.Fprintf(tw, "size\t%s\n", humanBytes(fi.Size()))
+        fmt.Fprintf(tw, "architecture\t%s\n", h.Architecture())
+        fmt.Fprintf(tw, "multilingual\t%t\n", h.Multilingual())
+        fmt.Fprintf(tw, "vocab size\t%d\n", h.NVocab)
+        fmt.Fprintf(tw, "quantization\t%s (version %d)\n", h.Quantization(), h.QntVersion)
+        fmt.Fprintf(tw, "audio\tctx=%d state=%d heads=%d layers=%d mels=%d\n", h.NAudioCtx, h.NAudioState, h.NAudioHead, h.NAudioLayer, h.NMels)
+        fmt.Fprintf(tw, "text\tctx=%d state=%d heads=%d layers=%d\n", h.NTextCtx, h.NTextState, h.NTextHead, h.NTextLayer)
+        return tw.Flush()
+    },
+}
+
+var modelsUsageCmd = &cobra.Command{
+    Use:   "usage",
+    Short: "Show cache disk usage, quota and last use of each model",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        u, err := newModelRepo().CacheUsage(cmd.Context())
+        if err != nil { return err }
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(tw, "NAME\tSIZE\tLAST USED\tPINNED")
+        for _, m := range u.Models {
+            pinned := ""
+            if m.Pinned { pinned = "yes" }
+            fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.Name, humanBytes(m.Size), m.LastUsed.Local().Format("2006-01-02 15:04"), pinned)
+        }
+        if err := tw.Flush(); err != nil { return err }
+        quota := "unlimited"
+        if u.QuotaBytes > 0 { quota = humanBytes(u.QuotaBytes) }
+        fmt.Printf("\n%s used of %s in %s\n", humanBytes(u.UsedBytes), quota, u.Dir)
+        return nil
+    },
+}
+
+var modelsPinCmd = &cobra.Command{
+    Use:   "pin <name>...",
+    Short: "Protect models from eviction when the cache is over quota",
+    Args:  cobra.MinimumNArgs(1),
+    RunE:  func(cmd *cobra.Command, args []string) error { return pinModels(args, true) },
+}
+
+var modelsUnpinCmd = &cobra.Command{
+    Use:   "unpin <name>...",
+    Short: "Allow pinned models to be evicted again",
+    Args:  cobra.MinimumNArgs(1),
+    RunE:  func(cmd *cobra.Command, args []string) error { return pinModels(args, false) },
+}
+
+func pinModels(names []string, pinned bool) error {
+    repo := newModelRepo()
+    for _, name := range names {
+        if err := repo.Pin(name, pinned); err != nil { return err }
+    }
+    return nil
+}
+
+var modelsPruneCmd = &cobra.Command{
+    Use:   "prune",
+    Short: "Evict least-recently-used models until the cache fits its quota",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        repo := newModelRepo()
+        if repo.MaxCacheBytes <= 0 { return fmt.Errorf("no cache quota set; pass --max-size or set models.cache_max") }
+        removed, err := repo.Prune()
+        for _, m := range removed { fmt.Printf("evicted %s (%s)\n", m.Entry.Name, humanBytes(m.Size)) }
+        return err
+    },
+}
+
+func orDash(s string) string {
+    if s == "" { return "-" }
+    return s
+}
+
+func langLabel(e model.ManifestEntry, known bool) string {
+    switch {
+    case !known:
+        return "-"
+    case e.Multilingual:
+        return "multi"
+    default:
+        return "en"
+    }
+}
+
+func init() {
+    modelsListCmd.Flags().BoolVar(&modelsFlags.cached, "cached", false, "Only list models in the cache")
+    modelsPullCmd.Flags().StringVar(&modelsFlags.baseURL, "base-url", "", "Model download base URL, tried after models.sources (default models.base_url, or Hugging Face when no sources are set)")
+    modelsPruneCmd.Flags().StringVar(&modelsFlags.maxSize, "max-size", "", "Cache quota to enforce, e.g. 2G (default models.cache_max)")
+    modelsCmd.AddCommand(modelsListCmd, modelsPullCmd, modelsVerifyCmd, modelsRmCmd, modelsInfoCmd,
+        modelsUsageCmd, modelsPinCmd, modelsUnpinCmd, modelsPruneCmd)
+    rootCmd.AddCommand(modelsCmd)
+}
@@ -7,10 +7,9 @@ import (
 
 	"github.com/spf13/cobra"
 	"gosper/internal/adapter/outbound/audio"
-	"gosper/internal/adapter/outbound/model"
 	"gosper/internal/adapter/outbound/storage"
 	"gosper/internal/adapter/outbound/whispercpp"
-	"gosper/internal/infrastructure/config"
+	"gosper/internal/infrastructure/clock"
 	"gosper/internal/usecase"
 )
 
@@ -23,91 +22,64 @@ var recordFlags = struct {
 	beep     bool
 	outdev   string
 	beepvol  float64
+	filters  usecase.Filters
+
+	minLogProb     float32
+	maxNoSpeech    float32
+	maxCompression float32
+	dropLowConf    bool
 }{}
 
 var recordCmd = &cobra.Command{
 	Use:   "record",
 	Short: "Record from microphone and transcribe",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// load config for defaults
-		cfg := config.FromEnv()
-		if p, err := config.LoadFile(config.DefaultPath()); err == nil {
-			if recordFlags.device == "" && p.LastDeviceID != "" {
-				recordFlags.device = p.LastDeviceID
-			}
-			if !recordFlags.beep {
-				recordFlags.beep = p.AudioFeedback
-			}
-			if recordFlags.outdev == "" && p.OutputDeviceID != "" {
-				recordFlags.outdev = p.OutputDeviceID
-			}
-			if recordFlags.beepvol == 0 && p.BeepVolume != 0 {
-				recordFlags.beepvol = p.BeepVolume
-			}
-		}
-
+		cfg := appConfig
 		uc := &usecase.RecordAndTranscribe{
-			Audio: audio.NewInput(),
-			Repo:  &model.FSRepo{},
-			Trans: &whispercpp.Transcriber{},
-			Store: &storage.FS{},
+			Audio:  audio.NewInput(),
+			Repo:   newModelRepo(),
+			Trans:  &whispercpp.Transcriber{},
+			Store:  &storage.FS{},
+			Logger: newLogger(),
+			Clock:  clock.SystemClock{},
 		}
-		if recordFlags.beep {
-			audio.PlayBeepOptions(audio.BeepOptions{DeviceID: recordFlags.outdev, Volume: float32(recordFlags.beepvol)})
+		beep := audio.BeepOptions{DeviceID: cfg.Audio.OutputDevice, Volume: float32(cfg.Audio.BeepVolume)}
+		if cfg.Audio.Feedback {
+			audio.PlayBeepOptions(beep)
 		}
 		_, err := uc.Execute(cmd.Context(), usecase.RecordInput{
-			DeviceID:  recordFlags.device,
+			DeviceID:  cfg.Audio.Device,
 			Duration:  recordFlags.duration,
-			ModelName: recordFlags.model,
-			Language:  recordFlags.lang,
+			ModelName: cfg.Model,
+			Language:  cfg.Language,
 			OutPath:   recordFlags.out,
-		})
-		if recordFlags.beep {
-			audio.PlayBeepOptions(audio.BeepOptions{DeviceID: recordFlags.outdev, Volume: float32(recordFlags.beepvol)})
-		}
+			Filters:   recordFlags.filters,
 
-		// Persist configuration if any settings were provided
-		configNeedsSaving := recordFlags.device != "" || recordFlags.outdev != "" ||
-			recordFlags.beepvol != 0 || recordFlags.beep
-
-		if configNeedsSaving || err != nil {
-			// Try to load existing config
-			p, loadErr := config.LoadFile(config.DefaultPath())
-			if loadErr != nil {
-				// Config doesn't exist, start with env defaults
-				p = cfg
-			}
-
-			// Update config with flag values
-			if recordFlags.device != "" {
-				p.LastDeviceID = recordFlags.device
-			}
-			p.AudioFeedback = recordFlags.beep
-			if recordFlags.outdev != "" {
-				p.OutputDeviceID = recordFlags.outdev
-			}
-			if recordFlags.beepvol != 0 {
-				p.BeepVolume = recordFlags.beepvol
-			}
-
-			// Save only if we have changes to persist
-			if configNeedsSaving {
-				_ = config.SaveFile(config.DefaultPath(), p)
-			}
+			MinAvgLogProb:       recordFlags.minLogProb,
+			MaxNoSpeechProb:     recordFlags.maxNoSpeech,
+			MaxCompressionRatio: recordFlags.maxCompression,
+			DropLowConfidence:   recordFlags.dropLowConf,
+		})
+		if cfg.Audio.Feedback {
+			audio.PlayBeepOptions(beep)
 		}
 
+		// Remember device settings given on the command line for next time
+		rememberFlags(cmd, "device", "audio-feedback", "output-device", "beep-volume")
 		return err
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(recordCmd)
-	recordCmd.Flags().StringVar(&recordFlags.device, "device", "", "Device ID")
+	recordCmd.Flags().StringVar(&recordFlags.device, "device", "", "Device ID (remembered; default: config audio.device)")
 	recordCmd.Flags().DurationVar(&recordFlags.duration, "duration", 0, "Record duration (e.g., 5s). 0 until Ctrl-C")
-	recordCmd.Flags().StringVar(&recordFlags.model, "model", "", "Model name or local path")
-	recordCmd.Flags().StringVar(&recordFlags.lang, "lang", "auto", "Language code or 'auto'")
+	recordCmd.Flags().StringVar(&recordFlags.model, "model", "", "Model name or local path (default: config model)")
+	recordCmd.Flags().StringVar(&recordFlags.lang, "lang", "", "Language code or 'auto' (default: config language)")
 	recordCmd.Flags().StringVarP(&recordFlags.out, "out", "o", "", "Output transcript path (.txt or .json)")
-	recordCmd.Flags().BoolVar(&recordFlags.beep, "audio-feedback", false, "Beep on start/stop (console bell)")
-	recordCmd.Flags().StringVar(&recordFlags.outdev, "output-device", "", "Output device ID or name for beep")
-	recordCmd.Flags().Float64Var(&recordFlags.beepvol, "beep-volume", 0.2, "Beep volume 0..1 (malgo builds)")
+	recordCmd.Flags().BoolVar(&recordFlags.beep, "audio-feedback", false, "Beep on start/stop (console bell; remembered)")
+	recordCmd.Flags().StringVar(&recordFlags.outdev, "output-device", "", "Output device ID or name for beep (remembered)")
+	recordCmd.Flags().Float64Var(&recordFlags.beepvol, "beep-volume", 0, "Beep volume 0..1, 0 for the default (malgo builds; remembered)")
+	addConfidenceFlags(recordCmd, &recordFlags.minLogProb, &recordFlags.maxNoSpeech, &recordFlags.maxCompression, &recordFlags.dropLowConf)
+	addFilterFlags(recordCmd, &recordFlags.filters)
 }
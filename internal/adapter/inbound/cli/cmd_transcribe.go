@@ -5,9 +5,9 @@ package cli
 import (
     "fmt"
     "github.com/spf13/cobra"
-    "gosper/internal/adapter/outbound/model"
     "gosper/internal/adapter/outbound/storage"
     "gosper/internal/adapter/outbound/whispercpp"
+    "gosper/internal/infrastructure/clock"
     "gosper/internal/usecase"
 )
 
@@ -21,6 +21,21 @@ var transcribeFlags = struct{
     beam int
     maxtokens uint
     prompt string
+    temperature float32
+    temperatureInc float32
+    bestOf int
+    entropyThold float32
+    logprobThold float32
+    noSpeechThold float32
+    suppressBlank bool
+    suppressRegex string
+    maxLen uint
+    splitOnWord bool
+    minLogProb float32
+    maxNoSpeech float32
+    maxCompression float32
+    dropLowConf bool
+    filters usecase.Filters
 }{}
 
 var transcribeCmd = &cobra.Command{
@@ -31,22 +46,39 @@ var transcribeCmd = &cobra.Command{
         ctx := cmd.Context()
         path := args[0]
         uc := &usecase.TranscribeFile{
-            Repo: &model.FSRepo{},
+            Repo: newModelRepo(),
             Trans: &whispercpp.Transcriber{},
             Store: storage.FS{},
+            Log: newLogger(),
+            Clock: clock.SystemClock{},
             Factory: nil, // default decoder.New
         }
         _, err := uc.Execute(ctx, usecase.TranscribeInput{
             Path: path,
             OutPath: transcribeFlags.out,
-            ModelName: transcribeFlags.model,
-            Language: transcribeFlags.lang,
+            ModelName: appConfig.Model,
+            Language: appConfig.Language,
             Translate: transcribeFlags.translate,
-            Threads: transcribeFlags.threads,
+            Threads: uint(appConfig.Threads),
             Timestamps: transcribeFlags.timestamps,
             BeamSize: transcribeFlags.beam,
             MaxTokens: transcribeFlags.maxtokens,
             InitialPrompt: transcribeFlags.prompt,
+            Temperature: transcribeFlags.temperature,
+            TemperatureInc: transcribeFlags.temperatureInc,
+            BestOf: transcribeFlags.bestOf,
+            EntropyThreshold: transcribeFlags.entropyThold,
+            LogProbThreshold: transcribeFlags.logprobThold,
+            NoSpeechThreshold: transcribeFlags.noSpeechThold,
+            AllowBlank: !transcribeFlags.suppressBlank,
+            SuppressRegex: transcribeFlags.suppressRegex,
+            MaxSegmentLength: transcribeFlags.maxLen,
+            SplitOnWord: transcribeFlags.splitOnWord,
+            MinAvgLogProb: transcribeFlags.minLogProb,
+            MaxNoSpeechProb: transcribeFlags.maxNoSpeech,
+            MaxCompressionRatio: transcribeFlags.maxCompression,
+            DropLowConfidence: transcribeFlags.dropLowConf,
+            Filters: transcribeFlags.filters,
         })
         if err != nil { return fmt.Errorf("transcription failed: %w", err) }
         return nil
@@ -55,14 +87,43 @@ var transcribeCmd = &cobra.Command{
 
 func init() {
     rootCmd.AddCommand(transcribeCmd)
-    transcribeCmd.Flags().StringVar(&transcribeFlags.model, "model", "", "Model name or local path")
-    transcribeCmd.Flags().StringVar(&transcribeFlags.lang, "lang", "auto", "Language code or 'auto'")
+    transcribeCmd.Flags().StringVar(&transcribeFlags.model, "model", "", "Model name or local path (default: config model)")
+    transcribeCmd.Flags().StringVar(&transcribeFlags.lang, "lang", "", "Language code or 'auto' (default: config language)")
     transcribeCmd.Flags().BoolVar(&transcribeFlags.translate, "translate", false, "Translate to English")
-    transcribeCmd.Flags().UintVar(&transcribeFlags.threads, "threads", 0, "Number of threads to use")
+    transcribeCmd.Flags().UintVar(&transcribeFlags.threads, "threads", 0, "Number of threads to use (default: config threads)")
     transcribeCmd.Flags().BoolVar(&transcribeFlags.timestamps, "timestamps", false, "Emit token timestamps")
     transcribeCmd.Flags().IntVar(&transcribeFlags.beam, "beam", 0, "Beam size (0 default)")
     transcribeCmd.Flags().UintVar(&transcribeFlags.maxtokens, "max-tokens", 0, "Max tokens per segment (0 unlimited)")
     transcribeCmd.Flags().StringVar(&transcribeFlags.prompt, "prompt", "", "Initial prompt")
+    transcribeCmd.Flags().Float32Var(&transcribeFlags.temperature, "temperature", 0, "Initial sampling temperature (0 greedy)")
+    transcribeCmd.Flags().Float32Var(&transcribeFlags.temperatureInc, "temperature-inc", 0, "Temperature fallback step (0 default, negative disables fallback)")
+    transcribeCmd.Flags().IntVar(&transcribeFlags.bestOf, "best-of", 0, "Candidates sampled when temperature > 0 (0 default)")
+    transcribeCmd.Flags().Float32Var(&transcribeFlags.entropyThold, "entropy-thold", 0, "Entropy threshold for fallback (0 default)")
+    transcribeCmd.Flags().Float32Var(&transcribeFlags.logprobThold, "logprob-thold", 0, "Average log-probability threshold for fallback (0 default)")
+    transcribeCmd.Flags().Float32Var(&transcribeFlags.noSpeechThold, "no-speech-thold", 0, "No-speech probability threshold (0 default)")
+    transcribeCmd.Flags().BoolVar(&transcribeFlags.suppressBlank, "suppress-blank", true, "Suppress blank output at segment start")
+    transcribeCmd.Flags().StringVar(&transcribeFlags.suppressRegex, "suppress-regex", "", "Never emit tokens matching this regular expression")
+    transcribeCmd.Flags().UintVar(&transcribeFlags.maxLen, "max-len", 0, "Max segment length in characters (0 unlimited)")
+    transcribeCmd.Flags().BoolVar(&transcribeFlags.splitOnWord, "split-on-word", false, "Split segments on word boundaries")
+    addConfidenceFlags(transcribeCmd, &transcribeFlags.minLogProb, &transcribeFlags.maxNoSpeech, &transcribeFlags.maxCompression, &transcribeFlags.dropLowConf)
+    addFilterFlags(transcribeCmd, &transcribeFlags.filters)
     transcribeCmd.Flags().StringVarP(&transcribeFlags.out, "out", "o", "", "Output transcript path (.txt or .json)")
 }
 
+// addConfidenceFlags registers the segment confidence thresholds and the
+// switch to drop flagged segments.
+func addConfidenceFlags(cmd *cobra.Command, minLogProb, maxNoSpeech, maxCompression *float32, drop *bool) {
+    cmd.Flags().Float32Var(minLogProb, "min-logprob", usecase.DefaultMinAvgLogProb, "Flag segments below this average log-probability (0 disables)")
+    cmd.Flags().Float32Var(maxNoSpeech, "max-no-speech", usecase.DefaultMaxNoSpeechProb, "Flag segments above this no-speech probability (0 disables)")
+    cmd.Flags().Float32Var(maxCompression, "max-compression-ratio", usecase.DefaultMaxCompressionRatio, "Flag segments above this compression ratio (0 disables)")
+    cmd.Flags().BoolVar(drop, "drop-low-confidence", false, "Drop flagged segments instead of marking them")
+}
+
+// addFilterFlags registers the transcript post-processing toggles, all on by default.
+func addFilterFlags(cmd *cobra.Command, f *usecase.Filters) {
+    d := usecase.DefaultFilters
+    cmd.Flags().BoolVar(&f.Duplicates, "drop-duplicates", d.Duplicates, "Drop segments repeating the previous one")
+    cmd.Flags().BoolVar(&f.NgramLoops, "collapse-loops", d.NgramLoops, "Collapse phrases repeated back to back")
+    cmd.Flags().BoolVar(&f.Hallucinations, "drop-hallucinations", d.Hallucinations, "Drop known phantom phrases (e.g. \"Thank you.\") in low-confidence or no-speech segments")
+    cmd.Flags().BoolVar(&f.ZeroDuration, "drop-zero-duration", d.ZeroDuration, "Drop zero-duration segments")
+}
@@ -0,0 +1,57 @@
+//go:build cli
+
+package cli
+
+import (
+    "fmt"
+    "io"
+    "strings"
+    "time"
+)
+
+// progressBar renders download progress on a single terminal line.
+type progressBar struct {
+    w       io.Writer
+    label   string
+    last    time.Time
+    started bool
+}
+
+func newProgressBar(w io.Writer, label string) *progressBar { return &progressBar{w: w, label: label} }
+
+// Update redraws the bar at most ten times a second, and always on
+// completion, which also ends the line.
+func (p *progressBar) Update(done, total int64) {
+    now := time.Now()
+    if done != total && now.Sub(p.last) < 100*time.Millisecond { return }
+    p.last = now
+    p.started = true
+    if total <= 0 {
+        fmt.Fprintf(p.w, "\r%s %s", p.label, humanBytes(done))
+        return
+    }
+    const width = 30
+    filled := int(float64(width) * float64(done) / float64(total))
+    if filled > width { filled = width }
+    fmt.Fprintf(p.w, "\r%s [%s%s] %3.0f%% %s/%s", p.label,
+        strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+        100*float64(done)/float64(total), humanBytes(done), humanBytes(total))
+    if done >= total { p.Done() }
+}
+
+// Done ends the progress line, if one is open.
+func (p *progressBar) Done() {
+    if p.started { fmt.Fprintln(p.w) }
+    p.started = false
+}
+
+func humanBytes(n int64) string {
+    const unit = 1024
+    if n < unit { return fmt.Sprintf("%d B", n) }
+    div, exp := int64(unit), 0
+    for m := n / unit; m >= unit; m /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -10,6 +10,8 @@ import (
     "syscall"
 
     "github.com/spf13/cobra"
+
+    herr "gosper/pkg/errors"
 )
 
 var rootCmd = &cobra.Command{
@@ -29,9 +31,25 @@ func Execute() error {
     rootCmd.SetContext(ctx)
     // attach subcommands
     rootCmd.AddCommand(versionCmd)
+    rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+        return herr.Newf(herr.InvalidArgument, "%w", err)
+    })
+    classifyArgErrors(rootCmd)
     return rootCmd.Execute()
 }
 
+// classifyArgErrors makes positional argument errors of cmd and its
+// subcommands InvalidArgument, so that usage mistakes exit with status 2.
+func classifyArgErrors(cmd *cobra.Command) {
+    if check := cmd.Args; check != nil {
+        cmd.Args = func(c *cobra.Command, args []string) error {
+            if err := check(c, args); err != nil { return herr.Newf(herr.InvalidArgument, "%w", err) }
+            return nil
+        }
+    }
+    for _, sub := range cmd.Commands() { classifyArgErrors(sub) }
+}
+
 func signalContext() (context.Context, context.CancelFunc) {
     ctx, cancel := context.WithCancel(context.Background())
     c := make(chan os.Signal, 1)
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"gosper/internal/domain"
+	herr "gosper/pkg/errors"
+	"gosper/pkg/trace"
+)
+
+type principalKey struct{}
+
+// PrincipalFrom returns the authenticated client of a request, if any.
+func PrincipalFrom(ctx context.Context) (domain.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(domain.Principal)
+	return p, ok
+}
+
+// clientID names the authenticated client of r for logs, or "-".
+func clientID(r *http.Request) string {
+	if p, ok := PrincipalFrom(r.Context()); ok {
+		return p.Method + ":" + p.ID
+	}
+	return "-"
+}
+
+// publicPaths are served without credentials, for probes and monitoring.
+var publicPaths = map[string]bool{
+	"/healthz": true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// credential returns the API key or bearer token of r, from
+// "Authorization: Bearer <token>" or "X-API-Key: <key>".
+func credential(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// authMiddleware authenticates requests: 401 without valid credentials
+// and 403 for credentials that may not be used. Without an authenticator
+// every request is let through.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cred := credential(r)
+		if cred == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gosper"`)
+			s.clientError(w, r, herr.Unauthenticated, "missing API key or bearer token")
+			return
+		}
+		p, err := s.auth.Authenticate(r.Context(), cred)
+		if err != nil {
+			if herr.From(err).Code == herr.Unauthenticated {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gosper", error="invalid_token"`)
+			}
+			s.serverError(w, r, err)
+			return
+		}
+		stateOf(r).principal = p.Method + ":" + p.ID
+		trace.FromContext(r.Context()).SetAttrs(trace.String("enduser.id", p.Method+":"+p.ID))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// quotaMiddleware counts requests against the client's quota, with 429
+// once it is spent.
+func (s *Server) quotaMiddleware(next http.Handler) http.Handler {
+	if s.usage == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := PrincipalFrom(r.Context()); ok {
+			if err := s.usage.Begin(p); err != nil {
+				s.serverError(w, r, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordAudio adds audio processed for the request's client to its usage
+// and audio rate limit, and to the request's access log line.
+func (s *Server) recordAudio(r *http.Request, d time.Duration) {
+	stateOf(r).audio += d
+	if p, ok := PrincipalFrom(r.Context()); ok && s.usage != nil {
+		s.usage.Record(p, d)
+	}
+	if s.limiter != nil {
+		s.limiter.Charge(s.rateKey(r), d)
+	}
+}
+
+// usageHandler reports the calling client's usage and quota
+func (s *Server) usageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.clientError(w, r, herr.MethodNotAllowed, "GET required")
+		return
+	}
+	p, ok := PrincipalFrom(r.Context())
+	if !ok || s.usage == nil {
+		s.clientError(w, r, herr.NotFound, "authentication is not enabled")
+		return
+	}
+	u := s.usage.Get(p.ID)
+	var resets *time.Time
+	if !u.Resets.IsZero() {
+		resets = &u.Resets
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":            p.ID,
+		"requests":      u.Requests,
+		"audio_seconds": u.AudioSeconds,
+		"since":         u.Since,
+		"resets":        resets,
+		"quota": map[string]any{
+			"requests":      p.Quota.Requests,
+			"audio_seconds": p.Quota.AudioSeconds,
+		},
+	})
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig is the cross-origin policy for browser clients.
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://app.example.com",
+	// patterns such as "https://*.example.com" matching any subdomain, or
+	// "*" for any origin. Empty allows no cross-origin requests.
+	AllowedOrigins []string
+	// AllowedHeaders are the request headers browsers may send.
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies and client certificates.
+	// Browsers refuse "*" with credentials, so matching origins are echoed.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// exposedHeaders are the response headers scripts may read.
+const exposedHeaders = "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After, X-Request-ID"
+
+// allowedOrigin reports whether origin matches the policy.
+func (c CORSConfig) allowedOrigin(origin string) bool {
+	for _, p := range c.AllowedOrigins {
+		if p == "*" || strings.EqualFold(p, origin) || matchSubdomain(p, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSubdomain reports whether origin matches a "scheme://*.domain[:port]"
+// pattern: same scheme and port, and a host below domain (not domain
+// itself).
+func matchSubdomain(pattern, origin string) bool {
+	pScheme, pHost, ok := strings.Cut(pattern, "://")
+	if !ok {
+		return false
+	}
+	suffix, ok := strings.CutPrefix(pHost, "*.")
+	if !ok {
+		return false
+	}
+	oScheme, oHost, ok := strings.Cut(origin, "://")
+	if !ok || !strings.EqualFold(pScheme, oScheme) {
+		return false
+	}
+	oHost, suffix = strings.ToLower(oHost), "."+strings.ToLower(suffix)
+	return len(oHost) > len(suffix) && strings.HasSuffix(oHost, suffix) && !strings.ContainsAny(oHost, "/@")
+}
+
+// corsMiddleware applies the CORS policy and answers preflight requests.
+// Requests from origins outside the policy get no CORS headers, so the
+// browser withholds the response from the calling page.
+func corsMiddleware(c CORSConfig, next http.Handler) http.Handler {
+	wildcard := false
+	for _, p := range c.AllowedOrigins {
+		wildcard = wildcard || p == "*"
+	}
+	allowHeaders := strings.Join(c.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(c.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions
+		if origin != "" {
+			h.Add("Vary", "Origin")
+		}
+		if origin != "" && c.allowedOrigin(origin) {
+			if wildcard && !c.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				if allowHeaders != "" {
+					h.Set("Access-Control-Allow-Headers", allowHeaders)
+				}
+				if c.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", maxAge)
+				}
+			} else {
+				h.Set("Access-Control-Expose-Headers", exposedHeaders)
+			}
+		}
+
+		// Handle preflight requests
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
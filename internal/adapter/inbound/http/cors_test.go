@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSConfig_AllowedOrigin(t *testing.T) {
+	c := CORSConfig{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"}}
+	for origin, want := range map[string]bool{
+		"https://app.example.com":       true,
+		"https://APP.example.com":       true,
+		"http://app.example.com":        false,
+		"https://evil.com":              false,
+		"https://a.example.org":         true,
+		"https://a.b.example.org":       true,
+		"https://example.org":           false,
+		"https://evilexample.org":       false,
+		"https://a.example.org:8443":    false,
+		"https://a.example.org.evil.io": false,
+	} {
+		if got := c.allowedOrigin(origin); got != want {
+			t.Errorf("allowedOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	h := corsMiddleware(CORSConfig{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}, next)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/transcribe", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	hdr := rec.Header()
+	if rec.Code != http.StatusNoContent || hdr.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		hdr.Get("Access-Control-Allow-Credentials") != "true" || hdr.Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" ||
+		hdr.Get("Access-Control-Max-Age") != "600" || hdr.Get("Vary") != "Origin" {
+		t.Fatalf("preflight: %d %v", rec.Code, hdr)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/transcribe", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Fatalf("request ID not exposed: %v", rec.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/transcribe", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin: %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	herr "gosper/pkg/errors"
+	"gosper/pkg/trace"
+)
+
+// AudioURLConfig controls fetching the audio of a request from the URL in
+// its audio_url field.
+type AudioURLConfig struct {
+	// AllowedHosts are the hosts audio may be fetched from: exact names or
+	// addresses, or *.example.com for subdomains. Empty disables audio_url.
+	AllowedHosts []string
+	// AllowPrivate permits hosts that resolve to private addresses;
+	// loopback, link-local and unspecified addresses are always refused.
+	AllowPrivate bool
+	// Timeout bounds the whole fetch, body included.
+	Timeout time.Duration
+}
+
+// maxRedirects is how many redirects a fetch follows, each to an allowed
+// host.
+const maxRedirects = 3
+
+// errBlockedAddr refuses connections to addresses audio is never fetched
+// from.
+var errBlockedAddr = errors.New("address not allowed")
+
+// fetcher downloads audio for audio_url. It only connects to allowed hosts,
+// checking the address actually dialled rather than the name, so neither
+// redirects nor DNS answers can point it at the server's own network.
+type fetcher struct {
+	hosts   []string
+	blocked func(netip.Addr) bool
+	client  *http.Client
+}
+
+// newFetcher returns a fetcher for cfg, or nil when audio_url is disabled.
+func newFetcher(cfg AudioURLConfig) *fetcher {
+	if len(cfg.AllowedHosts) == 0 {
+		return nil
+	}
+	f := &fetcher{blocked: func(a netip.Addr) bool { return blockedAddr(a, cfg.AllowPrivate) }}
+	for _, h := range cfg.AllowedHosts {
+		f.hosts = append(f.hosts, strings.ToLower(h))
+	}
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || f.blocked(ap.Addr().Unmap()) {
+				return errBlockedAddr
+			}
+			return nil
+		},
+	}
+	f.client = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			Proxy:               nil, // a proxy would dial for us, unchecked
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     time.Minute,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return herr.Newf(herr.InvalidArgument, "audio_url: more than %d redirects", maxRedirects)
+			}
+			return f.check(req.URL)
+		},
+	}
+	return f
+}
+
+// blockedAddr reports whether audio must not be fetched from a.
+func blockedAddr(a netip.Addr, allowPrivate bool) bool {
+	return !a.IsValid() || a.IsLoopback() || a.IsLinkLocalUnicast() || a.IsLinkLocalMulticast() ||
+		a.IsInterfaceLocalMulticast() || a.IsMulticast() || a.IsUnspecified() || (a.IsPrivate() && !allowPrivate)
+}
+
+// check refuses URLs that are not plain http(s) URLs of an allowed host.
+func (f *fetcher) check(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return herr.New(herr.InvalidArgument, "audio_url must be an http or https URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range f.hosts {
+		if host == h || (strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:])) {
+			return nil
+		}
+	}
+	return herr.Newf(herr.PermissionDenied, "audio_url host %q is not allowed", host).WithDetail("host", host)
+}
+
+// fetchAudio downloads the audio at rawURL to the temp directory, under the
+// same size limit and format checks as uploads. It returns the file and its
+// size.
+func (s *Server) fetchAudio(ctx context.Context, r *http.Request, rawURL string) (_ *os.File, _ int64, err error) {
+	if s.fetch == nil {
+		return nil, 0, herr.New(herr.InvalidArgument, "audio_url is not enabled on this server")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, 0, herr.New(herr.InvalidArgument, "audio_url must be an http or https URL")
+	}
+	if err := s.fetch.check(u); err != nil {
+		return nil, 0, err
+	}
+	ctx, span := trace.Start(ctx, "http.fetch_audio", trace.String("server.address", u.Hostname()))
+	defer func() {
+		span.Fail(err)
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, herr.New(herr.InvalidArgument, "audio_url must be an http or https URL")
+	}
+	resp, err := s.fetch.client.Do(req)
+	if err != nil {
+		return nil, 0, fetchError(err)
+	}
+	defer resp.Body.Close()
+	span.SetAttrs(trace.Int("http.response.status_code", int64(resp.StatusCode)))
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, herr.Newf(herr.InvalidArgument, "audio_url answered %s", resp.Status).WithDetail("status", resp.StatusCode)
+	}
+	body := resp.Body
+	if s.maxUpload > 0 {
+		if resp.ContentLength > s.maxUpload {
+			return nil, 0, tooLarge(s.maxUpload)
+		}
+		body = http.MaxBytesReader(nil, body, s.maxUpload)
+	}
+
+	// The media type names the format when it is one we know; S3 and the
+	// like often send a generic one, so then the extension does, or the
+	// content
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	declared := mediaTypes[mt]
+	if ext := strings.ToLower(path.Ext(u.Path)); declared == "" && knownFormats[ext] {
+		declared = ext
+	}
+	f, n, err := s.saveAudio(r, body, declared)
+	if err != nil {
+		return nil, 0, fetchError(err)
+	}
+	return f, n, nil
+}
+
+// fetchError classifies a failed fetch.
+func fetchError(err error) error {
+	var ne net.Error
+	var typed *herr.Error
+	switch {
+	case errors.Is(err, errBlockedAddr):
+		return herr.New(herr.PermissionDenied, "audio_url resolves to an address that is not allowed")
+	case errors.As(err, &ne) && ne.Timeout():
+		return herr.New(herr.DeadlineExceeded, "fetching audio_url timed out")
+	case errors.As(err, &typed):
+		return typed
+	}
+	return herr.Newf(herr.InvalidArgument, "fetch audio_url: %v", err)
+}
@@ -0,0 +1,185 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gosper/internal/usecase"
+	herr "gosper/pkg/errors"
+)
+
+func TestRawUpload(t *testing.T) {
+	s, dir := uploadServer(t, 1<<20, 0)
+	srv := httptest.NewServer(s.httpServer.Handler)
+	defer srv.Close()
+
+	for _, c := range []struct {
+		name, contentType string
+		body              []byte
+		want              int
+		code              herr.Code
+	}{
+		{"wav", "audio/wav", wav(), http.StatusOK, ""},
+		{"octet-stream", "application/octet-stream", wav(), http.StatusOK, ""},
+		{"mismatch", "audio/mpeg", wav(), http.StatusUnprocessableEntity, herr.InvalidAudio},
+		{"unsupported audio", "audio/x-m4a", []byte("....ftypM4A "), http.StatusUnsupportedMediaType, herr.UnsupportedFormat},
+		{"not audio", "application/json", []byte(`{}`), http.StatusUnsupportedMediaType, herr.UnsupportedFormat},
+	} {
+		resp, err := http.Post(srv.URL+"/api/transcribe?lang=en&model=tiny.en", c.contentType, bytes.NewReader(c.body))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var body responseError
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != c.want || body.Code != c.code {
+			t.Errorf("%s: got %s %+v, want %d %s", c.name, resp.Status, body, c.want, c.code)
+		}
+		if left, _ := os.ReadDir(dir); len(left) != 0 {
+			t.Errorf("%s: left %v in the temp dir", c.name, left)
+		}
+	}
+
+	// Malformed thresholds are refused rather than replaced by the default
+	resp, err := http.Post(srv.URL+"/api/transcribe?min_logprob=abc", "audio/wav", bytes.NewReader(wav()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body responseError
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || body.Code != herr.InvalidArgument {
+		t.Errorf("malformed min_logprob: got %s %+v", resp.Status, body)
+	}
+}
+
+func TestFetchAudio(t *testing.T) {
+	audio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a.wav", "/big.wav":
+			w.Header().Set("Content-Type", "binary/octet-stream")
+			w.Write(wav())
+		case "/away":
+			http.Redirect(w, r, strings.Replace("http://"+r.Host, "127.0.0.1", "localhost", 1)+"/a.wav", http.StatusFound)
+		case "/slow.wav":
+			time.Sleep(time.Second)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer audio.Close()
+
+	newServer := func(maxUpload int64) *Server {
+		return NewServer(
+			&usecase.TranscribeFile{Repo: fakeRepo{}, Trans: fakeTranscriber{}},
+			&usecase.DetectLanguage{},
+			&usecase.Models{Catalog: fakeCatalog{}, Default: "tiny.en"},
+			&testLogger{},
+			Config{MaxUpload: maxUpload, TempDir: t.TempDir(), AudioURL: AudioURLConfig{AllowedHosts: []string{"127.0.0.1"}, Timeout: 200 * time.Millisecond}},
+		)
+	}
+	s := newServer(1 << 20)
+	s.fetch.blocked = func(netip.Addr) bool { return false } // the audio server is on loopback
+	srv := httptest.NewServer(s.httpServer.Handler)
+	defer srv.Close()
+
+	send := func(srv *httptest.Server, audioURL string) (int, responseError) {
+		resp, err := http.PostForm(srv.URL+"/api/transcribe", url.Values{"audio_url": {audioURL}, "lang": {"en"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body responseError
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	for _, c := range []struct {
+		path string
+		want int
+		code herr.Code
+	}{
+		{"/a.wav", http.StatusOK, ""},
+		{"/missing.wav", http.StatusBadRequest, herr.InvalidArgument},
+		{"/away", http.StatusForbidden, herr.PermissionDenied},
+		{"/slow.wav", http.StatusGatewayTimeout, herr.DeadlineExceeded},
+	} {
+		if status, body := send(srv, audio.URL+c.path); status != c.want || body.Code != c.code {
+			t.Errorf("%s: got %d %+v, want %d %s", c.path, status, body, c.want, c.code)
+		}
+	}
+	if status, body := send(srv, "file:///etc/passwd"); status != http.StatusBadRequest {
+		t.Errorf("file URL: got %d %+v", status, body)
+	}
+
+	// The query works as well as the form, and the size limit applies
+	resp, err := http.Post(srv.URL+"/api/transcribe?audio_url="+url.QueryEscape(audio.URL+"/a.wav"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("audio_url in the query: %s", resp.Status)
+	}
+	small := newServer(1000)
+	small.fetch.blocked = s.fetch.blocked
+	smallSrv := httptest.NewServer(small.httpServer.Handler)
+	defer smallSrv.Close()
+	if status, body := send(smallSrv, audio.URL+"/big.wav"); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("too large: got %d %+v", status, body)
+	}
+
+	// Loopback stays off limits, even for an allowed host
+	guarded := newServer(1 << 20)
+	guardedSrv := httptest.NewServer(guarded.httpServer.Handler)
+	defer guardedSrv.Close()
+	if status, body := send(guardedSrv, audio.URL+"/a.wav"); status != http.StatusForbidden || body.Code != herr.PermissionDenied {
+		t.Errorf("loopback: got %d %+v", status, body)
+	}
+}
+
+func TestFetcherCheck(t *testing.T) {
+	f := newFetcher(AudioURLConfig{AllowedHosts: []string{"audio.example.com", "*.cdn.example.net"}})
+	for raw, ok := range map[string]bool{
+		"https://audio.example.com/a.wav":      true,
+		"http://AUDIO.example.com:8080/a.wav":  true,
+		"https://eu.cdn.example.net/a.wav":     true,
+		"https://cdn.example.net/a.wav":        false,
+		"https://evil.com/audio.example.com":   false,
+		"https://audio.example.com.evil.com/":  false,
+		"https://user@audio.example.com/a.wav": false,
+		"ftp://audio.example.com/a.wav":        false,
+		"https://evilcdn.example.net/a.wav":    false,
+	} {
+		u, _ := url.Parse(raw)
+		if err := f.check(u); (err == nil) != ok {
+			t.Errorf("check(%s) = %v, want ok %v", raw, err, ok)
+		}
+	}
+	for addr, blocked := range map[string]bool{
+		"93.184.216.34":   false,
+		"127.0.0.1":       true,
+		"169.254.169.254": true,
+		"10.0.0.1":        true,
+		"::1":             true,
+		"fe80::1":         true,
+		"0.0.0.0":         true,
+	} {
+		if got := blockedAddr(netip.MustParseAddr(addr), false); got != blocked {
+			t.Errorf("blockedAddr(%s) = %v", addr, got)
+		}
+	}
+	if blockedAddr(netip.MustParseAddr("10.0.0.1"), true) {
+		t.Error("private address blocked with AllowPrivate")
+	}
+	if newFetcher(AudioURLConfig{}) != nil {
+		t.Error("audio_url enabled without allowed hosts")
+	}
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	herr "gosper/pkg/errors"
+	"gosper/pkg/metrics"
+)
+
+// Metrics are the server's Prometheus metrics. Besides what the server
+// observes itself, ModelLoaded and CacheLookup record events of the
+// transcriber and model repository.
+type Metrics struct {
+	Registry *metrics.Registry
+
+	requests     *metrics.Counter
+	latency      *metrics.Histogram
+	inFlight     *metrics.Gauge
+	uploadBytes  *metrics.Counter
+	decodeErrors *metrics.Counter
+	processing   *metrics.Histogram
+	audio        *metrics.Counter
+	rtf          *metrics.Histogram
+	modelLoad    *metrics.Histogram
+	cacheLookups *metrics.Counter
+}
+
+// NewMetrics registers the server's metrics with reg.
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	long := metrics.ExponentialBuckets(0.1, 2, 12) // 0.1s to about 3.4 minutes
+	return &Metrics{
+		Registry:     reg,
+		requests:     reg.Counter("gosper_http_requests_total", "HTTP requests by route, method and status.", "route", "method", "status"),
+		latency:      reg.Histogram("gosper_http_request_duration_seconds", "HTTP request latency by route, method and status.", long, "route", "method", "status"),
+		inFlight:     reg.Gauge("gosper_http_requests_in_flight", "HTTP requests being served."),
+		uploadBytes:  reg.Counter("gosper_upload_bytes_total", "Bytes of audio uploaded, by file format.", "format"),
+		decodeErrors: reg.Counter("gosper_audio_decode_errors_total", "Uploads that could not be decoded, by file format and error code.", "format", "code"),
+		processing:   reg.Histogram("gosper_processing_duration_seconds", "Time spent transcribing or detecting the language, by operation and model.", long, "operation", "model"),
+		audio:        reg.Counter("gosper_audio_seconds_total", "Seconds of audio processed, by operation and model.", "operation", "model"),
+		rtf: reg.Histogram("gosper_realtime_factor", "Processing time divided by audio duration, by operation and model; below 1 is faster than real time.",
+			[]float64{0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1, 1.5, 2, 5}, "operation", "model"),
+		modelLoad:    reg.Histogram("gosper_model_load_duration_seconds", "Time to load a model into memory.", long, "model"),
+		cacheLookups: reg.Counter("gosper_model_cache_lookups_total", "Model cache lookups by result (hit or miss).", "model", "result"),
+	}
+}
+
+// ModelLoaded records that loading the model at path took d.
+func (m *Metrics) ModelLoaded(path string, d time.Duration) {
+	m.modelLoad.Observe(d.Seconds(), filepath.Base(path))
+}
+
+// CacheLookup records a model cache lookup.
+func (m *Metrics) CacheLookup(file string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.Inc(file, result)
+}
+
+// observeProcessing records how long an operation on audio of the given
+// length took.
+func (m *Metrics) observeProcessing(op, model string, took, audio time.Duration) {
+	m.processing.Observe(took.Seconds(), op, model)
+	m.audio.Add(audio.Seconds(), op, model)
+	if audio > 0 {
+		m.rtf.Observe(took.Seconds()/audio.Seconds(), op, model)
+	}
+}
+
+// metricsMiddleware counts requests and their latency. Routes the server
+// does not serve are counted as "other", so scanners cannot add labels.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	if s.metrics == nil {
+		return next
+	}
+	m := s.metrics
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if !s.routes[route] {
+			route = "other"
+		}
+		method := r.Method
+		switch method {
+		case http.MethodGet, http.MethodPost, http.MethodOptions, http.MethodHead:
+		default:
+			method = "other"
+		}
+
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		status := strconv.Itoa(rec.code())
+		m.requests.Inc(route, method, status)
+		m.latency.Observe(time.Since(start).Seconds(), route, method, status)
+	})
+}
+
+// knownFormats bounds the format label to the extensions the server
+// handles.
+var knownFormats = map[string]bool{".wav": true, ".mp3": true, ".webm": true, ".ogg": true}
+
+// observeUpload records n bytes of audio uploaded with extension ext.
+func (s *Server) observeUpload(r *http.Request, ext string, n int64) {
+	if s.metrics == nil {
+		return
+	}
+	format := strings.TrimPrefix(ext, ".")
+	if !knownFormats[ext] {
+		format = "other"
+	}
+	stateOf(r).format = format
+	s.metrics.uploadBytes.Add(float64(n), format)
+}
+
+// observeError counts audio that could not be decoded.
+func (s *Server) observeError(r *http.Request, e *herr.Error) {
+	if s.metrics == nil || (e.Code != herr.UnsupportedFormat && e.Code != herr.InvalidAudio) {
+		return
+	}
+	format := stateOf(r).format
+	if format == "" {
+		format = "unknown"
+	}
+	s.metrics.decodeErrors.Inc(format, string(e.Code))
+}
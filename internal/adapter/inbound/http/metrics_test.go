@@ -0,0 +1,136 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gosper/internal/domain"
+	"gosper/internal/usecase"
+	"gosper/pkg/metrics"
+)
+
+type fakeCatalog struct{}
+
+func (fakeCatalog) Catalog(context.Context) ([]domain.ModelInfo, error) {
+	return []domain.ModelInfo{{Name: "tiny.en", File: "ggml-tiny.en.bin"}}, nil
+}
+
+func (fakeCatalog) Resolve(_ context.Context, name string) (domain.ModelInfo, bool, error) {
+	if name == "tiny.en" || name == "ggml-tiny.en.bin" {
+		return domain.ModelInfo{Name: "tiny.en", File: "ggml-tiny.en.bin"}, true, nil
+	}
+	return domain.ModelInfo{}, false, nil
+}
+
+type fakeRepo struct{}
+
+func (fakeRepo) Ensure(_ context.Context, name string) (string, error) { return "/models/" + name, nil }
+
+type fakeTranscriber struct{}
+
+func (fakeTranscriber) Transcribe(context.Context, []float32, domain.ModelConfig) (domain.Transcript, error) {
+	return domain.Transcript{Language: "en", FullText: "hello"}, nil
+}
+
+// wav returns a second of 16 kHz mono silence.
+func wav() []byte {
+	var b bytes.Buffer
+	data := make([]byte, 2*16000)
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(data)))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, struct {
+		Size                      uint32
+		Format, Channels          uint16
+		Rate, ByteRate            uint32
+		BlockAlign, BitsPerSample uint16
+	}{16, 1, 1, 16000, 32000, 2, 16})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+// uploadRequest builds a transcription request for content.
+func uploadRequest(t *testing.T, url, filename string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("audio", filename)
+	fw.Write(content)
+	mw.WriteField("lang", "en")
+	mw.Close()
+	req, err := http.NewRequest(http.MethodPost, url+"/api/transcribe", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func upload(t *testing.T, url, filename string, content []byte) *http.Response {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(uploadRequest(t, url, filename, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	m := NewMetrics(metrics.NewRegistry())
+	s := NewServer(
+		&usecase.TranscribeFile{Repo: fakeRepo{}, Trans: fakeTranscriber{}},
+		&usecase.DetectLanguage{},
+		&usecase.Models{Catalog: fakeCatalog{}, Default: "tiny.en"},
+		&testLogger{},
+		Config{Metrics: m},
+	)
+	srv := httptest.NewServer(s.httpServer.Handler)
+	defer srv.Close()
+
+	if resp := upload(t, srv.URL, "a.wav", wav()); resp.StatusCode != http.StatusOK {
+		t.Fatalf("transcribe: %s", resp.Status)
+	}
+	if resp := upload(t, srv.URL, "b.wav", []byte("not audio")); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("bad audio: %s", resp.Status)
+	}
+	http.Get(srv.URL + "/no/such/path")
+	m.CacheLookup("ggml-tiny.en.bin", true)
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	text := string(b)
+	for _, want := range []string{
+		`gosper_http_requests_total{route="/api/transcribe",method="POST",status="200"} 1`,
+		`gosper_http_requests_total{route="/api/transcribe",method="POST",status="422"} 1`,
+		`gosper_http_requests_total{route="other",method="GET",status="404"} 1`,
+		`gosper_http_request_duration_seconds_count{route="/api/transcribe",method="POST",status="200"} 1`,
+		`gosper_audio_seconds_total{operation="transcribe",model="ggml-tiny.en.bin"} 1`,
+		`gosper_realtime_factor_count{operation="transcribe",model="ggml-tiny.en.bin"} 1`,
+		`gosper_audio_decode_errors_total{format="wav",code="invalid_audio"} 1`,
+		`gosper_upload_bytes_total{format="wav"} 32053`,
+		`gosper_model_cache_lookups_total{model="ggml-tiny.en.bin",result="hit"} 1`,
+		`gosper_http_requests_in_flight 1`, // the scrape itself
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(text)
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"gosper/internal/usecase"
+)
+
+// ParseTrustedProxies parses IP addresses and CIDR prefixes of proxies
+// whose X-Forwarded-For header is believed.
+func ParseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+			}
+			out = append(out, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+		}
+		out = append(out, netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()))
+	}
+	return out, nil
+}
+
+func (s *Server) trusted(a netip.Addr) bool {
+	for _, p := range s.proxies {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that sent r. When the peer
+// is a trusted proxy, X-Forwarded-For is walked from the right, past
+// further trusted proxies, to the first address they did not add; entries
+// left of it could be forged by the client.
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !s.trusted(addr) {
+		return addr.String()
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !s.trusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// rateKey identifies the client of r for rate limiting: its credential
+// when authenticated, its address otherwise.
+func (s *Server) rateKey(r *http.Request) string {
+	if p, ok := PrincipalFrom(r.Context()); ok {
+		return "client:" + p.Method + ":" + p.ID
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+// rateLimitMiddleware refuses requests over the client's rate limits with
+// 429 and reports the request limit in RateLimit-* headers.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	if s.limiter == nil || !s.limiter.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		st, err := s.limiter.Allow(s.rateKey(r))
+		setRateHeaders(w, st, s.limiter)
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setRateHeaders sets the RateLimit-Limit, -Remaining, -Reset and -Policy
+// headers of the IETF httpapi-ratelimit-headers draft.
+func setRateHeaders(w http.ResponseWriter, st usecase.RateStatus, l *usecase.RateLimiter) {
+	var policy []string
+	if l.Requests.N > 0 {
+		policy = append(policy, fmt.Sprintf("%g;w=%d", l.Requests.N, int(l.Requests.Window.Seconds())))
+	}
+	if l.Audio.N > 0 {
+		policy = append(policy, fmt.Sprintf("%g;w=%d;comment=\"audio seconds\"", l.Audio.N, int(l.Audio.Window.Seconds())))
+	}
+	h := w.Header()
+	h.Set("RateLimit-Policy", strings.Join(policy, ", "))
+	if st.Limit > 0 {
+		h.Set("RateLimit-Limit", strconv.Itoa(st.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(st.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(st.Reset.Seconds()))))
+	}
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"gosper/pkg/trace"
+)
+
+// requestIDHeader carries the request ID in both directions: a valid ID
+// sent by the client or a proxy is kept, otherwise one is generated.
+const requestIDHeader = "X-Request-ID"
+
+// requestState is what handlers learn about a request, for the access log
+// line and the metrics recorded when it ends.
+type requestState struct {
+	id        string
+	principal string        // method:id of the authenticated client
+	model     string        // model file the request used
+	format    string        // of the uploaded audio
+	audio     time.Duration // of audio processed
+}
+
+type requestStateKey struct{}
+
+// stateOf returns the state of r, or a throwaway one for requests that did
+// not pass through requestMiddleware.
+func stateOf(r *http.Request) *requestState {
+	if st, ok := r.Context().Value(requestStateKey{}).(*requestState); ok {
+		return st
+	}
+	return &requestState{}
+}
+
+// RequestID returns the ID of the request ctx belongs to, if any.
+func RequestID(ctx context.Context) string {
+	if st, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+		return st.id
+	}
+	return ""
+}
+
+// validRequestID accepts IDs of up to 128 letters, digits and -_.:=+/, which
+// covers UUIDs and the IDs common proxies send, so a client cannot inject
+// anything into logs or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("-_.:=+/", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// statusRecorder remembers the status code and body size written through
+// it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+// code returns the status written, 200 if the handler wrote nothing.
+func (r *statusRecorder) code() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// requestMiddleware assigns each request its ID and logs one access line
+// when it ends.
+func (s *Server) requestMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := &requestState{id: r.Header.Get(requestIDHeader)}
+		if !validRequestID(st.id) {
+			st.id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, st.id)
+		ctx := context.WithValue(r.Context(), requestStateKey{}, st)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		kv := []any{
+			"request_id", st.id,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.code(),
+			"bytes", rec.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote", s.clientIP(r),
+		}
+		if st.principal != "" {
+			kv = append(kv, "principal", st.principal)
+		}
+		if st.model != "" {
+			kv = append(kv, "model", st.model)
+		}
+		if st.audio > 0 {
+			kv = append(kv, "audio_duration_ms", st.audio.Milliseconds())
+		}
+		if sc := trace.FromContext(ctx).SpanContext(); sc.Valid() {
+			kv = append(kv, "trace_id", sc.TraceID.String())
+		}
+		s.logger.Info(ctx, "request", kv...)
+	})
+}
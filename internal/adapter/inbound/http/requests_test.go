@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gosper/internal/domain"
+	"gosper/internal/usecase"
+	herr "gosper/pkg/errors"
+)
+
+// testLogger records what is logged through it.
+type testLogger struct {
+	mu      sync.Mutex
+	records []logRecord
+}
+
+type logRecord struct {
+	level, msg string
+	kv         map[string]any
+}
+
+func (l *testLogger) log(level, msg string, kv []any) {
+	r := logRecord{level: level, msg: msg, kv: map[string]any{}}
+	for i := 0; i+1 < len(kv); i += 2 {
+		r.kv[kv[i].(string)] = kv[i+1]
+	}
+	l.mu.Lock()
+	l.records = append(l.records, r)
+	l.mu.Unlock()
+}
+
+func (l *testLogger) Debug(_ context.Context, msg string, kv ...any) { l.log("debug", msg, kv) }
+func (l *testLogger) Info(_ context.Context, msg string, kv ...any)  { l.log("info", msg, kv) }
+func (l *testLogger) Warn(_ context.Context, msg string, kv ...any)  { l.log("warn", msg, kv) }
+func (l *testLogger) Error(_ context.Context, msg string, kv ...any) { l.log("error", msg, kv) }
+
+func (l *testLogger) find(msg string) []logRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []logRecord
+	for _, r := range l.records {
+		if r.msg == msg {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func TestValidRequestID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"abc-123":                      true,
+		"Root=1-5759e988-bd862e3fe1be": true,
+		"":                             false,
+		"has space":                    false,
+		"line\nbreak":                  false,
+		strings.Repeat("a", 129):       false,
+	} {
+		if got := validRequestID(id); got != want {
+			t.Errorf("validRequestID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestRequestMiddleware(t *testing.T) {
+	logger := &testLogger{}
+	s := NewServer(
+		&usecase.TranscribeFile{Repo: fakeRepo{}, Trans: fakeTranscriber{}},
+		&usecase.DetectLanguage{},
+		&usecase.Models{Catalog: fakeCatalog{}, Default: "tiny.en"},
+		logger,
+		Config{Auth: fakeAuth{}},
+	)
+	srv := httptest.NewServer(s.httpServer.Handler)
+	defer srv.Close()
+
+	// A client's ID is kept and the access line has what the request did
+	req := uploadRequest(t, srv.URL, "a.wav", wav())
+	req.Header.Set("X-API-Key", "secret")
+	req.Header.Set("X-Request-ID", "client-chosen-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Request-ID") != "client-chosen-1" {
+		t.Fatalf("transcribe: %s, X-Request-ID %q", resp.Status, resp.Header.Get("X-Request-ID"))
+	}
+	lines := logger.find("request")
+	if len(lines) != 1 {
+		t.Fatalf("access lines: %+v", lines)
+	}
+	kv := lines[0].kv
+	if kv["request_id"] != "client-chosen-1" || kv["method"] != "POST" || kv["path"] != "/api/transcribe" || kv["status"] != 200 ||
+		kv["principal"] != "api_key:alice" || kv["model"] != "ggml-tiny.en.bin" || kv["audio_duration_ms"] != int64(1000) {
+		t.Errorf("access line %v", kv)
+	}
+	if n, _ := kv["bytes"].(int64); n == 0 {
+		t.Errorf("access line bytes %v", kv["bytes"])
+	}
+
+	// An unusable ID is replaced, and error bodies carry the ID
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/api/models", nil)
+	req.Header.Set("X-Request-ID", "bad id")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body responseError
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	id := resp.Header.Get("X-Request-ID")
+	if resp.StatusCode != http.StatusUnauthorized || len(id) != 32 || body.RequestID != id {
+		t.Fatalf("got %s, X-Request-ID %q, body %+v", resp.Status, id, body)
+	}
+}
+
+// fakeAuth accepts the API key "secret" as alice.
+type fakeAuth struct{}
+
+func (fakeAuth) Authenticate(_ context.Context, cred string) (domain.Principal, error) {
+	if cred != "secret" {
+		return domain.Principal{}, herr.New(herr.Unauthenticated, "invalid API key")
+	}
+	return domain.Principal{ID: "alice", Method: "api_key"}, nil
+}
@@ -4,51 +4,121 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
+	"net/netip"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 
+	"gosper/internal/port"
 	"gosper/internal/usecase"
+	herr "gosper/pkg/errors"
+	"gosper/pkg/trace"
 )
 
-// Logger interface for dependency injection
-type Logger interface {
-	Println(v ...interface{})
-}
-
 // Server handles HTTP requests
 type Server struct {
 	transcribeUC *usecase.TranscribeFile
-	logger       Logger
+	detectUC     *usecase.DetectLanguage
+	modelsUC     *usecase.Models
+	logger       port.Logger
 	httpServer   *http.Server
+	auth         port.Authenticator
+	usage        *usecase.Usage
+	limiter      *usecase.RateLimiter
+	proxies      []netip.Prefix // trusted to set X-Forwarded-For
+	metrics      *Metrics
+	tracer       *trace.Tracer
+	routes       map[string]bool // paths served, for metric labels
+	maxUpload    int64
+	maxAudio     time.Duration
+	tempDir      string
+	fetch        *fetcher // nil when audio_url is disabled
+
+	mu          sync.Mutex
+	ready       bool
+	notReadyMsg string
 }
 
 // Config holds server configuration
 type Config struct {
 	Addr          string
-	ModelDefault  string
 	LanguageDefault string
+
+	// Auth authenticates API requests; nil leaves the API open. Usage,
+	// when set, counts authenticated requests and enforces their quotas.
+	Auth  port.Authenticator
+	Usage *usecase.Usage
+
+	// RateLimit, when set, limits each client's request and audio rate.
+	// Clients are told apart by credential, or else by address; the
+	// X-Forwarded-For header is believed only from TrustedProxies.
+	RateLimit      *usecase.RateLimiter
+	TrustedProxies []netip.Prefix
+
+	CORS CORSConfig
+
+	// Metrics, when set, are recorded and served at /metrics.
+	Metrics *Metrics
+	// Tracer, when set, traces each request; see traceMiddleware.
+	Tracer *trace.Tracer
+
+	// MaxUpload is the largest request body in bytes, and MaxAudioDuration
+	// the longest audio; 0 for no limit. Uploads are kept in TempDir while
+	// processed, os.TempDir() when empty; see PrepareTempDir.
+	MaxUpload        int64
+	MaxAudioDuration time.Duration
+	TempDir          string
+
+	// AudioURL controls fetching audio from audio_url; see AudioURLConfig.
+	AudioURL AudioURLConfig
 }
 
 // NewServer creates a new HTTP server
-func NewServer(transcribeUC *usecase.TranscribeFile, logger Logger, cfg Config) *Server {
+func NewServer(transcribeUC *usecase.TranscribeFile, detectUC *usecase.DetectLanguage, modelsUC *usecase.Models, logger port.Logger, cfg Config) *Server {
 	s := &Server{
 		transcribeUC: transcribeUC,
+		detectUC:     detectUC,
+		modelsUC:     modelsUC,
 		logger:       logger,
+		auth:         cfg.Auth,
+		usage:        cfg.Usage,
+		limiter:      cfg.RateLimit,
+		proxies:      cfg.TrustedProxies,
+		metrics:      cfg.Metrics,
+		tracer:       cfg.Tracer,
+		routes:       map[string]bool{},
+		maxUpload:    cfg.MaxUpload,
+		maxAudio:     cfg.MaxAudioDuration,
+		tempDir:      cfg.TempDir,
+		fetch:        newFetcher(cfg.AudioURL),
+		notReadyMsg:  "starting",
+	}
+	if s.tempDir == "" {
+		s.tempDir = os.TempDir()
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", s.healthHandler)
-	mux.HandleFunc("/api/transcribe", s.transcribeHandler(cfg))
+	handle := func(path string, h http.Handler) {
+		mux.Handle(path, h)
+		s.routes[path] = true
+	}
+	handle("/healthz", http.HandlerFunc(s.healthHandler))
+	handle("/livez", http.HandlerFunc(s.healthHandler))
+	handle("/readyz", http.HandlerFunc(s.readyHandler))
+	handle("/api/transcribe", s.transcribeHandler(cfg))
+	handle("/api/detect-language", s.detectLanguageHandler())
+	handle("/api/models", http.HandlerFunc(s.modelsHandler))
+	handle("/api/models/cache", http.HandlerFunc(s.modelCacheHandler))
+	handle("/api/usage", http.HandlerFunc(s.usageHandler))
+	if cfg.Metrics != nil {
+		handle("/metrics", cfg.Metrics.Registry)
+	}
 
 	s.httpServer = &http.Server{
 		Addr:    cfg.Addr,
-		Handler: corsMiddleware(mux),
+		Handler: s.traceMiddleware(s.requestMiddleware(s.metricsMiddleware(corsMiddleware(cfg.CORS, s.authMiddleware(s.rateLimitMiddleware(s.quotaMiddleware(mux))))))),
 	}
 
 	return s
@@ -56,30 +126,57 @@ func NewServer(transcribeUC *usecase.TranscribeFile, logger Logger, cfg Config)
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	s.logger.Println("HTTP server listening on", s.httpServer.Addr)
+	s.logger.Info(context.Background(), "HTTP server listening", "addr", s.httpServer.Addr)
 	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// SetReady marks the server as ready to receive traffic, or as not ready
+// with a reason that /readyz reports. /readyz is public, so the reason is a
+// fixed status such as "loading models", never an error text; log the
+// detail instead. A new server is not ready.
+func (s *Server) SetReady(ready bool, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ready = ready
+	s.notReadyMsg = reason
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
-	s.logger.Println("Shutting down HTTP server")
+	s.logger.Info(ctx, "shutting down HTTP server")
+	s.SetReady(false, "shutting down")
 	return s.httpServer.Shutdown(ctx)
 }
 
-// healthHandler handles health check requests
+// healthHandler handles liveness checks: the process is up and serving
+// HTTP, whether or not models are ready
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
 }
 
+// readyHandler handles readiness checks: 200 once models are loaded, 503
+// with the reason before then and while shutting down
+func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	ready, reason := s.ready, s.notReadyMsg
+	s.mu.Unlock()
+	if !ready {
+		s.clientError(w, r, herr.Unavailable, "not ready: "+reason)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
 // transcribeHandler handles transcription requests
 func (s *Server) transcribeHandler(cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			s.clientError(w, r, http.StatusMethodNotAllowed, "POST required")
+			s.clientError(w, r, herr.MethodNotAllowed, "POST required")
 			return
 		}
 
@@ -89,185 +186,301 @@ func (s *Server) transcribeHandler(cfg Config) http.HandlerFunc {
 		}
 		defer cleanup()
 
-		modelName := r.FormValue("model")
-		if modelName == "" {
-			modelName = cfg.ModelDefault
+		modelName, ok := s.checkModel(w, r)
+		if !ok {
+			return
 		}
 		lang := r.FormValue("lang")
 		if lang == "" {
 			lang = cfg.LanguageDefault
 		}
 
+		timestamps, _ := strconv.ParseBool(r.FormValue("timestamps"))
+		dropLowConf, _ := strconv.ParseBool(r.FormValue("drop_low_confidence"))
+
+		in := usecase.TranscribeInput{
+			Path:       tmp.Name(),
+			ModelName:  modelName,
+			Language:   lang,
+			Timestamps: timestamps,
+
+			MinAvgLogProb:       usecase.DefaultMinAvgLogProb,
+			MaxNoSpeechProb:     usecase.DefaultMaxNoSpeechProb,
+			MaxCompressionRatio: usecase.DefaultMaxCompressionRatio,
+			DropLowConfidence:   dropLowConf,
+
+			Filters: usecase.Filters{
+				Duplicates:     formBool(r, "drop_duplicates", usecase.DefaultFilters.Duplicates),
+				NgramLoops:     formBool(r, "collapse_loops", usecase.DefaultFilters.NgramLoops),
+				Hallucinations: formBool(r, "drop_hallucinations", usecase.DefaultFilters.Hallucinations),
+				ZeroDuration:   formBool(r, "drop_zero_duration", usecase.DefaultFilters.ZeroDuration),
+			},
+
+			MaxDuration: s.maxAudio,
+		}
+		if err := parseDecodingForm(r, &in); err != nil {
+			s.clientError(w, r, herr.InvalidArgument, err.Error())
+			return
+		}
+
 		start := time.Now()
-		tr, trErr := s.transcribeUC.Execute(r.Context(), usecase.TranscribeInput{
-			Path:      tmp.Name(),
-			ModelName: modelName,
-			Language:  lang,
-		})
+		tr, trErr := s.transcribeUC.Execute(r.Context(), in)
 		dur := time.Since(start)
 		if trErr != nil {
 			s.serverError(w, r, trErr)
 			return
 		}
+		s.recordAudio(r, tr.Duration)
+		if s.metrics != nil {
+			s.metrics.observeProcessing("transcribe", modelName, dur, tr.Duration)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]any{
-			"language":    tr.Language,
-			"text":        tr.FullText,
-			"segments":    tr.Segments,
-			"duration_ms": dur.Milliseconds(),
+			"language":               tr.Language,
+			"language_probabilities": tr.LanguageProbs,
+			"text":                   tr.FullText,
+			"segments":               tr.Segments,
+			"removed":                tr.Removed,
+			"audio_duration_ms":      tr.Duration.Milliseconds(),
+			"duration_ms":            dur.Milliseconds(),
 		})
 	}
 }
 
-// handleFileUpload processes multipart file upload
-func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) (*os.File, func(), error) {
-	if err := r.ParseMultipartForm(100 << 20); err != nil { // 100MB
-		s.clientError(w, r, http.StatusBadRequest, fmt.Sprintf("parse form: %v", err))
-		return nil, nil, err
-	}
-	file, header, err := r.FormFile("audio")
-	if err != nil {
-		s.clientError(w, r, http.StatusBadRequest, "missing file field 'audio'")
-		return nil, nil, err
-	}
-	defer file.Close()
+// detectLanguageHandler identifies the spoken language of an upload using
+// only its first 30 seconds
+func (s *Server) detectLanguageHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			s.clientError(w, r, herr.MethodNotAllowed, "POST required")
+			return
+		}
 
-	// Persist to temp file for decoder compatibility
-	tmpDir := os.TempDir()
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		ext = ".wav"
-	}
+		tmp, cleanup, err := s.handleFileUpload(w, r)
+		if err != nil {
+			return
+		}
+		defer cleanup()
 
-	// Normalize extension
-	ext = strings.ToLower(ext)
+		modelName, ok := s.checkModel(w, r)
+		if !ok {
+			return
+		}
 
-	tmp, err := os.CreateTemp(tmpDir, "upload-*"+ext)
-	if err != nil {
-		s.serverError(w, r, fmt.Errorf("tmp: %v", err))
-		return nil, nil, err
+		start := time.Now()
+		det, detErr := s.detectUC.Execute(r.Context(), usecase.DetectInput{
+			Path:        tmp.Name(),
+			ModelName:   modelName,
+			MaxDuration: s.maxAudio,
+		})
+		dur := time.Since(start)
+		if detErr != nil {
+			s.serverError(w, r, detErr)
+			return
+		}
+		s.recordAudio(r, det.Duration)
+		if s.metrics != nil {
+			s.metrics.observeProcessing("detect_language", modelName, dur, det.Duration)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"language":      det.Language,
+			"probabilities": det.Probabilities,
+			"duration_ms":   dur.Milliseconds(),
+		})
 	}
-	cleanup := func() {
-		tmp.Close()
-		os.Remove(tmp.Name())
+}
+
+// checkModel validates the "model" form field against the models clients
+// may use, writing a 400 for unknown or disallowed models
+func (s *Server) checkModel(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name, err := s.modelsUC.Check(r.Context(), r.FormValue("model"))
+	if err != nil {
+		s.serverError(w, r, err)
+		return "", false
 	}
+	stateOf(r).model = name
+	return name, true
+}
 
-	if _, err := io.Copy(tmp, file); err != nil {
-		cleanup()
-		s.serverError(w, r, fmt.Errorf("write: %v", err))
-		return nil, nil, err
+// modelsHandler lists the models clients may request
+func (s *Server) modelsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.clientError(w, r, herr.MethodNotAllowed, "GET required")
+		return
 	}
-	if _, err := tmp.Seek(0, 0); err != nil {
-		cleanup()
-		s.serverError(w, r, fmt.Errorf("seek: %v", err))
-		return nil, nil, err
+	list, err := s.modelsUC.List(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
 	}
 
-	// Convert WebM to WAV if needed
-	if ext == ".webm" || ext == ".ogg" {
-		wavTmp, convertErr := s.convertToWAV(tmp.Name())
-		if convertErr != nil {
-			cleanup()
-			s.serverError(w, r, fmt.Errorf("convert to wav: %v", convertErr))
-			return nil, nil, convertErr
-		}
-
-		// Clean up original file, return converted WAV
-		cleanup()
-		newCleanup := func() {
-			wavTmp.Close()
-			os.Remove(wavTmp.Name())
+	def := ""
+	models := make([]map[string]any, 0, len(list))
+	for _, m := range list {
+		if m.Default {
+			def = m.Name
 		}
-		return wavTmp, newCleanup, nil
+		models = append(models, map[string]any{
+			"name":         m.Name,
+			"file":         m.File,
+			"size":         m.Size,
+			"quantization": m.Quantization,
+			"multilingual": m.Multilingual,
+			"cached":       m.Cached,
+			"loaded":       m.Loaded,
+			"default":      m.Default,
+		})
 	}
-
-	return tmp, cleanup, nil
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"default": def,
+		"models":  models,
+	})
 }
 
-// convertToWAV converts an audio file to WAV format using ffmpeg
-func (s *Server) convertToWAV(inputPath string) (*os.File, error) {
-	tmpDir := os.TempDir()
-	wavFile, err := os.CreateTemp(tmpDir, "converted-*.wav")
-	if err != nil {
-		return nil, fmt.Errorf("create temp wav: %v", err)
+// modelCacheHandler reports model cache usage and quota
+func (s *Server) modelCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.clientError(w, r, herr.MethodNotAllowed, "GET required")
+		return
 	}
-	wavFile.Close() // ffmpeg will write to it
-
-	// Convert to 16kHz mono WAV (whisper requirement)
-	cmd := exec.Command("ffmpeg",
-		"-i", inputPath,
-		"-ar", "16000",
-		"-ac", "1",
-		"-c:a", "pcm_s16le",
-		"-y", // overwrite
-		wavFile.Name(),
-	)
-
-	output, err := cmd.CombinedOutput()
+	u, ok, err := s.modelsUC.CacheUsage(r.Context())
 	if err != nil {
-		os.Remove(wavFile.Name())
-		return nil, fmt.Errorf("ffmpeg: %v, output: %s", err, string(output))
+		s.serverError(w, r, err)
+		return
 	}
-
-	// Reopen for reading
-	f, err := os.Open(wavFile.Name())
-	if err != nil {
-		os.Remove(wavFile.Name())
-		return nil, fmt.Errorf("reopen wav: %v", err)
+	if !ok {
+		s.clientError(w, r, herr.NotFound, "no model cache configured")
+		return
 	}
 
-	return f, nil
+	models := make([]map[string]any, 0, len(u.Models))
+	for _, m := range u.Models {
+		models = append(models, map[string]any{
+			"name":      m.Name,
+			"file":      m.File,
+			"size":      m.Size,
+			"last_used": m.LastUsed,
+			"pinned":    m.Pinned,
+			"in_use":    m.InUse,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"used_bytes":  u.UsedBytes,
+		"quota_bytes": u.QuotaBytes,
+		"models":      models,
+	})
 }
 
-// corsMiddleware adds CORS headers to allow cross-origin requests
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow requests from any origin (adjust for production)
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		// Handle preflight requests
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+// parseDecodingForm fills the optional decoding parameters and confidence
+// thresholds of in from the form, keeping the values already in in for
+// absent fields. Unlike the lenient helpers below, malformed values are
+// reported so the client learns its setting was not applied; ranges are
+// validated by the use case.
+func parseDecodingForm(r *http.Request, in *usecase.TranscribeInput) error {
+	floats := []struct {
+		key string
+		dst *float32
+	}{
+		{"temperature", &in.Temperature},
+		{"temperature_inc", &in.TemperatureInc},
+		{"entropy_threshold", &in.EntropyThreshold},
+		{"logprob_threshold", &in.LogProbThreshold},
+		{"no_speech_threshold", &in.NoSpeechThreshold},
+		{"min_logprob", &in.MinAvgLogProb},
+		{"max_no_speech", &in.MaxNoSpeechProb},
+		{"max_compression_ratio", &in.MaxCompressionRatio},
+	}
+	for _, f := range floats {
+		if v := r.FormValue(f.key); v != "" {
+			x, err := strconv.ParseFloat(v, 32)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", f.key, v)
+			}
+			*f.dst = float32(x)
 		}
+	}
+	if v := r.FormValue("best_of"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid best_of: %q", v)
+		}
+		in.BestOf = n
+	}
+	if v := r.FormValue("max_segment_length"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid max_segment_length: %q", v)
+		}
+		in.MaxSegmentLength = uint(n)
+	}
+	if v := r.FormValue("suppress_blank"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid suppress_blank: %q", v)
+		}
+		in.AllowBlank = !b
+	}
+	if v := r.FormValue("split_on_word"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid split_on_word: %q", v)
+		}
+		in.SplitOnWord = b
+	}
+	in.SuppressRegex = r.FormValue("suppress_regex")
+	return nil
+}
 
-		next.ServeHTTP(w, r)
-	})
+// formBool reads an optional boolean form field, falling back to def when
+// the field is absent or malformed.
+func formBool(r *http.Request, key string, def bool) bool {
+	b, err := strconv.ParseBool(r.FormValue(key))
+	if err != nil {
+		return def
+	}
+	return b
 }
 
 // Error handling
 
+// responseError is the JSON body of every error response. Error is the
+// message, kept a plain string for older clients; Code is stable and meant
+// for programs; RequestID matches the X-Request-ID header and the logs.
 type responseError struct {
-	Error string `json:"error"`
+	Error     string         `json:"error"`
+	Code      herr.Code      `json:"code"`
+	Details   map[string]any `json:"details,omitempty"`
+	Retryable bool           `json:"retryable"`
+	RequestID string         `json:"request_id,omitempty"`
 }
 
-func (s *Server) errorResponse(w http.ResponseWriter, r *http.Request, status int, message string) {
-	env := responseError{Error: message}
+func (s *Server) errorResponse(w http.ResponseWriter, r *http.Request, e *herr.Error) {
+	s.observeError(r, e)
+	env := responseError{Error: e.PublicMessage(), Code: e.Code, Details: e.Details, Retryable: e.Retryable, RequestID: RequestID(r.Context())}
+	if secs, ok := e.Details["retry_after"].(int); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+	w.WriteHeader(e.HTTPStatus())
 	_ = json.NewEncoder(w).Encode(env)
 }
 
+// serverError writes err with the status of its classification, logging
+// the full error for server-side faults, whose details clients do not see.
 func (s *Server) serverError(w http.ResponseWriter, r *http.Request, err error) {
-	s.logger.Println(r.Method, r.URL.Path, r.RemoteAddr, "error:", err)
-	message := "the server encountered a problem and could not process your request"
-	s.errorResponse(w, r, http.StatusInternalServerError, message)
-}
-
-func (s *Server) clientError(w http.ResponseWriter, r *http.Request, status int, message string) {
-	s.errorResponse(w, r, status, message)
-}
-
-// Default logger implementation
-type DefaultLogger struct {
-	*log.Logger
+	e := herr.From(err)
+	if e.HTTPStatus() >= http.StatusInternalServerError {
+		s.logger.Error(r.Context(), "request failed", "request_id", RequestID(r.Context()),
+			"method", r.Method, "path", r.URL.Path, "client", clientID(r), "error", err)
+	}
+	s.errorResponse(w, r, e)
 }
 
-func NewDefaultLogger() *DefaultLogger {
-	return &DefaultLogger{
-		Logger: log.New(os.Stdout, "[HTTP] ", log.LstdFlags),
-	}
+func (s *Server) clientError(w http.ResponseWriter, r *http.Request, code herr.Code, message string) {
+	s.errorResponse(w, r, herr.New(code, message))
 }
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gosper/internal/domain"
+	"gosper/internal/usecase"
+)
+
+type fakeCache struct{}
+
+func (fakeCache) CacheUsage(context.Context) (domain.CacheUsage, error) {
+	return domain.CacheUsage{Dir: "/home/svc/.cache/gosper/models", UsedBytes: 10, Models: []domain.CachedModel{{Name: "tiny.en", Size: 10}}}, nil
+}
+
+func TestModelCacheHandler(t *testing.T) {
+	s := NewServer(
+		&usecase.TranscribeFile{},
+		&usecase.DetectLanguage{},
+		&usecase.Models{Catalog: fakeCatalog{}, Cache: fakeCache{}, Default: "tiny.en"},
+		&testLogger{},
+		Config{},
+	)
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/models/cache", nil))
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("got %d, %v", rec.Code, err)
+	}
+	if _, ok := body["dir"]; ok {
+		t.Errorf("cache directory exposed: %v", body)
+	}
+	if body["used_bytes"] != 10.0 || len(body["models"].([]any)) != 1 {
+		t.Errorf("unexpected usage: %v", body)
+	}
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+
+	"gosper/pkg/trace"
+)
+
+// traceMiddleware starts a server span for each request, continuing the
+// caller's trace when the request carries a W3C traceparent header.
+// Handlers and the use cases they call add child spans to it.
+func (s *Server) traceMiddleware(next http.Handler) http.Handler {
+	if s.tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, ok := trace.ParseTraceparent(r.Header.Get("Traceparent")); ok {
+			ctx = trace.ContextWithRemote(ctx, sc)
+		}
+		route := r.URL.Path
+		if !s.routes[route] {
+			route = "other"
+		}
+		ctx, span := s.tracer.Start(ctx, r.Method+" "+route,
+			trace.String("http.request.method", r.Method),
+			trace.String("url.path", r.URL.Path),
+			trace.String("user_agent.original", r.UserAgent()),
+		)
+		if route != "other" {
+			span.SetAttrs(trace.String("http.route", route))
+		}
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttrs(trace.Int("http.response.status_code", int64(rec.code())))
+		if rec.code() >= 500 {
+			span.Fail(errStatus(rec.code()))
+		}
+	})
+}
+
+// errStatus is the span error of a request answered with a server error.
+type errStatus int
+
+func (e errStatus) Error() string { return http.StatusText(int(e)) }
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gosper/internal/usecase"
+	"gosper/pkg/trace"
+)
+
+func TestTraceMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	s := NewServer(
+		&usecase.TranscribeFile{Repo: fakeRepo{}, Trans: fakeTranscriber{}},
+		&usecase.DetectLanguage{},
+		&usecase.Models{Catalog: fakeCatalog{}, Default: "tiny.en"},
+		&testLogger{},
+		Config{Tracer: &trace.Tracer{Exporter: &trace.WriterExporter{W: &out}}}, // sample only continued traces
+	)
+	srv := httptest.NewServer(s.httpServer.Handler)
+	defer srv.Close()
+
+	req := uploadRequest(t, srv.URL, "a.wav", wav())
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("transcribe: %s", resp.Status)
+	}
+	if resp, err := http.Get(srv.URL + "/livez"); err == nil { // new trace, not sampled
+		resp.Body.Close()
+	}
+
+	type span struct {
+		TraceID, SpanID, ParentSpanID, Name string
+	}
+	byName := map[string]span{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var sp span
+		if err := json.Unmarshal([]byte(line), &sp); err != nil {
+			t.Fatalf("%v: %s", err, line)
+		}
+		if sp.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %s in trace %s", sp.Name, sp.TraceID)
+		}
+		byName[sp.Name] = sp
+	}
+	parents := map[string]string{
+		"POST /api/transcribe":   "",
+		"http.upload":            "POST /api/transcribe",
+		"TranscribeFile.Execute": "POST /api/transcribe",
+		"audio.decode":           "TranscribeFile.Execute",
+		"audio.resample":         "TranscribeFile.Execute",
+		"model.ensure":           "TranscribeFile.Execute",
+	}
+	if len(byName) != len(parents) {
+		t.Errorf("got spans %v", byName)
+	}
+	for name, parent := range parents {
+		sp, ok := byName[name]
+		want := "00f067aa0ba902b7"
+		if parent != "" {
+			want = byName[parent].SpanID
+		}
+		if !ok || sp.ParentSpanID != want {
+			t.Errorf("span %s: %+v, want parent %s", name, sp, parent)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	herr "gosper/pkg/errors"
+	"gosper/pkg/trace"
+)
+
+// mediaTypes maps the media types clients send for audio to the extension
+// of the format.
+var mediaTypes = map[string]string{
+	"audio/wav":       ".wav",
+	"audio/x-wav":     ".wav",
+	"audio/wave":      ".wav",
+	"audio/vnd.wave":  ".wav",
+	"audio/mpeg":      ".mp3",
+	"audio/mp3":       ".mp3",
+	"audio/ogg":       ".ogg",
+	"application/ogg": ".ogg",
+	"audio/webm":      ".webm",
+	"video/webm":      ".webm",
+}
+
+// maxFormBytes bounds the form fields sent with an upload, which are kept
+// in memory.
+const maxFormBytes = 1 << 20
+
+// tempPrefixes start the names of the files the server keeps in its temp
+// directory.
+var tempPrefixes = []string{"upload-", "converted-"}
+
+// PrepareTempDir creates dir for Config.TempDir and removes the uploads an
+// earlier run left in it, as after a crash, returning how many it removed.
+// The directory must not be shared with another running server.
+func PrepareTempDir(dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if e.IsDir() || !hasTempPrefix(e.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func hasTempPrefix(name string) bool {
+	for _, p := range tempPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleFileUpload saves the audio of a request to the temp directory,
+// converting WebM and Ogg to WAV; see receiveUpload for where it may come
+// from. The body is streamed, never buffered whole, and refused with 413
+// once it passes the upload limit. On error the response is written.
+func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) (*os.File, func(), error) {
+	f, err := s.receiveUpload(w, r)
+	if err != nil {
+		s.serverError(w, r, err)
+		return nil, nil, err
+	}
+
+	// Convert WebM and Ogg to WAV
+	if ext := filepath.Ext(f.Name()); ext == ".webm" || ext == ".ogg" {
+		wav, err := s.convertToWAV(r.Context(), f.Name())
+		removeTemp(f)
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				s.serverError(w, r, fmt.Errorf("convert to wav: %w", err))
+			} else {
+				s.logger.Warn(r.Context(), "convert to wav", "request_id", RequestID(r.Context()), "format", ext, "error", err)
+				s.clientError(w, r, herr.InvalidAudio, "could not decode "+ext+" audio")
+			}
+			return nil, nil, err
+		}
+		f = wav
+	}
+	return f, func() { removeTemp(f) }, nil
+}
+
+// receiveUpload saves the audio of r: the "audio" file of a multipart
+// form, a body sent with an audio media type, or else the audio at the
+// audio_url field or query parameter. Options are read into r.Form.
+func (s *Server) receiveUpload(w http.ResponseWriter, r *http.Request) (_ *os.File, err error) {
+	ctx, span := trace.Start(r.Context(), "http.upload")
+	defer func() {
+		span.Fail(err)
+		span.End()
+	}()
+	if err := s.limitBody(w, r); err != nil {
+		return nil, err
+	}
+
+	var audio *os.File
+	var n int64
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mt == "multipart/form-data":
+		audio, n, err = s.receiveMultipart(r)
+	case mt == "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, s.bodyError(err)
+		}
+	case r.URL.Query().Get("audio_url") != "":
+		setForm(r, url.Values{}) // the body is not read
+	case mediaTypes[mt] != "", mt == "application/octet-stream", mt == "" && r.ContentLength != 0:
+		setForm(r, url.Values{})
+		audio, n, err = s.saveAudio(r, r.Body, mediaTypes[mt])
+	case strings.HasPrefix(mt, "audio/"):
+		return nil, herr.Newf(herr.UnsupportedFormat, "unsupported audio format %s", mt)
+	default:
+		return nil, herr.Newf(herr.UnsupportedFormat, "unsupported content type %q; send multipart/form-data, an audio body or audio_url", mt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u := r.FormValue("audio_url"); u != "" {
+		if audio != nil {
+			removeTemp(audio)
+			return nil, herr.New(herr.InvalidArgument, "send either an audio file or audio_url, not both")
+		}
+		if audio, n, err = s.fetchAudio(ctx, r, u); err != nil {
+			return nil, err
+		}
+	}
+	if audio == nil {
+		return nil, herr.New(herr.InvalidArgument, "missing file field 'audio'")
+	}
+	span.SetAttrs(trace.String("audio.format", strings.TrimPrefix(filepath.Ext(audio.Name()), ".")), trace.Int("http.request.body.size", n))
+	return audio, nil
+}
+
+// receiveMultipart reads a multipart form, saving its "audio" file, if
+// any, and returning it with its size.
+func (s *Server) receiveMultipart(r *http.Request) (_ *os.File, _ int64, err error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, 0, herr.Newf(herr.InvalidArgument, "parse form: %v", err)
+	}
+	var audio *os.File
+	var n int64
+	defer func() {
+		if err != nil && audio != nil {
+			removeTemp(audio)
+		}
+	}()
+	form := url.Values{}
+	size := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, s.bodyError(err)
+		}
+		name := part.FormName()
+		switch {
+		case name == "audio" && part.FileName() != "":
+			if audio != nil {
+				return nil, 0, herr.New(herr.InvalidArgument, "more than one file field 'audio'")
+			}
+			audio, n, err = s.saveAudio(r, part, declaredFormat(part.FileName(), part.Header.Get("Content-Type")))
+			if err != nil {
+				return nil, 0, err
+			}
+		case name != "":
+			v, err := io.ReadAll(io.LimitReader(part, int64(maxFormBytes-size+1)))
+			if err != nil {
+				return nil, 0, s.bodyError(err)
+			}
+			if size += len(v); size > maxFormBytes {
+				return nil, 0, herr.Newf(herr.PayloadTooLarge, "form fields are larger than %d bytes", maxFormBytes)
+			}
+			form.Add(name, string(v))
+		}
+	}
+	setForm(r, form)
+	return audio, n, nil
+}
+
+// limitBody refuses a body declared larger than the upload limit and
+// makes reading past the limit fail, so that it is never read whole.
+func (s *Server) limitBody(w http.ResponseWriter, r *http.Request) error {
+	if s.maxUpload <= 0 {
+		return nil
+	}
+	if r.ContentLength > s.maxUpload {
+		return tooLarge(s.maxUpload)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	return nil
+}
+
+func tooLarge(limit int64) error {
+	return herr.Newf(herr.PayloadTooLarge, "request body is larger than %d bytes", limit).WithDetail("limit_bytes", limit)
+}
+
+// bodyError classifies a failure to read the request body.
+func (s *Server) bodyError(err error) error {
+	var max *http.MaxBytesError
+	if errors.As(err, &max) {
+		return tooLarge(max.Limit)
+	}
+	return herr.Newf(herr.InvalidArgument, "read audio: %w", err)
+}
+
+// setForm makes the fields read from a streamed body available through
+// r.FormValue, ahead of the query parameters as ParseForm would.
+func setForm(r *http.Request, form url.Values) {
+	r.PostForm = form
+	r.Form = url.Values{}
+	for k, v := range form {
+		r.Form[k] = append(r.Form[k], v...)
+	}
+	for k, v := range r.URL.Query() {
+		r.Form[k] = append(r.Form[k], v...)
+	}
+}
+
+// saveAudio copies audio from src to a new file in the temp directory,
+// after checking that it starts like the declared format; with none
+// declared, the format is taken from the content. It returns the file and
+// its size.
+func (s *Server) saveAudio(r *http.Request, src io.Reader, declared string) (*os.File, int64, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, 0, s.bodyError(err)
+	}
+	head = head[:n]
+	ext, err := checkFormat(declared, sniffFormat(head))
+	if err != nil {
+		s.observeUpload(r, ext, int64(n))
+		return nil, 0, err
+	}
+
+	tmp, err := os.CreateTemp(s.tempDir, "upload-*"+ext)
+	if err != nil {
+		return nil, 0, herr.Wrap(herr.FsError, fmt.Errorf("tmp: %w", err))
+	}
+	written, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(head), src))
+	s.observeUpload(r, ext, written)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeTemp(tmp)
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, 0, herr.Wrap(herr.FsError, fmt.Errorf("write: %w", err))
+		}
+		return nil, 0, s.bodyError(err)
+	}
+	return tmp, written, nil
+}
+
+func removeTemp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// declaredFormat is the audio format a client gave for an upload: the
+// extension of its file name, or else its media type; "" for neither.
+func declaredFormat(filename, contentType string) string {
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" {
+		return ext
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mediaTypes[mt]
+}
+
+// sniffLen is how much of a file sniffFormat needs.
+const sniffLen = 12
+
+// sniffFormat returns the extension of the format whose signature head
+// starts with, or "" for none the server accepts.
+func sniffFormat(head []byte) string {
+	switch {
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return ".wav"
+	case bytes.HasPrefix(head, []byte("ID3")), len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0: // ID3 tag or frame sync
+		return ".mp3"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return ".ogg"
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}): // EBML
+		return ".webm"
+	}
+	return ""
+}
+
+// checkFormat returns the format of an upload from the one declared and
+// the one sniffed, refusing formats the server does not accept and content
+// that does not match its declared format.
+func checkFormat(declared, sniffed string) (string, error) {
+	switch {
+	case declared == "" && sniffed == "":
+		return "", herr.New(herr.UnsupportedFormat, "unrecognized audio format; send WAV, MP3, Ogg or WebM")
+	case declared == "":
+		return sniffed, nil
+	case !knownFormats[declared]:
+		return declared, herr.Newf(herr.UnsupportedFormat, "unsupported audio format %s", declared)
+	case sniffed != declared:
+		return declared, herr.Newf(herr.InvalidAudio, "content is not %s audio", strings.TrimPrefix(declared, "."))
+	}
+	return declared, nil
+}
+
+// convertToWAV converts an audio file to WAV format using ffmpeg
+func (s *Server) convertToWAV(ctx context.Context, inputPath string) (_ *os.File, err error) {
+	ctx, span := trace.Start(ctx, "ffmpeg.convert", trace.String("audio.format", strings.TrimPrefix(filepath.Ext(inputPath), ".")))
+	defer func() {
+		span.Fail(err)
+		span.End()
+	}()
+
+	wavFile, err := os.CreateTemp(s.tempDir, "converted-*.wav")
+	if err != nil {
+		return nil, fmt.Errorf("create temp wav: %v", err)
+	}
+	wavFile.Close() // ffmpeg will write to it
+
+	// Convert to 16kHz mono WAV (whisper requirement)
+	args := []string{
+		"-i", inputPath,
+		"-ar", "16000",
+		"-ac", "1",
+		"-c:a", "pcm_s16le",
+	}
+	if s.maxAudio > 0 {
+		// Stop just past the limit: the use case refuses the result, and
+		// overlong audio is never converted whole
+		args = append(args, "-t", strconv.FormatFloat((s.maxAudio+time.Second).Seconds(), 'f', -1, 64))
+	}
+	args = append(args, "-y", wavFile.Name()) // overwrite
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(wavFile.Name())
+		return nil, fmt.Errorf("ffmpeg: %w, output: %s", err, string(output))
+	}
+
+	// Reopen for reading
+	f, err := os.Open(wavFile.Name())
+	if err != nil {
+		os.Remove(wavFile.Name())
+		return nil, fmt.Errorf("reopen wav: %v", err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gosper/internal/usecase"
+	herr "gosper/pkg/errors"
+)
+
+// uploadServer returns a server with the given upload limits that keeps
+// uploads in a directory of its own.
+func uploadServer(t testing.TB, maxUpload int64, maxAudio time.Duration) (*Server, string) {
+	dir := t.TempDir()
+	return NewServer(
+		&usecase.TranscribeFile{Repo: fakeRepo{}, Trans: fakeTranscriber{}},
+		&usecase.DetectLanguage{},
+		&usecase.Models{Catalog: fakeCatalog{}, Default: "tiny.en"},
+		&testLogger{},
+		Config{MaxUpload: maxUpload, MaxAudioDuration: maxAudio, TempDir: dir},
+	), dir
+}
+
+func TestCheckFormat(t *testing.T) {
+	mp3 := []byte("ID3\x04\x00\x00\x00\x00\x00\x00\x00\x00")
+	for _, c := range []struct {
+		declared string
+		head     []byte
+		want     string
+		code     herr.Code
+	}{
+		{".wav", wav(), ".wav", ""},
+		{"", wav(), ".wav", ""},
+		{"", mp3, ".mp3", ""},
+		{".mp3", []byte{0xFF, 0xFB, 0x90, 0x00}, ".mp3", ""},
+		{".ogg", []byte("OggS\x00\x02"), ".ogg", ""},
+		{".webm", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F}, ".webm", ""},
+		{".mp3", wav(), ".mp3", herr.InvalidAudio},
+		{".wav", []byte("RIFF\x00\x00\x00\x00AVI "), ".wav", herr.InvalidAudio},
+		{".flac", []byte("fLaC"), ".flac", herr.UnsupportedFormat},
+		{"", []byte("#!/bin/sh"), "", herr.UnsupportedFormat},
+		{"", nil, "", herr.UnsupportedFormat},
+	} {
+		head := c.head
+		if len(head) > sniffLen {
+			head = head[:sniffLen]
+		}
+		got, err := checkFormat(c.declared, sniffFormat(head))
+		if got != c.want || (err == nil) != (c.code == "") || (err != nil && herr.From(err).Code != c.code) {
+			t.Errorf("checkFormat(%q, %q) = %q, %v; want %q, %s", c.declared, head, got, err, c.want, c.code)
+		}
+	}
+	if got := declaredFormat("blob", "audio/webm;codecs=opus"); got != ".webm" {
+		t.Errorf("declaredFormat by media type = %q", got)
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	s, dir := uploadServer(t, 20000, 500*time.Millisecond)
+	srv := httptest.NewServer(s.httpServer.Handler)
+	defer srv.Close()
+
+	short := shortWAV(250)
+	chunked := uploadRequest(t, srv.URL, "a.wav", wav())
+	chunked.Body = io.NopCloser(chunked.Body) // hide the length
+	chunked.ContentLength = -1
+
+	for _, c := range []struct {
+		name string
+		req  *http.Request
+		want int
+		code herr.Code
+	}{
+		{"ok", uploadRequest(t, srv.URL, "a.wav", short), http.StatusOK, ""},
+		{"sniffed", uploadRequest(t, srv.URL, "recording", short), http.StatusOK, ""},
+		{"declared too large", uploadRequest(t, srv.URL, "a.wav", wav()), http.StatusRequestEntityTooLarge, herr.PayloadTooLarge},
+		{"streamed too large", chunked, http.StatusRequestEntityTooLarge, herr.PayloadTooLarge},
+		{"too long", uploadRequest(t, srv.URL, "a.wav", shortWAV(600)), http.StatusRequestEntityTooLarge, herr.PayloadTooLarge},
+		{"mismatch", uploadRequest(t, srv.URL, "a.mp3", short), http.StatusUnprocessableEntity, herr.InvalidAudio},
+		{"unsupported", uploadRequest(t, srv.URL, "a.flac", []byte("fLaC\x00\x00\x00\x22")), http.StatusUnsupportedMediaType, herr.UnsupportedFormat},
+	} {
+		resp, err := http.DefaultClient.Do(c.req)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var body responseError
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != c.want || body.Code != c.code {
+			t.Errorf("%s: got %s %+v, want %d %s", c.name, resp.Status, body, c.want, c.code)
+		}
+		if left, _ := os.ReadDir(dir); len(left) != 0 {
+			t.Errorf("%s: left %v in the temp dir", c.name, left)
+		}
+	}
+}
+
+// shortWAV returns ms milliseconds of 16 kHz mono silence, up to a second.
+func shortWAV(ms int) []byte {
+	n := 32 * ms
+	b := append([]byte(nil), wav()[:44+n]...)
+	binary.LittleEndian.PutUint32(b[4:], uint32(36+n))
+	binary.LittleEndian.PutUint32(b[40:], uint32(n))
+	return b
+}
+
+func TestPrepareTempDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	if n, err := PrepareTempDir(dir); n != 0 || err != nil {
+		t.Fatalf("new dir: %d, %v", n, err)
+	}
+	for _, name := range []string{"upload-1.wav", "converted-2.wav", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0o600)
+	}
+	if n, err := PrepareTempDir(dir); n != 2 || err != nil {
+		t.Fatalf("removed %d, %v", n, err)
+	}
+	if left, _ := os.ReadDir(dir); len(left) != 1 || left[0].Name() != "notes.txt" {
+		t.Errorf("left %v", left)
+	}
+}
+
+// FuzzUpload sends arbitrary files through the upload path: the server
+// must answer with a client error or success, and leave nothing behind.
+func FuzzUpload(f *testing.F) {
+	f.Add("a.wav", "audio/wav", wav())
+	f.Add("a.wav", "", wav()[:44])
+	f.Add("clip", "audio/mpeg", []byte("ID3\x04\x00\x00\x00\x00\x00\x00\xFF\xFB\x90\x00"))
+	f.Add("a.ogg", "audio/ogg", []byte("OggS"))
+	f.Add("", "", []byte("RIFF\xff\xff\xff\xffWAVEfmt \x10\x00\x00\x00"))
+	f.Fuzz(func(t *testing.T, filename, contentType string, content []byte) {
+		s, dir := uploadServer(t, 1<<16, time.Minute)
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="audio"; filename="`+filename+`"`)
+		if contentType != "" {
+			h.Set("Content-Type", contentType)
+		}
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			t.Skip()
+		}
+		pw.Write(content)
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/transcribe", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		checkUpload(t, s, req, dir)
+	})
+}
+
+// FuzzUploadBody sends arbitrary multipart bodies.
+func FuzzUploadBody(f *testing.F) {
+	f.Add([]byte("--b\r\nContent-Disposition: form-data; name=\"audio\"; filename=\"a.wav\"\r\n\r\nRIFF\r\n--b--\r\n"))
+	f.Add([]byte("--b\r\nContent-Disposition: form-data; name=\"lang\"\r\n\r\nen\r\n--b--\r\n"))
+	f.Add([]byte("--b--"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		s, dir := uploadServer(t, 1<<16, time.Minute)
+		req := httptest.NewRequest(http.MethodPost, "/api/transcribe", bytes.NewReader(b))
+		req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+		checkUpload(t, s, req, dir)
+	})
+}
+
+func checkUpload(t *testing.T, s *Server, req *http.Request, dir string) {
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	switch rec.Code {
+	case http.StatusOK, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+	default:
+		// Ogg and WebM need ffmpeg, which may not be installed
+		if failed := s.logger.(*testLogger).find("request failed"); len(failed) != 1 || !errors.Is(failed[0].kv["error"].(error), exec.ErrNotFound) {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	if left, _ := os.ReadDir(dir); len(left) != 0 {
+		t.Fatalf("left %v in the temp dir", left)
+	}
+}
@@ -4,10 +4,14 @@ package decoder
 // into normalized mono float32 PCM.
 
 import (
+    "errors"
     "fmt"
     "path/filepath"
 )
 
+// ErrUnsupportedFormat is returned by New for files it has no decoder for.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
 type Info struct {
     SampleRate int
     Channels   int
@@ -31,7 +35,7 @@ func New(path string) (Decoder, error) {
 	case ".mp3", ".MP3", ".Mp3":
         return NewMP3(path)
     default:
-        return nil, fmt.Errorf("unsupported audio format: %s", ext)
+        return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, ext)
     }
 }
 
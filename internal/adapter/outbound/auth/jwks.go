@@ -0,0 +1,231 @@
+package auth
+
+import (
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math/big"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+// JWKS is a JSON Web Key Set read from a file or an http(s) URL. Keys are
+// cached: a file is read again when it changes, a URL when its keys are
+// older than Refresh or, to pick up rotated keys, when a token names a key
+// ID the set does not hold. If a refresh fails the previous keys are kept.
+type JWKS struct {
+    Source  string        // file path or http(s) URL
+    Refresh time.Duration // maximum age of keys fetched from a URL; 0 for an hour
+    Client  *http.Client  // nil for a client with a 10s timeout
+    Clock   port.Clock    // nil for the system clock
+
+    mu      sync.Mutex
+    keys    []jwk
+    expires time.Time // when keys from a URL go stale
+    modTime time.Time // of the file keys were read from
+    miss    time.Time // last refresh for an unknown key ID
+}
+
+// missInterval limits refreshes for unknown key IDs, so tokens naming
+// made-up keys cannot make the server hammer the JWKS endpoint.
+const missInterval = time.Minute
+
+type jwk struct {
+    kid string
+    alg string // empty when the set does not restrict it
+    key crypto.PublicKey
+}
+
+// keysFor returns the keys that may have signed a token with kid, or all
+// keys when kid is empty.
+func (s *JWKS) keysFor(ctx context.Context, kid string) ([]jwk, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := s.now()
+    if s.stale(now) {
+        if err := s.load(ctx, now); err != nil && s.keys == nil { return nil, err }
+    }
+    match := s.match(kid)
+    if len(match) == 0 && kid != "" && s.isURL() && now.Sub(s.miss) >= missInterval {
+        s.miss = now
+        if err := s.load(ctx, now); err != nil { return nil, err }
+        match = s.match(kid)
+    }
+    return match, nil
+}
+
+func (s *JWKS) match(kid string) []jwk {
+    if kid == "" { return s.keys }
+    var out []jwk
+    for _, k := range s.keys {
+        if k.kid == kid { out = append(out, k) }
+    }
+    return out
+}
+
+func (s *JWKS) isURL() bool {
+    return strings.HasPrefix(s.Source, "https://") || strings.HasPrefix(s.Source, "http://")
+}
+
+// stale reports whether the cached keys must be loaded again. s.mu must
+// be held.
+func (s *JWKS) stale(now time.Time) bool {
+    if s.keys == nil { return true }
+    if s.isURL() { return !now.Before(s.expires) }
+    fi, err := os.Stat(s.Source)
+    return err == nil && !fi.ModTime().Equal(s.modTime)
+}
+
+// load reads the key set. s.mu must be held.
+func (s *JWKS) load(ctx context.Context, now time.Time) error {
+    var (
+        data   []byte
+        maxAge = s.Refresh
+        err    error
+    )
+    if maxAge <= 0 { maxAge = time.Hour }
+    if s.isURL() {
+        var age time.Duration
+        data, age, err = s.fetch(ctx)
+        if age > 0 && age < maxAge { maxAge = age }
+    } else {
+        var fi os.FileInfo
+        if fi, err = os.Stat(s.Source); err == nil {
+            s.modTime = fi.ModTime()
+            data, err = os.ReadFile(s.Source)
+        }
+    }
+    if err != nil { return herr.Newf(herr.Unavailable, "load JWKS: %w", err) }
+    keys, err := parseJWKS(data)
+    if err != nil { return herr.Newf(herr.Unavailable, "load JWKS from %s: %w", s.Source, err) }
+    s.keys, s.expires = keys, now.Add(maxAge)
+    return nil
+}
+
+// fetch downloads the key set and returns the max-age it may be cached for.
+func (s *JWKS) fetch(ctx context.Context) ([]byte, time.Duration, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Source, nil)
+    if err != nil { return nil, 0, err }
+    req.Header.Set("Accept", "application/json")
+    client := s.Client
+    if client == nil { client = &http.Client{Timeout: 10 * time.Second} }
+    resp, err := client.Do(req)
+    if err != nil { return nil, 0, err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { return nil, 0, fmt.Errorf("GET %s: %s", s.Source, resp.Status) }
+    data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+    if err != nil { return nil, 0, err }
+    return data, maxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// maxAge returns the max-age directive of a Cache-Control header, or 0.
+func maxAge(cc string) time.Duration {
+    for _, d := range strings.Split(cc, ",") {
+        if v, ok := strings.CutPrefix(strings.TrimSpace(d), "max-age="); ok {
+            if n, err := strconv.Atoi(v); err == nil && n > 0 { return time.Duration(n) * time.Second }
+        }
+    }
+    return 0
+}
+
+func (s *JWKS) now() time.Time {
+    if s.Clock != nil { return s.Clock.Now() }
+    return time.Now()
+}
+
+// parseJWKS decodes the signature keys of a key set. Keys of unknown types
+// and encryption keys are skipped, so one new key type does not break
+// verification with the others.
+func parseJWKS(data []byte) ([]jwk, error) {
+    var set struct {
+        Keys []struct {
+            Kty string `json:"kty"`
+            Kid string `json:"kid"`
+            Use string `json:"use"`
+            Alg string `json:"alg"`
+            Crv string `json:"crv"`
+            N   string `json:"n"`
+            E   string `json:"e"`
+            X   string `json:"x"`
+            Y   string `json:"y"`
+        } `json:"keys"`
+    }
+    if err := json.Unmarshal(data, &set); err != nil { return nil, err }
+    keys := make([]jwk, 0, len(set.Keys))
+    for i, k := range set.Keys {
+        if k.Use != "" && k.Use != "sig" { continue }
+        var (
+            pub crypto.PublicKey
+            err error
+        )
+        switch k.Kty {
+        case "RSA":
+            pub, err = rsaKey(k.N, k.E)
+        case "EC":
+            pub, err = ecKey(k.Crv, k.X, k.Y)
+        case "OKP":
+            if k.Crv != "Ed25519" { continue }
+            var x []byte
+            if x, err = b64(k.X); err == nil && len(x) != ed25519.PublicKeySize { err = fmt.Errorf("bad Ed25519 key length %d", len(x)) }
+            pub = ed25519.PublicKey(x)
+        default:
+            continue
+        }
+        if err != nil { return nil, fmt.Errorf("key %d (%q): %w", i, k.Kid, err) }
+        keys = append(keys, jwk{kid: k.Kid, alg: k.Alg, key: pub})
+    }
+    if len(keys) == 0 { return nil, fmt.Errorf("no signature keys") }
+    return keys, nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+    nb, err := b64(n)
+    if err != nil { return nil, err }
+    eb, err := b64(e)
+    if err != nil { return nil, err }
+    exp := new(big.Int).SetBytes(eb)
+    if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 { return nil, fmt.Errorf("bad RSA exponent") }
+    k := &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}
+    if k.N.BitLen() < 2048 { return nil, fmt.Errorf("RSA key of %d bits is too short", k.N.BitLen()) }
+    return k, nil
+}
+
+func ecKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+    var c elliptic.Curve
+    switch crv {
+    case "P-256":
+        c = elliptic.P256()
+    case "P-384":
+        c = elliptic.P384()
+    case "P-521":
+        c = elliptic.P521()
+    default:
+        return nil, fmt.Errorf("unsupported curve %q", crv)
+    }
+    xb, err := b64(x)
+    if err != nil { return nil, err }
+    yb, err := b64(y)
+    if err != nil { return nil, err }
+    k := &ecdsa.PublicKey{Curve: c, X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}
+    if _, err := k.ECDH(); err != nil { return nil, fmt.Errorf("point is not on %s", crv) }
+    return k, nil
+}
+
+// b64 decodes base64url, with or without padding.
+func b64(s string) ([]byte, error) {
+    return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
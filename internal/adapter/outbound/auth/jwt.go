@@ -0,0 +1,202 @@
+package auth
+
+import (
+    "bytes"
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/json"
+    "fmt"
+    "math"
+    "math/big"
+    "strings"
+    "time"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+// JWTVerifier authenticates bearer JWTs signed with RS256/384/512,
+// ES256/384/512 or EdDSA by a key in a JWKS. Tokens must carry an exp
+// claim; iss and aud are checked when Issuer and Audience are set.
+type JWTVerifier struct {
+    Keys     *JWKS
+    Issuer   string        // required iss; empty accepts any
+    Audience []string      // aud must name one of these; empty accepts any
+    Subject  string        // claim holding the principal ID; empty for "sub"
+    Leeway   time.Duration // clock skew allowed for exp, nbf and iat
+    Clock    port.Clock    // nil for the system clock
+}
+
+var _ port.Authenticator = (*JWTVerifier)(nil)
+
+// IsJWT reports whether credential has the shape of a compact JWS, as
+// opposed to an opaque API key.
+func IsJWT(credential string) bool {
+    return strings.Count(credential, ".") == 2 && strings.HasPrefix(credential, "eyJ")
+}
+
+// Authenticate verifies the token and maps its claims to a principal. An
+// optional "quota" claim, {"requests": n, "audio_seconds": n}, sets the
+// principal's quota.
+func (v *JWTVerifier) Authenticate(ctx context.Context, token string) (domain.Principal, error) {
+    claims, err := v.verify(ctx, token)
+    if err != nil { return domain.Principal{}, err }
+    claim := v.Subject
+    if claim == "" { claim = "sub" }
+    id, _ := claims[claim].(string)
+    if id == "" { return domain.Principal{}, invalidToken("no %s claim", claim) }
+    p := domain.Principal{ID: id, Method: "jwt", Claims: claims}
+    if q, ok := claims["quota"].(map[string]any); ok {
+        if n, ok := q["requests"].(json.Number); ok { p.Quota.Requests, _ = n.Int64() }
+        if n, ok := q["audio_seconds"].(json.Number); ok { p.Quota.AudioSeconds, _ = n.Float64() }
+    }
+    return p, nil
+}
+
+// Credentials authenticates each credential with the authenticator for its
+// kind: JWTs with Tokens, anything else with Keys. Either may be nil.
+type Credentials struct {
+    Keys   port.Authenticator
+    Tokens port.Authenticator
+}
+
+func (c Credentials) Authenticate(ctx context.Context, credential string) (domain.Principal, error) {
+    a, kind := c.Keys, "API key"
+    if IsJWT(credential) { a, kind = c.Tokens, "token" }
+    if a == nil { return domain.Principal{}, herr.Newf(herr.Unauthenticated, "%s authentication is not enabled", kind) }
+    return a.Authenticate(ctx, credential)
+}
+
+func (v *JWTVerifier) verify(ctx context.Context, token string) (map[string]any, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 { return nil, invalidToken("malformed token") }
+    var header struct {
+        Alg  string   `json:"alg"`
+        Kid  string   `json:"kid"`
+        Crit []string `json:"crit"`
+    }
+    if err := decodeSegment(parts[0], &header); err != nil { return nil, invalidToken("malformed header") }
+    if len(header.Crit) > 0 { return nil, invalidToken("unsupported critical header %q", header.Crit) }
+    hash, ok := algHashes[header.Alg]
+    if !ok { return nil, invalidToken("unsupported algorithm %q", header.Alg) }
+    sig, err := b64(parts[2])
+    if err != nil { return nil, invalidToken("malformed signature") }
+
+    keys, err := v.Keys.keysFor(ctx, header.Kid)
+    if err != nil { return nil, err }
+    signed := []byte(parts[0] + "." + parts[1])
+    verified := false
+    for _, k := range keys {
+        if k.alg != "" && k.alg != header.Alg { continue }
+        if verifySignature(header.Alg, hash, k.key, signed, sig) {
+            verified = true
+            break
+        }
+    }
+    if !verified { return nil, invalidToken("signature does not match any known key") }
+
+    var claims map[string]any
+    if err := decodeSegment(parts[1], &claims); err != nil || claims == nil { return nil, invalidToken("malformed claims") }
+    if err := v.checkClaims(claims); err != nil { return nil, err }
+    return claims, nil
+}
+
+func (v *JWTVerifier) checkClaims(claims map[string]any) error {
+    now := time.Now()
+    if v.Clock != nil { now = v.Clock.Now() }
+    exp, ok := numericDate(claims["exp"])
+    if !ok { return invalidToken("no exp claim") }
+    if !now.Before(exp.Add(v.Leeway)) { return invalidToken("token expired") }
+    if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.Leeway).Before(nbf) { return invalidToken("token not valid yet") }
+    if iat, ok := numericDate(claims["iat"]); ok && now.Add(v.Leeway).Before(iat) { return invalidToken("token issued in the future") }
+    if v.Issuer != "" {
+        if iss, _ := claims["iss"].(string); iss != v.Issuer { return invalidToken("unexpected issuer") }
+    }
+    if len(v.Audience) > 0 && !audienceMatches(claims["aud"], v.Audience) { return invalidToken("unexpected audience") }
+    return nil
+}
+
+var algHashes = map[string]crypto.Hash{
+    "RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+    "ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+    "EdDSA": 0,
+}
+
+// verifySignature checks sig over signed with key, which must be of the
+// type alg calls for; ECDSA keys must also be on alg's curve.
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, signed, sig []byte) bool {
+    digest := func() []byte {
+        switch hash {
+        case crypto.SHA384:
+            d := sha512.Sum384(signed)
+            return d[:]
+        case crypto.SHA512:
+            d := sha512.Sum512(signed)
+            return d[:]
+        }
+        d := sha256.Sum256(signed)
+        return d[:]
+    }
+    switch k := key.(type) {
+    case *rsa.PublicKey:
+        return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(k, hash, digest(), sig) == nil
+    case *ecdsa.PublicKey:
+        size := (k.Curve.Params().BitSize + 7) / 8
+        if !strings.HasPrefix(alg, "ES") || ecCurveBits[alg] != k.Curve.Params().BitSize || len(sig) != 2*size { return false }
+        r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+        return ecdsa.Verify(k, digest(), r, s)
+    case ed25519.PublicKey:
+        return alg == "EdDSA" && ed25519.Verify(k, signed, sig)
+    }
+    return false
+}
+
+var ecCurveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+func decodeSegment(seg string, v any) error {
+    b, err := b64(seg)
+    if err != nil { return err }
+    dec := json.NewDecoder(bytes.NewReader(b))
+    dec.UseNumber()
+    return dec.Decode(v)
+}
+
+func numericDate(v any) (time.Time, bool) {
+    n, ok := v.(json.Number)
+    if !ok { return time.Time{}, false }
+    if i, err := n.Int64(); err == nil { return time.Unix(i, 0), true }
+    f, err := n.Float64()
+    if err != nil || math.Abs(f) > 1e15 { return time.Time{}, false }
+    sec, frac := math.Modf(f)
+    return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// audienceMatches reports whether aud, a string or a list of strings,
+// names one of want.
+func audienceMatches(aud any, want []string) bool {
+    var got []string
+    switch a := aud.(type) {
+    case string:
+        got = []string{a}
+    case []any:
+        for _, item := range a {
+            if s, ok := item.(string); ok { got = append(got, s) }
+        }
+    }
+    for _, g := range got {
+        for _, w := range want {
+            if g == w { return true }
+        }
+    }
+    return false
+}
+
+func invalidToken(format string, args ...any) error {
+    return herr.New(herr.Unauthenticated, "invalid token: "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+
+    herr "gosper/pkg/errors"
+)
+
+type testClock struct{ t time.Time }
+
+func (c *testClock) Now() time.Time { return c.t }
+
+// testKey is a signing key and its public JWK.
+type testKey struct {
+    kid, alg string
+    priv     crypto.Signer
+}
+
+func (k testKey) jwk() map[string]any {
+    enc := base64.RawURLEncoding.EncodeToString
+    m := map[string]any{"kid": k.kid, "use": "sig", "alg": k.alg}
+    switch pub := k.priv.Public().(type) {
+    case *rsa.PublicKey:
+        m["kty"], m["n"], m["e"] = "RSA", enc(pub.N.Bytes()), enc(big.NewInt(int64(pub.E)).Bytes())
+    case *ecdsa.PublicKey:
+        m["kty"], m["crv"], m["x"], m["y"] = "EC", "P-256", enc(pub.X.FillBytes(make([]byte, 32))), enc(pub.Y.FillBytes(make([]byte, 32)))
+    case ed25519.PublicKey:
+        m["kty"], m["crv"], m["x"] = "OKP", "Ed25519", enc(pub)
+    }
+    return m
+}
+
+func (k testKey) sign(t *testing.T, claims map[string]any) string {
+    t.Helper()
+    seg := func(v any) string {
+        b, _ := json.Marshal(v)
+        return base64.RawURLEncoding.EncodeToString(b)
+    }
+    signed := seg(map[string]any{"alg": k.alg, "kid": k.kid, "typ": "JWT"}) + "." + seg(claims)
+    var sig []byte
+    var err error
+    switch p := k.priv.(type) {
+    case ed25519.PrivateKey:
+        sig = ed25519.Sign(p, []byte(signed))
+    case *ecdsa.PrivateKey:
+        d := sha256.Sum256([]byte(signed))
+        r, s, e := ecdsa.Sign(rand.Reader, p, d[:])
+        sig, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), e
+    default:
+        d := sha256.Sum256([]byte(signed))
+        sig, err = k.priv.Sign(rand.Reader, d[:], crypto.SHA256)
+    }
+    if err != nil { t.Fatal(err) }
+    return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestKeys(t *testing.T) (rs, es, ed testKey) {
+    t.Helper()
+    rk, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil { t.Fatal(err) }
+    ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil { t.Fatal(err) }
+    _, dk, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil { t.Fatal(err) }
+    return testKey{"rsa-1", "RS256", rk}, testKey{"ec-1", "ES256", ek}, testKey{"ed-1", "EdDSA", dk}
+}
+
+// jwksServer serves the public keys of *keys, counting fetches.
+func jwksServer(t *testing.T, mu *sync.Mutex, keys *[]testKey, fetches *int) *httptest.Server {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        defer mu.Unlock()
+        *fetches++
+        set := []map[string]any{}
+        for _, k := range *keys { set = append(set, k.jwk()) }
+        w.Header().Set("Cache-Control", "public, max-age=600")
+        _ = json.NewEncoder(w).Encode(map[string]any{"keys": set})
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func TestJWTVerifier(t *testing.T) {
+    rs, es, ed := newTestKeys(t)
+    var mu sync.Mutex
+    keys, fetches := []testKey{rs, es, ed}, 0
+    srv := jwksServer(t, &mu, &keys, &fetches)
+    clk := &testClock{t: time.Unix(1_700_000_000, 0)}
+    v := &JWTVerifier{
+        Keys:     &JWKS{Source: srv.URL, Clock: clk},
+        Issuer:   "https://id.example.com",
+        Audience: []string{"gosper"},
+        Clock:    clk,
+    }
+    claims := func(extra map[string]any) map[string]any {
+        c := map[string]any{"iss": "https://id.example.com", "aud": []string{"other", "gosper"}, "sub": "app-1", "exp": clk.t.Add(time.Hour).Unix()}
+        for k, val := range extra { c[k] = val }
+        return c
+    }
+
+    for _, k := range []testKey{rs, es, ed} {
+        p, err := v.Authenticate(context.Background(), k.sign(t, claims(map[string]any{"quota": map[string]any{"requests": 5}})))
+        if err != nil || p.ID != "app-1" || p.Method != "jwt" || p.Quota.Requests != 5 || p.Claims["iss"] != "https://id.example.com" {
+            t.Fatalf("%s: Authenticate = %+v, %v", k.alg, p, err)
+        }
+    }
+    if fetches != 1 { t.Fatalf("JWKS fetched %d times, want 1 (cached)", fetches) }
+
+    other, _, _ := newTestKeys(t)
+    forged := other
+    forged.kid = rs.kid
+    for name, tok := range map[string]string{
+        "expired":        rs.sign(t, claims(map[string]any{"exp": clk.t.Add(-time.Minute).Unix()})),
+        "no exp":         rs.sign(t, map[string]any{"sub": "app-1", "iss": "https://id.example.com", "aud": "gosper"}),
+        "not yet valid":  rs.sign(t, claims(map[string]any{"nbf": clk.t.Add(time.Hour).Unix()})),
+        "wrong issuer":   rs.sign(t, claims(map[string]any{"iss": "https://evil.example.com"})),
+        "wrong audience": rs.sign(t, claims(map[string]any{"aud": "other"})),
+        "bad signature":  forged.sign(t, claims(nil)),
+        "alg none":       (testKey{kid: rs.kid, alg: "none", priv: rs.priv}).signNone(claims(nil)),
+    } {
+        if _, err := v.Authenticate(context.Background(), tok); herr.From(err).Code != herr.Unauthenticated {
+            t.Errorf("%s: want unauthenticated, got %v", name, err)
+        }
+    }
+}
+
+// signNone returns an unsigned token claiming alg "none".
+func (k testKey) signNone(claims map[string]any) string {
+    seg := func(v any) string {
+        b, _ := json.Marshal(v)
+        return base64.RawURLEncoding.EncodeToString(b)
+    }
+    return seg(map[string]any{"alg": "none", "kid": k.kid}) + "." + seg(claims) + "."
+}
+
+func TestJWKS_Rotation(t *testing.T) {
+    rs, es, _ := newTestKeys(t)
+    var mu sync.Mutex
+    keys, fetches := []testKey{rs}, 0
+    srv := jwksServer(t, &mu, &keys, &fetches)
+    clk := &testClock{t: time.Unix(1_700_000_000, 0)}
+    v := &JWTVerifier{Keys: &JWKS{Source: srv.URL, Clock: clk}, Clock: clk}
+    tok := func(k testKey) string { return k.sign(t, map[string]any{"sub": "a", "exp": clk.t.Add(time.Hour).Unix()}) }
+
+    if _, err := v.Authenticate(context.Background(), tok(rs)); err != nil { t.Fatal(err) }
+
+    // The issuer rotates to a new key: the unknown kid triggers a refetch.
+    mu.Lock()
+    keys = []testKey{es}
+    mu.Unlock()
+    if _, err := v.Authenticate(context.Background(), tok(es)); err != nil { t.Fatalf("rotated key: %v", err) }
+    if fetches != 2 { t.Fatalf("fetches = %d, want 2", fetches) }
+
+    // Unknown kids refetch at most once a minute.
+    _, _, ed := newTestKeys(t)
+    if _, err := v.Authenticate(context.Background(), tok(ed)); herr.From(err).Code != herr.Unauthenticated || fetches != 2 {
+        t.Fatalf("unknown kid right after a refetch: %v, fetches = %d", err, fetches)
+    }
+    clk.t = clk.t.Add(2 * time.Minute)
+    for i := 0; i < 3; i++ { _, _ = v.Authenticate(context.Background(), tok(ed)) }
+    if fetches != 3 { t.Fatalf("fetches = %d, want 3", fetches) }
+
+    // Keys expire after the server's max-age.
+    clk.t = clk.t.Add(11 * time.Minute)
+    if _, err := v.Authenticate(context.Background(), tok(es)); err != nil { t.Fatal(err) }
+    if fetches != 4 { t.Fatalf("fetches = %d, want 4", fetches) }
+}
+
+func TestJWKS_FileAndCredentials(t *testing.T) {
+    _, es, ed := newTestKeys(t)
+    path := filepath.Join(t.TempDir(), "jwks.json")
+    write := func(k testKey, mtime time.Time) {
+        b, _ := json.Marshal(map[string]any{"keys": []any{k.jwk()}})
+        if err := os.WriteFile(path, b, 0o600); err != nil { t.Fatal(err) }
+        if err := os.Chtimes(path, mtime, mtime); err != nil { t.Fatal(err) }
+    }
+    write(es, time.Unix(1000, 0))
+
+    ks, err := NewKeyStore([]APIKey{{ID: "ci", Hash: HashKey("secret")}})
+    if err != nil { t.Fatal(err) }
+    c := Credentials{Keys: ks, Tokens: &JWTVerifier{Keys: &JWKS{Source: path}}}
+    exp := time.Now().Add(time.Hour).Unix()
+
+    if p, err := c.Authenticate(context.Background(), "secret"); err != nil || p.Method != "api_key" { t.Fatalf("api key: %+v, %v", p, err) }
+    if p, err := c.Authenticate(context.Background(), es.sign(t, map[string]any{"sub": "a", "exp": exp})); err != nil || p.Method != "jwt" {
+        t.Fatalf("token: %+v, %v", p, err)
+    }
+
+    // A changed file is read again.
+    write(ed, time.Unix(2000, 0))
+    if _, err := c.Authenticate(context.Background(), ed.sign(t, map[string]any{"sub": "a", "exp": exp})); err != nil { t.Fatalf("reloaded file: %v", err) }
+
+    if _, err := (Credentials{Keys: ks}).Authenticate(context.Background(), ed.sign(t, map[string]any{"sub": "a", "exp": exp})); herr.From(err).Code != herr.Unauthenticated {
+        t.Fatalf("token without a verifier: %v", err)
+    }
+}
@@ -0,0 +1,107 @@
+// Package auth verifies API client credentials.
+package auth
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+// APIKey is a configured API key. Only the SHA-256 digest of the key is
+// stored, as "sha256:<hex>", so a leaked key file grants nothing.
+type APIKey struct {
+    ID       string `yaml:"id"`
+    Hash     string `yaml:"hash"`
+    Disabled bool   `yaml:"disabled"` // authenticates, but is refused with 403
+    Quota    struct {
+        Requests     int64   `yaml:"requests"`
+        AudioSeconds float64 `yaml:"audio_seconds"`
+    } `yaml:"quota"`
+}
+
+// KeyStore authenticates static API keys.
+type KeyStore struct {
+    keys map[string]APIKey // by hex digest
+}
+
+var _ port.Authenticator = (*KeyStore)(nil)
+
+// NewKeyStore checks keys for malformed digests and duplicate IDs.
+func NewKeyStore(keys []APIKey) (*KeyStore, error) {
+    s := &KeyStore{keys: make(map[string]APIKey, len(keys))}
+    ids := map[string]bool{}
+    for i, k := range keys {
+        if k.ID == "" { return nil, fmt.Errorf("api key %d has no id", i) }
+        if ids[k.ID] { return nil, fmt.Errorf("api key %q is listed twice", k.ID) }
+        ids[k.ID] = true
+        digest := strings.ToLower(strings.TrimPrefix(k.Hash, "sha256:"))
+        if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+            return nil, fmt.Errorf("api key %q: hash must be sha256:<64 hex digits>", k.ID)
+        }
+        s.keys[digest] = k
+    }
+    return s, nil
+}
+
+// Len returns the number of keys.
+func (s *KeyStore) Len() int { return len(s.keys) }
+
+func (s *KeyStore) Authenticate(ctx context.Context, credential string) (domain.Principal, error) {
+    sum := sha256.Sum256([]byte(credential))
+    k, ok := s.keys[hex.EncodeToString(sum[:])]
+    if !ok { return domain.Principal{}, herr.New(herr.Unauthenticated, "invalid API key") }
+    if k.Disabled { return domain.Principal{}, herr.Newf(herr.PermissionDenied, "API key %q is disabled", k.ID) }
+    return domain.Principal{
+        ID:     k.ID,
+        Method: "api_key",
+        Quota:  domain.Quota{Requests: k.Quota.Requests, AudioSeconds: k.Quota.AudioSeconds},
+    }, nil
+}
+
+// LoadKeyFile reads keys from a YAML or JSON file holding a "keys" list.
+func LoadKeyFile(path string) ([]APIKey, error) {
+    b, err := os.ReadFile(path)
+    if err != nil { return nil, err }
+    var f struct {
+        Keys []APIKey `yaml:"keys"`
+    }
+    if err := yaml.Unmarshal(b, &f); err != nil { return nil, fmt.Errorf("api key file %s: %w", path, err) }
+    return f.Keys, nil
+}
+
+// ParseKeys parses a comma-separated list of id:sha256:<hex> entries, as
+// given in the environment.
+func ParseKeys(spec string) ([]APIKey, error) {
+    var keys []APIKey
+    for _, item := range strings.Split(spec, ",") {
+        if item = strings.TrimSpace(item); item == "" { continue }
+        id, hash, ok := strings.Cut(item, ":")
+        if !ok { return nil, fmt.Errorf("api key %q: want id:sha256:<hex>", item) }
+        keys = append(keys, APIKey{ID: id, Hash: hash})
+    }
+    return keys, nil
+}
+
+// HashKey returns the digest of key in the form APIKey.Hash expects.
+func HashKey(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// NewKey returns a random API key.
+func NewKey() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil { return "", err }
+    return "gsk_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
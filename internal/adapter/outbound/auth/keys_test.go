@@ -0,0 +1,51 @@
+package auth
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+
+    herr "gosper/pkg/errors"
+)
+
+func TestKeyStore_Authenticate(t *testing.T) {
+    keys, err := ParseKeys("ci:" + HashKey("secret-ci") + ", old:" + HashKey("secret-old"))
+    if err != nil { t.Fatal(err) }
+    keys[1].Disabled = true
+    keys[0].Quota.Requests = 10
+    s, err := NewKeyStore(keys)
+    if err != nil { t.Fatal(err) }
+
+    p, err := s.Authenticate(context.Background(), "secret-ci")
+    if err != nil || p.ID != "ci" || p.Method != "api_key" || p.Quota.Requests != 10 {
+        t.Fatalf("Authenticate = %+v, %v", p, err)
+    }
+    if _, err := s.Authenticate(context.Background(), "wrong"); herr.From(err).Code != herr.Unauthenticated {
+        t.Fatalf("wrong key: %v", err)
+    }
+    if _, err := s.Authenticate(context.Background(), "secret-old"); herr.From(err).Code != herr.PermissionDenied {
+        t.Fatalf("disabled key: %v", err)
+    }
+}
+
+func TestNewKeyStore_Rejects(t *testing.T) {
+    for name, keys := range map[string][]APIKey{
+        "plain key":    {{ID: "a", Hash: "secret"}},
+        "duplicate id": {{ID: "a", Hash: HashKey("x")}, {ID: "a", Hash: HashKey("y")}},
+        "missing id":   {{Hash: HashKey("x")}},
+    } {
+        if _, err := NewKeyStore(keys); err == nil { t.Errorf("%s accepted", name) }
+    }
+}
+
+func TestLoadKeyFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "keys.yaml")
+    content := "keys:\n  - id: ci\n    hash: " + HashKey("k") + "\n    quota: { requests: 5, audio_seconds: 600 }\n"
+    if err := os.WriteFile(path, []byte(content), 0o600); err != nil { t.Fatal(err) }
+    keys, err := LoadKeyFile(path)
+    if err != nil || len(keys) != 1 || keys[0].Quota.AudioSeconds != 600 { t.Fatalf("LoadKeyFile = %+v, %v", keys, err) }
+
+    k, err := NewKey()
+    if err != nil || len(k) < 40 { t.Fatalf("NewKey = %q, %v", k, err) }
+}
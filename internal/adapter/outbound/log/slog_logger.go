@@ -2,6 +2,8 @@ package logadapter
 
 import (
     "context"
+    "fmt"
+    "io"
     "log/slog"
 
     "gosper/internal/port"
@@ -13,6 +15,21 @@ type SlogLogger struct{
 
 func NewSlogLogger(l *slog.Logger) *SlogLogger { return &SlogLogger{l: l} }
 
+// NewSlog returns a logger writing to w at level (debug, info, warn or
+// error) as JSON or text.
+func NewSlog(w io.Writer, level, format string) (*slog.Logger, error) {
+    var lv slog.Level
+    if err := lv.UnmarshalText([]byte(level)); err != nil { return nil, fmt.Errorf("log level: %w", err) }
+    opts := &slog.HandlerOptions{Level: lv}
+    switch format {
+    case "json":
+        return slog.New(slog.NewJSONHandler(w, opts)), nil
+    case "text":
+        return slog.New(slog.NewTextHandler(w, opts)), nil
+    }
+    return nil, fmt.Errorf("log format %q is not json or text", format)
+}
+
 var _ port.Logger = (*SlogLogger)(nil)
 
 func (s *SlogLogger) Debug(ctx context.Context, msg string, kv ...any) { s.l.DebugContext(ctx, msg, kv...) }
@@ -0,0 +1,143 @@
+package model
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// CachedModel is a model file present in the cache directory.
+type CachedModel struct {
+    Entry    ManifestEntry // manifest entry, or a synthetic one for unknown files
+    Known    bool          // whether the file is listed in the manifest
+    Path     string
+    Size     int64
+    LastUsed time.Time // from the usage record, else the file's modification time
+    Pinned   bool
+    InUse    bool // held in this process
+}
+
+// VerifyResult reports the outcome of checking one cached model.
+type VerifyResult struct {
+    Model  CachedModel
+    Status string // "ok", "mismatch" or "pinned" (no digest known; first digest recorded)
+    SHA256 string
+    Err    error
+}
+
+// Dir returns the cache directory models are stored in.
+func (r *FSRepo) Dir() string { return r.cacheDir() }
+
+// Cached lists model files in the cache directory, sorted by file name.
+// Partial downloads and bookkeeping files are skipped.
+func (r *FSRepo) Cached() ([]CachedModel, error) {
+    m, err := r.Manifest()
+    if err != nil { return nil, err }
+    cacheDir := r.cacheDir()
+    ents, err := os.ReadDir(cacheDir)
+    if os.IsNotExist(err) { return nil, nil }
+    if err != nil { return nil, err }
+    usage := loadRecords[usageRecord](cacheDir, usageFile)
+    var out []CachedModel
+    for _, de := range ents {
+        name := de.Name()
+        if !de.Type().IsRegular() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".bin") { continue }
+        fi, err := de.Info()
+        if err != nil { continue }
+        e, known := m.Lookup(name)
+        if !known { e = ManifestEntry{Name: strings.TrimSuffix(strings.TrimPrefix(name, "ggml-"), ".bin"), File: name} }
+        c := CachedModel{Entry: e, Known: known, Path: filepath.Join(cacheDir, name), Size: fi.Size(), LastUsed: fi.ModTime()}
+        if u, ok := usage[name]; ok {
+            if !u.LastUsed.IsZero() { c.LastUsed = u.LastUsed }
+            c.Pinned = u.Pinned
+        }
+        c.Pinned = c.Pinned || r.pinnedByConfig(m, name)
+        c.InUse = r.holds.held(c.Path)
+        out = append(out, c)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Entry.File < out[j].Entry.File })
+    return out, nil
+}
+
+// VerifyCached re-hashes every cached model and checks it against the
+// expected digest from Checksums, the manifest or the pinned record.
+func (r *FSRepo) VerifyCached() ([]VerifyResult, error) {
+    models, err := r.Cached()
+    if err != nil { return nil, err }
+    m, err := r.Manifest()
+    if err != nil { return nil, err }
+    records := loadVerified(r.cacheDir())
+    verified := map[string]verifiedRecord{}
+    out := make([]VerifyResult, 0, len(models))
+    for _, cm := range models {
+        res := VerifyResult{Model: cm}
+        e := r.resolve(m, cm.Entry.File)
+        sum, err := fileSha256(cm.Path)
+        if err != nil {
+            res.Err = err
+            out = append(out, res)
+            continue
+        }
+        res.SHA256 = sum
+        want := e.SHA256
+        if want == "" { want = records[e.File].SHA256 }
+        switch {
+        case want == "":
+            res.Status = "pinned"
+        case strings.EqualFold(sum, want):
+            res.Status = "ok"
+        default:
+            res.Status = "mismatch"
+        }
+        if res.Status != "mismatch" {
+            if fi, err := os.Stat(cm.Path); err == nil {
+                verified[e.File] = verifiedRecord{SHA256: sum, Size: fi.Size(), ModTime: fi.ModTime()}
+            }
+        }
+        out = append(out, res)
+    }
+    updateVerified(r.cacheDir(), func(records map[string]verifiedRecord) {
+        for k, v := range verified { records[k] = v }
+    })
+    return out, nil
+}
+
+// Remove deletes a cached model, given by alias or file name, along with
+// its pinned digest.
+func (r *FSRepo) Remove(name string) error {
+    m, err := r.Manifest()
+    if err != nil { return err }
+    e := r.resolve(m, name)
+    if e.File != filepath.Base(e.File) { return fmt.Errorf("invalid model name %q", name) }
+    cacheDir := r.cacheDir()
+    if err := os.Remove(filepath.Join(cacheDir, e.File)); err != nil {
+        if os.IsNotExist(err) { return fmt.Errorf("model %q is not cached", name) }
+        return err
+    }
+    _ = os.Remove(filepath.Join(cacheDir, e.File+".part"))
+    _ = os.Remove(filepath.Join(cacheDir, e.File+".part.json"))
+    updateVerified(cacheDir, func(records map[string]verifiedRecord) { delete(records, e.File) })
+    updateRecords(cacheDir, usageFile, func(m map[string]usageRecord) {
+        if !m[e.File].Pinned { delete(m, e.File) }
+    })
+    return nil
+}
+
+// Lookup resolves a model name through the manifest and reports where it
+// would be cached and whether it is.
+func (r *FSRepo) Lookup(name string) (CachedModel, bool, error) {
+    if name == "" { return CachedModel{}, false, errors.New("model name required") }
+    m, err := r.Manifest()
+    if err != nil { return CachedModel{}, false, err }
+    e, known := m.Lookup(name)
+    if !known { e = ManifestEntry{Name: name, File: name} }
+    cm := CachedModel{Entry: e, Known: known, Path: filepath.Join(r.cacheDir(), e.File)}
+    fi, err := os.Stat(cm.Path)
+    if err != nil { return cm, false, nil }
+    cm.Size = fi.Size()
+    return cm, true, nil
+}
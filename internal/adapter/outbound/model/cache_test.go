@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFSRepo_CacheOperations tests listing, verifying and removing cached models
+func TestFSRepo_CacheOperations(t *testing.T) {
+	cacheDir := t.TempDir()
+	os.WriteFile(filepath.Join(cacheDir, "ggml-tiny.en.bin"), []byte("tiny"), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "custom.bin"), []byte("custom"), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "ggml-base.bin.part"), []byte("partial"), 0644)
+	repo := &FSRepo{CacheDir: cacheDir}
+
+	cached, err := repo.Cached()
+	if err != nil {
+		t.Fatalf("Cached() error = %v", err)
+	}
+	if len(cached) != 2 || cached[0].Entry.File != "custom.bin" || cached[0].Known || !cached[1].Known || cached[1].Entry.Name != "tiny.en" {
+		t.Fatalf("unexpected cached models: %+v", cached)
+	}
+
+	// First verification pins, second confirms
+	res, err := repo.VerifyCached()
+	if err != nil || len(res) != 2 || res[0].Status != "pinned" {
+		t.Fatalf("VerifyCached() = %+v, %v", res, err)
+	}
+	res, _ = repo.VerifyCached()
+	if res[1].Status != "ok" {
+		t.Fatalf("expected ok after pinning: %+v", res)
+	}
+	os.WriteFile(filepath.Join(cacheDir, "ggml-tiny.en.bin"), []byte("corrupt"), 0644)
+	res, _ = repo.VerifyCached()
+	if res[1].Status != "mismatch" {
+		t.Fatalf("expected mismatch for corrupted file: %+v", res)
+	}
+
+	if err := repo.Remove("tiny.en"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, cachedNow, _ := repo.Lookup("tiny.en"); cachedNow {
+		t.Error("model still cached after Remove")
+	}
+	if err := repo.Remove("tiny.en"); err == nil {
+		t.Error("expected error removing a model that is not cached")
+	}
+	if _, err := repo.Ensure(context.Background(), "../escape.bin"); err == nil {
+		t.Error("expected error for model name with a path")
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+    "context"
+    "path/filepath"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+)
+
+var _ port.ModelCatalog = (*FSRepo)(nil)
+
+// Catalog lists the manifest models, followed by cached files that are not
+// in the manifest, with their cache state.
+func (r *FSRepo) Catalog(ctx context.Context) ([]domain.ModelInfo, error) {
+    m, err := r.Manifest()
+    if err != nil { return nil, err }
+    cached, err := r.Cached()
+    if err != nil { return nil, err }
+    byFile := map[string]CachedModel{}
+    for _, c := range cached { byFile[c.Entry.File] = c }
+
+    out := make([]domain.ModelInfo, 0, len(m.Models))
+    for _, e := range m.Models {
+        c, ok := byFile[e.File]
+        out = append(out, modelInfo(e, c, ok))
+        delete(byFile, e.File)
+    }
+    for _, c := range cached {
+        if _, extra := byFile[c.Entry.File]; extra { out = append(out, modelInfo(c.Entry, c, true)) }
+    }
+    return out, nil
+}
+
+// Resolve maps an alias or file name to a manifest entry or a cached file.
+// Names that are neither, including paths, are not found.
+func (r *FSRepo) Resolve(ctx context.Context, name string) (domain.ModelInfo, bool, error) {
+    if name != filepath.Base(name) { return domain.ModelInfo{}, false, nil }
+    c, cached, err := r.Lookup(name)
+    if err != nil { return domain.ModelInfo{}, false, err }
+    if !c.Known && !cached { return domain.ModelInfo{}, false, nil }
+    return modelInfo(c.Entry, c, cached), true, nil
+}
+
+func modelInfo(e ManifestEntry, c CachedModel, cached bool) domain.ModelInfo {
+    info := domain.ModelInfo{
+        Name: e.Name, File: e.File, Size: e.Size,
+        Quantization: e.Quantization, Multilingual: e.Multilingual,
+        Cached: cached,
+    }
+    if cached {
+        info.Path = c.Path
+        info.Size = c.Size
+    }
+    return info
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFSRepo_CatalogAndResolve(t *testing.T) {
+	cacheDir := t.TempDir()
+	os.WriteFile(filepath.Join(cacheDir, "ggml-base.en.bin"), []byte("base"), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "custom.bin"), []byte("custom"), 0644)
+	os.WriteFile(filepath.Join(filepath.Dir(cacheDir), "outside.bin"), []byte("x"), 0644)
+	repo := &FSRepo{CacheDir: cacheDir}
+	ctx := context.Background()
+
+	cat, err := repo.Catalog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cat) != len(builtinManifest.Models)+1 {
+		t.Fatalf("catalog has %d models, want the manifest plus the custom file", len(cat))
+	}
+	if last := cat[len(cat)-1]; last.File != "custom.bin" || !last.Cached || last.Size != 6 {
+		t.Errorf("custom model = %+v", last)
+	}
+
+	for name, wantFile := range map[string]string{"base.en": "ggml-base.en.bin", "ggml-base.en.bin": "ggml-base.en.bin", "custom.bin": "custom.bin", "large-v3": "ggml-large-v3.bin"} {
+		m, ok, err := repo.Resolve(ctx, name)
+		if err != nil || !ok || m.File != wantFile {
+			t.Errorf("Resolve(%q) = %+v, %v, %v", name, m, ok, err)
+		}
+	}
+	for _, name := range []string{"unknown.bin", "../outside.bin", "/etc/passwd"} {
+		if _, ok, _ := repo.Resolve(ctx, name); ok {
+			t.Errorf("Resolve(%q) should not be found", name)
+		}
+	}
+}
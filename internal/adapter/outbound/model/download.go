@@ -0,0 +1,210 @@
+package model
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "gosper/pkg/trace"
+)
+
+// partMeta is stored next to a partial download (<file>.part.json) so a
+// later attempt can resume it with If-Range, and only if the remote file is
+// still the same one.
+type partMeta struct {
+    URL          string `json:"url"`
+    ETag         string `json:"etag,omitempty"`
+    LastModified string `json:"last_modified,omitempty"`
+}
+
+func (m partMeta) validator() string {
+    if m.ETag != "" && !strings.HasPrefix(m.ETag, "W/") { return m.ETag }
+    return m.LastModified
+}
+
+// download fetches url from src into local via local+".part", resuming an
+// earlier partial download when the server supports ranges and the validator
+// still matches. The SHA-256 is computed while streaming. On failure the
+// partial file is kept for the next attempt, and kept reports how many bytes
+// of it that attempt can resume from: 0 when the server gave no validator to
+// resume with.
+func (r *FSRepo) download(ctx context.Context, src Source, url, local, file string) (sum string, kept int64, err error) {
+    ctx, span := trace.Start(ctx, "model.download", trace.String("model.file", file), trace.String("model.source", src.String()))
+    defer func() {
+        span.Fail(err)
+        span.End()
+    }()
+    // A stalled transfer cancels ctx; see stallTimer.
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    stall := newStallTimer(src.Timeout, cancel)
+    defer stall.Stop()
+    defer func() {
+        if err != nil && stall.fired() { err = fmt.Errorf("download stalled: no data from %s for %s", src, src.Timeout) }
+    }()
+
+    tmp := local + ".part"
+    metaPath := tmp + ".json"
+
+    var offset int64
+    meta := loadPartMeta(metaPath)
+    if fi, err := os.Stat(tmp); err == nil && meta.URL == url && meta.validator() != "" {
+        offset = fi.Size()
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil { return "", 0, err }
+    for k, v := range src.Headers { req.Header.Set(k, v) }
+    if offset > 0 {
+        req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+        req.Header.Set("If-Range", meta.validator())
+    }
+    resp, err := src.client().Do(req)
+    if err != nil { return "", 0, err }
+    defer resp.Body.Close()
+
+    total := resp.ContentLength
+    switch resp.StatusCode {
+    case http.StatusPartialContent:
+        start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+        if !ok || start != offset {
+            os.Remove(tmp)
+            return "", 0, fmt.Errorf("download failed: unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+        }
+        total = size
+    case http.StatusOK:
+        offset = 0 // no range support, or the remote file changed
+    case http.StatusRequestedRangeNotSatisfiable:
+        os.Remove(tmp) // stale partial file; start over on the next attempt
+        return "", 0, fmt.Errorf("download failed: %s", resp.Status)
+    default:
+        return "", 0, fmt.Errorf("download failed: %s", resp.Status)
+    }
+
+    meta = partMeta{URL: url, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+    if err := savePartMeta(metaPath, meta); err != nil { return "", 0, err }
+
+    h := sha256.New()
+    flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+    if offset > 0 {
+        if err := hashFile(h, tmp); err != nil { return "", 0, err }
+        flags = os.O_WRONLY | os.O_APPEND
+    }
+    f, err := os.OpenFile(tmp, flags, 0o644)
+    if err != nil { return "", 0, err }
+    defer f.Close()
+
+    progress := func(n int64) { stall.Reset() }
+    if r.Progress != nil {
+        r.Progress(file, offset, total)
+        progress = func(n int64) {
+            stall.Reset()
+            r.Progress(file, offset+n, total)
+        }
+    }
+    n, err := ioCopy(ctx, io.MultiWriter(f, h), resp.Body, progress)
+    if meta.validator() != "" { kept = offset + n }
+    span.SetAttrs(trace.Int("model.download.offset", offset), trace.Int("model.download.bytes", n))
+    if err != nil { return "", kept, err }
+    if total >= 0 && offset+n != total {
+        return "", kept, fmt.Errorf("download incomplete: got %d of %d bytes", offset+n, total)
+    }
+    if err := f.Sync(); err != nil { return "", kept, err }
+    if err := f.Close(); err != nil { return "", kept, err }
+    if err := os.Rename(tmp, local); err != nil { return "", kept, err }
+    os.Remove(metaPath)
+    return hex.EncodeToString(h.Sum(nil)), kept, nil
+}
+
+// client returns an HTTP client honouring the source's timeout for
+// connecting and for response headers.
+func (s Source) client() *http.Client {
+    if s.Timeout <= 0 { return http.DefaultClient }
+    t := http.DefaultTransport.(*http.Transport).Clone()
+    t.DialContext = (&net.Dialer{Timeout: s.Timeout, KeepAlive: 30 * time.Second}).DialContext
+    t.TLSHandshakeTimeout = s.Timeout
+    t.ResponseHeaderTimeout = s.Timeout
+    return &http.Client{Transport: t}
+}
+
+// stallTimer cancels a download that receives no data for its timeout;
+// a zero timeout never fires.
+type stallTimer struct {
+    d      time.Duration
+    t      *time.Timer
+    mu     sync.Mutex
+    killed bool
+}
+
+func newStallTimer(d time.Duration, cancel func()) *stallTimer {
+    s := &stallTimer{d: d}
+    if d > 0 {
+        s.t = time.AfterFunc(d, func() {
+            s.mu.Lock()
+            s.killed = true
+            s.mu.Unlock()
+            cancel()
+        })
+    }
+    return s
+}
+
+func (s *stallTimer) Reset() {
+    if s.t != nil { s.t.Reset(s.d) }
+}
+
+func (s *stallTimer) Stop() {
+    if s.t != nil { s.t.Stop() }
+}
+
+func (s *stallTimer) fired() bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.killed
+}
+
+// parseContentRange parses "bytes start-end/size"; size must be known.
+func parseContentRange(v string) (start, size int64, ok bool) {
+    v, found := strings.CutPrefix(v, "bytes ")
+    if !found { return 0, 0, false }
+    rng, sz, found := strings.Cut(v, "/")
+    if !found { return 0, 0, false }
+    first, _, found := strings.Cut(rng, "-")
+    if !found { return 0, 0, false }
+    start, err1 := strconv.ParseInt(first, 10, 64)
+    size, err2 := strconv.ParseInt(sz, 10, 64)
+    if err1 != nil || err2 != nil { return 0, 0, false }
+    return start, size, true
+}
+
+func hashFile(w io.Writer, path string) error {
+    f, err := os.Open(path)
+    if err != nil { return err }
+    defer f.Close()
+    _, err = io.Copy(w, f)
+    return err
+}
+
+func loadPartMeta(path string) partMeta {
+    var m partMeta
+    if b, err := os.ReadFile(path); err == nil {
+        _ = json.Unmarshal(b, &m)
+    }
+    return m
+}
+
+func savePartMeta(path string, m partMeta) error {
+    b, err := json.Marshal(m)
+    if err != nil { return err }
+    return os.WriteFile(path, b, 0o644)
+}
@@ -0,0 +1,218 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer serves content with range support, unless noRanges is set,
+// and aborts each response after dropAfter bytes until it has been cut off
+// drops times.
+type flakyServer struct {
+	mu        sync.Mutex
+	content   []byte
+	etag      string
+	noRanges  bool
+	dropAfter int
+	drops     int
+	ranges    []string
+	ifRanges  []string
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.ifRanges = append(s.ifRanges, r.Header.Get("If-Range"))
+	drop := s.drops > 0
+	if drop {
+		s.drops--
+	}
+	content, etag := s.content, s.etag
+	s.mu.Unlock()
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if s.noRanges {
+		r.Header.Del("Range")
+	}
+	if drop {
+		w = &cutWriter{ResponseWriter: w, left: s.dropAfter}
+	}
+	http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(content))
+}
+
+// cutWriter aborts the connection once left bytes have been written.
+type cutWriter struct {
+	http.ResponseWriter
+	left int
+}
+
+func (c *cutWriter) Write(p []byte) (int, error) {
+	if len(p) > c.left {
+		c.ResponseWriter.Write(p[:c.left])
+		c.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	c.left -= len(p)
+	return c.ResponseWriter.Write(p)
+}
+
+func testContent(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestFSRepo_Ensure_ResumesInterruptedDownload(t *testing.T) {
+	content := testContent(100000)
+	sum := sha256.Sum256(content)
+	srv := &flakyServer{content: content, etag: `"v1"`, dropAfter: 30000, drops: 3}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	var lastDone, lastTotal int64
+	repo := &FSRepo{
+		BaseURL:    ts.URL,
+		CacheDir:   t.TempDir(),
+		Checksums:  map[string]string{"model.bin": hex.EncodeToString(sum[:])},
+		Retry:      1,
+		RetryDelay: time.Millisecond,
+		Progress:   func(_ string, done, total int64) { lastDone, lastTotal = done, total },
+	}
+	path, err := repo.Ensure(context.Background(), "model.bin")
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content differs (len %d)", len(got))
+	}
+	if lastDone != int64(len(content)) || lastTotal != int64(len(content)) {
+		t.Errorf("last progress = %d/%d, want %d/%d", lastDone, lastTotal, len(content), len(content))
+	}
+	if len(srv.ranges) != 4 || srv.ranges[0] != "" {
+		t.Fatalf("requests ranges = %q, want a full request then 3 resumes", srv.ranges)
+	}
+	for i, rg := range srv.ranges[1:] {
+		if !strings.HasPrefix(rg, "bytes=") || srv.ifRanges[i+1] != `"v1"` {
+			t.Errorf("resume %d: Range %q If-Range %q", i, rg, srv.ifRanges[i+1])
+		}
+	}
+	for _, leftover := range []string{path + ".part", path + ".part.json"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed after a complete download", filepath.Base(leftover))
+		}
+	}
+}
+
+func TestFSRepo_Ensure_GivesUpOnDroppingServer(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		srv  *flakyServer
+		want int
+	}{
+		// Nothing to resume from: every attempt counts
+		{"no validator", &flakyServer{dropAfter: 10000}, 3},
+		// Each attempt starts over and gets no further than the first
+		{"no ranges", &flakyServer{etag: `"v1"`, noRanges: true, dropAfter: 10000}, 4},
+		// Each attempt gets further, but only maxResumes are forgiven
+		{"slow progress", &flakyServer{etag: `"v1"`, dropAfter: 100}, 3 + maxResumes},
+	} {
+		c.srv.content = testContent(100000)
+		c.srv.drops = 1 << 30
+		ts := httptest.NewServer(c.srv)
+		repo := &FSRepo{BaseURL: ts.URL, CacheDir: t.TempDir(), Retry: 3, RetryDelay: time.Microsecond}
+		if _, err := repo.Ensure(context.Background(), "model.bin"); err == nil {
+			t.Errorf("%s: expected the download to fail", c.name)
+		}
+		ts.Close()
+		if len(c.srv.ranges) != c.want {
+			t.Errorf("%s: %d attempts, want %d", c.name, len(c.srv.ranges), c.want)
+		}
+	}
+}
+
+func TestFSRepo_Ensure_RestartsWhenRemoteChanged(t *testing.T) {
+	old := testContent(50000)
+	cacheDir := t.TempDir()
+	local := filepath.Join(cacheDir, "model.bin")
+	// A partial download of an older revision of the file.
+	if err := os.WriteFile(local+".part", old[:20000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := bytes.Repeat([]byte("new"), 20000)
+	srv := &flakyServer{content: content, etag: `"v2"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	if err := savePartMeta(local+".part.json", partMeta{URL: ts.URL + "/model.bin", ETag: `"v1"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &FSRepo{BaseURL: ts.URL, CacheDir: cacheDir, RetryDelay: time.Millisecond}
+	path, err := repo.Ensure(context.Background(), "model.bin")
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if srv.ranges[0] != "bytes=20000-" || srv.ifRanges[0] != `"v1"` {
+		t.Errorf("first request Range %q If-Range %q", srv.ranges[0], srv.ifRanges[0])
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, content) {
+		t.Fatal("stale partial data was kept after the remote file changed")
+	}
+}
+
+func TestFSRepo_Ensure_ResumedChecksumMismatch(t *testing.T) {
+	content := testContent(40000)
+	srv := &flakyServer{content: content, etag: `"v1"`, dropAfter: 10000, drops: 1}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	repo := &FSRepo{
+		BaseURL:    ts.URL,
+		CacheDir:   t.TempDir(),
+		Checksums:  map[string]string{"model.bin": strings.Repeat("0", 64)},
+		Retry:      1,
+		RetryDelay: time.Millisecond,
+	}
+	if _, err := repo.Ensure(context.Background(), "model.bin"); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+	if _, err := os.Stat(filepath.Join(repo.CacheDir, "model.bin")); !os.IsNotExist(err) {
+		t.Error("corrupt model should be removed")
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		in          string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-0/1", 0, 1, true},
+		{"bytes 100-199/*", 0, 0, false},
+		{"items 1-2/3", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, size, ok := parseContentRange(tt.in)
+		if start != tt.start || size != tt.size || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v", tt.in, start, size, ok)
+		}
+	}
+}
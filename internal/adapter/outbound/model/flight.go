@@ -0,0 +1,54 @@
+package model
+
+import (
+    "context"
+    "errors"
+    "sync"
+)
+
+// flightGroup deduplicates concurrent Ensure calls for the same model file so
+// that only one of them downloads it; the others wait for its result.
+type flightGroup struct {
+    mu    sync.Mutex
+    calls map[string]*flightCall
+}
+
+type flightCall struct {
+    done chan struct{}
+    path string
+    err  error
+}
+
+// do runs fn once per key at a time. Callers arriving while fn runs share its
+// result, unless it failed only because the first caller's context ended, in
+// which case a caller whose context is still live takes over.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (string, error)) (string, error) {
+    for {
+        g.mu.Lock()
+        if g.calls == nil { g.calls = map[string]*flightCall{} }
+        if c, ok := g.calls[key]; ok {
+            g.mu.Unlock()
+            select {
+            case <-c.done:
+            case <-ctx.Done():
+                return "", ctx.Err()
+            }
+            if isContextErr(c.err) && ctx.Err() == nil { continue }
+            return c.path, c.err
+        }
+        c := &flightCall{done: make(chan struct{})}
+        g.calls[key] = c
+        g.mu.Unlock()
+
+        c.path, c.err = fn()
+        g.mu.Lock()
+        delete(g.calls, key)
+        g.mu.Unlock()
+        close(c.done)
+        return c.path, c.err
+    }
+}
+
+func isContextErr(err error) bool {
+    return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
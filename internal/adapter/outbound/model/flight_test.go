@@ -0,0 +1,118 @@
+package model
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowServer counts requests and holds each one briefly so concurrent
+// Ensure calls overlap.
+func slowServer(t *testing.T, hits *int32) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("model data"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestFSRepo_Ensure_ConcurrentCallsShareDownload(t *testing.T) {
+	var hits int32
+	ts := slowServer(t, &hits)
+	repo := &FSRepo{BaseURL: ts.URL, CacheDir: t.TempDir()}
+
+	// Aliases and file names of the same model share one download.
+	names := []string{"tiny.en", "ggml-tiny.en.bin", "tiny.en", "ggml-tiny.en", "tiny.en", "tiny.en"}
+	var wg sync.WaitGroup
+	paths := make([]string, len(names))
+	errs := make([]error, len(names))
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			paths[i], errs[i] = repo.Ensure(context.Background(), name)
+		}()
+	}
+	wg.Wait()
+	for i := range names {
+		if errs[i] != nil {
+			t.Fatalf("Ensure(%q) error = %v", names[i], errs[i])
+		}
+		if paths[i] != paths[0] {
+			t.Errorf("Ensure(%q) = %q, want %q", names[i], paths[i], paths[0])
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hit %d times, want 1", hits)
+	}
+}
+
+func TestFSRepo_Ensure_SharedCacheAcrossRepos(t *testing.T) {
+	// Separate repos over one directory stand in for separate processes:
+	// they do not share a flight group, only the file lock.
+	var hits int32
+	ts := slowServer(t, &hits)
+	cacheDir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repo := &FSRepo{BaseURL: ts.URL, CacheDir: cacheDir}
+			_, errs[i] = repo.Ensure(context.Background(), "base.en")
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Ensure() error = %v", err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hit %d times, want 1", hits)
+	}
+	if got, _ := os.ReadFile(filepath.Join(cacheDir, "ggml-base.en.bin")); string(got) != "model data" {
+		t.Errorf("cached content = %q", got)
+	}
+}
+
+func TestFlightGroup_WaiterTakesOverCanceledCall(t *testing.T) {
+	var g flightGroup
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	leaderDone := make(chan error)
+	go func() {
+		_, err := g.do(leaderCtx, "k", func() (string, error) {
+			close(started)
+			<-leaderCtx.Done()
+			return "", leaderCtx.Err()
+		})
+		leaderDone <- err
+	}()
+	<-started
+
+	waiter := make(chan string)
+	go func() {
+		path, _ := g.do(context.Background(), "k", func() (string, error) { return "from waiter", nil })
+		waiter <- path
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-leaderDone; err != context.Canceled {
+		t.Fatalf("leader error = %v", err)
+	}
+	if got := <-waiter; got != "from waiter" {
+		t.Errorf("waiter got %q, want its own result after the leader was canceled", got)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build ignore
+
+// gendigests writes digests.go from the SHA-256 digests Hugging Face reports
+// for the ggml models published with whisper.cpp. Run it with go generate
+// when models are added to the built-in manifest.
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "go/format"
+    "log"
+    "net/http"
+    "os"
+    "sort"
+    "strings"
+)
+
+// tree lists the files of the repository DefaultBaseURL downloads from; for
+// files stored with LFS it includes their SHA-256.
+const tree = "https://huggingface.co/api/models/ggerganov/whisper.cpp/tree/main"
+
+type file struct {
+    Path string `json:"path"`
+    LFS  *struct {
+        Oid string `json:"oid"`
+    } `json:"lfs"`
+}
+
+func main() {
+    resp, err := http.Get(tree)
+    if err != nil { log.Fatal(err) }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { log.Fatalf("%s: %s", tree, resp.Status) }
+    var files []file
+    if err := json.NewDecoder(resp.Body).Decode(&files); err != nil { log.Fatalf("%s: %v", tree, err) }
+    sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+    var buf bytes.Buffer
+    buf.WriteString("// Code generated by gendigests.go; DO NOT EDIT.\n\npackage model\n\n")
+    buf.WriteString("// builtinDigests holds the published SHA-256 of each built-in model file.\n")
+    buf.WriteString("var builtinDigests = map[string]string{\n")
+    n := 0
+    for _, f := range files {
+        if f.LFS == nil || !strings.HasPrefix(f.Path, "ggml-") || !strings.HasSuffix(f.Path, ".bin") { continue }
+        fmt.Fprintf(&buf, "%q: %q,\n", f.Path, strings.TrimPrefix(f.LFS.Oid, "sha256:"))
+        n++
+    }
+    buf.WriteString("}\n")
+    if n == 0 { log.Fatalf("%s lists no models", tree) }
+    src, err := format.Source(buf.Bytes())
+    if err != nil { log.Fatal(err) }
+    if err := os.WriteFile("digests.go", src, 0o644); err != nil { log.Fatal(err) }
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+    "encoding/binary"
+    "fmt"
+    "io"
+    "os"
+)
+
+// ggmlMagic is "ggml" read as a little-endian uint32.
+const ggmlMagic = 0x67676d6c
+
+// qntVersionFactor splits the stored ftype into quantization version and type.
+const qntVersionFactor = 1000
+
+// Header holds the hyperparameters stored at the start of a whisper ggml model.
+type Header struct {
+    NVocab      int32
+    NAudioCtx   int32
+    NAudioState int32
+    NAudioHead  int32
+    NAudioLayer int32
+    NTextCtx    int32
+    NTextState  int32
+    NTextHead   int32
+    NTextLayer  int32
+    NMels       int32
+    FType       int32 // weight type, without the quantization version
+    QntVersion  int32
+}
+
+// ReadHeader reads the ggml header of a whisper model file.
+func ReadHeader(path string) (Header, error) {
+    f, err := os.Open(path)
+    if err != nil { return Header{}, err }
+    defer f.Close()
+    return readHeader(f)
+}
+
+func readHeader(r io.Reader) (Header, error) {
+    var magic uint32
+    if err := binary.Read(r, binary.LittleEndian, &magic); err != nil { return Header{}, fmt.Errorf("read magic: %w", err) }
+    if magic != ggmlMagic { return Header{}, fmt.Errorf("not a ggml model (magic %#x)", magic) }
+    var hp [11]int32
+    if err := binary.Read(r, binary.LittleEndian, &hp); err != nil { return Header{}, fmt.Errorf("read hparams: %w", err) }
+    return Header{
+        NVocab: hp[0], NAudioCtx: hp[1], NAudioState: hp[2], NAudioHead: hp[3], NAudioLayer: hp[4],
+        NTextCtx: hp[5], NTextState: hp[6], NTextHead: hp[7], NTextLayer: hp[8], NMels: hp[9],
+        FType: hp[10] % qntVersionFactor, QntVersion: hp[10] / qntVersionFactor,
+    }, nil
+}
+
+// Multilingual reports whether the vocabulary includes language tokens;
+// English-only models have 51864 tokens.
+func (h Header) Multilingual() bool { return h.NVocab >= 51865 }
+
+// Architecture names the whisper model size from the encoder depth.
+func (h Header) Architecture() string {
+    switch h.NAudioLayer {
+    case 4:
+        return "tiny"
+    case 6:
+        return "base"
+    case 12:
+        return "small"
+    case 24:
+        return "medium"
+    case 32:
+        if h.NTextLayer == 4 { return "large-v3-turbo" }
+        if h.NMels == 128 { return "large-v3" }
+        return "large"
+    }
+    return fmt.Sprintf("unknown (%d encoder layers)", h.NAudioLayer)
+}
+
+// Quantization names the weight type (GGML_FTYPE_MOSTLY_*).
+func (h Header) Quantization() string {
+    switch h.FType {
+    case 0:
+        return "f32"
+    case 1:
+        return "f16"
+    case 2:
+        return "q4_0"
+    case 3:
+        return "q4_1"
+    case 7:
+        return "q8_0"
+    case 8:
+        return "q5_0"
+    case 9:
+        return "q5_1"
+    case 10:
+        return "q2_k"
+    case 11:
+        return "q3_k"
+    case 12:
+        return "q4_k"
+    case 13:
+        return "q5_k"
+    case 14:
+        return "q6_k"
+    }
+    return fmt.Sprintf("ftype %d", h.FType)
+}
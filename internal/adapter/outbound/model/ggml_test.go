@@ -0,0 +1,50 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeHeader(t *testing.T, path string, hp [11]int32) {
+	t.Helper()
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(ggmlMagic))
+	binary.Write(&buf, binary.LittleEndian, hp)
+	buf.WriteString("weights...")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReadHeader tests decoding of whisper ggml hyperparameters
+func TestReadHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ggml-base.en-q5_1.bin")
+	// base.en: 6 layers, English-only vocab, q5_1 with quantization version 1
+	writeHeader(t, path, [11]int32{51864, 1500, 512, 8, 6, 448, 512, 8, 6, 80, 1009})
+
+	h, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("ReadHeader() error = %v", err)
+	}
+	if h.Architecture() != "base" || h.Quantization() != "q5_1" || h.QntVersion != 1 || h.Multilingual() {
+		t.Errorf("unexpected header: %+v (%s, %s)", h, h.Architecture(), h.Quantization())
+	}
+
+	large := [11]int32{51866, 1500, 1280, 20, 32, 448, 1280, 20, 4, 128, 1}
+	writeHeader(t, path, large)
+	if h, _ := ReadHeader(path); h.Architecture() != "large-v3-turbo" || h.Quantization() != "f16" || !h.Multilingual() {
+		t.Errorf("unexpected header: %+v", h)
+	}
+}
+
+// TestReadHeader_NotGGML tests rejection of non-model files
+func TestReadHeader_NotGGML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.bin")
+	os.WriteFile(path, []byte("definitely not a model file"), 0644)
+	if _, err := ReadHeader(path); err == nil {
+		t.Error("expected error for non-ggml file")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package model
+
+import "context"
+
+// lockFile is a no-op where flock is unavailable; concurrent Ensure calls in
+// one process are still deduplicated, but separate processes sharing a
+// cache directory are not.
+func lockFile(ctx context.Context, path string) (func(), error) {
+    return func() {}, nil
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package model
+
+import (
+    "context"
+    "errors"
+    "os"
+    "syscall"
+    "time"
+)
+
+// lockFile takes an exclusive advisory lock on path, creating it if needed,
+// so processes sharing a cache directory (e.g. pods on one volume) do not
+// download the same model at once. It polls until the lock is free or ctx
+// ends; the returned func releases it.
+func lockFile(ctx context.Context, path string) (func(), error) {
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+    if err != nil { return nil, err }
+    for {
+        err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+        if err == nil { break }
+        if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+            f.Close()
+            return nil, err
+        }
+        select {
+        case <-time.After(lockPollInterval):
+        case <-ctx.Done():
+            f.Close()
+            return nil, ctx.Err()
+        }
+    }
+    return func() {
+        _ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+        f.Close()
+    }, nil
+}
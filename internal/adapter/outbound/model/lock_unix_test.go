@@ -0,0 +1,33 @@
+//go:build unix
+
+package model
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile_Exclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.bin.lock")
+	unlock, err := lockFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPollInterval)
+	defer cancel()
+	if _, err := lockFile(ctx, path); err != context.DeadlineExceeded {
+		t.Fatalf("second lock error = %v, want deadline exceeded", err)
+	}
+
+	unlock()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock2, err := lockFile(ctx, path)
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+	unlock2()
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// ManifestEntry describes a model known by a friendly name.
+type ManifestEntry struct {
+    Name         string `json:"name"`                   // alias, e.g. "base.en" or "small.en-q5_1"
+    File         string `json:"file"`                   // file name in the cache and on the remote
+    Size         int64  `json:"size,omitempty"`         // download size in bytes; approximate for built-in entries
+    SHA256       string `json:"sha256,omitempty"`       // expected digest; empty means pin on first use
+    Quantization string `json:"quantization,omitempty"` // "f16", "q5_0", "q5_1", "q8_0"
+    Multilingual bool   `json:"multilingual"`
+}
+
+// Manifest maps friendly model names to files and digests.
+type Manifest struct {
+    Models []ManifestEntry `json:"models"`
+}
+
+const mib = 1 << 20
+
+//go:generate go run gendigests.go
+
+// builtinManifest lists the ggml models published with whisper.cpp. Their
+// digests come from builtinDigests; a manifest file or FSRepo.Checksums may
+// override them. Entries without one have the digest of their first
+// download pinned (see verify).
+var builtinManifest = Manifest{Models: []ManifestEntry{
+    {Name: "tiny", Size: 75 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "tiny.en", Size: 75 * mib, Quantization: "f16"},
+    {Name: "tiny-q5_1", Size: 31 * mib, Quantization: "q5_1", Multilingual: true},
+    {Name: "tiny.en-q5_1", Size: 31 * mib, Quantization: "q5_1"},
+    {Name: "tiny-q8_0", Size: 42 * mib, Quantization: "q8_0", Multilingual: true},
+    {Name: "base", Size: 142 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "base.en", Size: 142 * mib, Quantization: "f16"},
+    {Name: "base-q5_1", Size: 57 * mib, Quantization: "q5_1", Multilingual: true},
+    {Name: "base.en-q5_1", Size: 57 * mib, Quantization: "q5_1"},
+    {Name: "base-q8_0", Size: 78 * mib, Quantization: "q8_0", Multilingual: true},
+    {Name: "small", Size: 466 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "small.en", Size: 466 * mib, Quantization: "f16"},
+    {Name: "small-q5_1", Size: 181 * mib, Quantization: "q5_1", Multilingual: true},
+    {Name: "small.en-q5_1", Size: 181 * mib, Quantization: "q5_1"},
+    {Name: "small-q8_0", Size: 252 * mib, Quantization: "q8_0", Multilingual: true},
+    {Name: "medium", Size: 1463 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "medium.en", Size: 1463 * mib, Quantization: "f16"},
+    {Name: "medium-q5_0", Size: 514 * mib, Quantization: "q5_0", Multilingual: true},
+    {Name: "medium.en-q5_0", Size: 514 * mib, Quantization: "q5_0"},
+    {Name: "medium-q8_0", Size: 785 * mib, Quantization: "q8_0", Multilingual: true},
+    {Name: "large-v1", Size: 2951 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "large-v2", Size: 2951 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "large-v2-q5_0", Size: 1080 * mib, Quantization: "q5_0", Multilingual: true},
+    {Name: "large-v2-q8_0", Size: 1660 * mib, Quantization: "q8_0", Multilingual: true},
+    {Name: "large-v3", Size: 2951 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "large-v3-q5_0", Size: 1080 * mib, Quantization: "q5_0", Multilingual: true},
+    {Name: "large-v3-turbo", Size: 1550 * mib, Quantization: "f16", Multilingual: true},
+    {Name: "large-v3-turbo-q5_0", Size: 547 * mib, Quantization: "q5_0", Multilingual: true},
+    {Name: "large-v3-turbo-q8_0", Size: 834 * mib, Quantization: "q8_0", Multilingual: true},
+}}
+
+func init() {
+    for i := range builtinManifest.Models {
+        e := &builtinManifest.Models[i]
+        e.File = "ggml-" + e.Name + ".bin"
+        e.SHA256 = builtinDigests[e.File]
+    }
+}
+
+// BuiltinManifest returns a copy of the built-in manifest.
+func BuiltinManifest() Manifest {
+    return Manifest{Models: append([]ManifestEntry(nil), builtinManifest.Models...)}
+}
+
+// LoadManifest reads a JSON manifest file.
+func LoadManifest(path string) (Manifest, error) {
+    var m Manifest
+    b, err := os.ReadFile(path)
+    if err != nil { return m, err }
+    if err := json.Unmarshal(b, &m); err != nil { return m, fmt.Errorf("parse manifest %s: %w", path, err) }
+    for i, e := range m.Models {
+        if e.Name == "" { return m, fmt.Errorf("manifest %s: entry %d has no name", path, i) }
+        if e.File == "" { m.Models[i].File = "ggml-" + e.Name + ".bin" }
+    }
+    return m, nil
+}
+
+// Merge returns m with the entries of override added; entries with the same
+// name are replaced.
+func (m Manifest) Merge(override Manifest) Manifest {
+    byName := map[string]ManifestEntry{}
+    var order []string
+    for _, e := range append(append([]ManifestEntry(nil), m.Models...), override.Models...) {
+        if _, ok := byName[e.Name]; !ok { order = append(order, e.Name) }
+        byName[e.Name] = e
+    }
+    out := Manifest{Models: make([]ManifestEntry, 0, len(order))}
+    for _, n := range order { out.Models = append(out.Models, byName[n]) }
+    return out
+}
+
+// Lookup finds an entry by alias ("base.en"), file name ("ggml-base.en.bin")
+// or file stem ("ggml-base.en").
+func (m Manifest) Lookup(name string) (ManifestEntry, bool) {
+    for _, e := range m.Models {
+        if e.Name == name || e.File == name || strings.TrimSuffix(e.File, ".bin") == name {
+            return e, true
+        }
+    }
+    return ManifestEntry{}, false
+}
+
+// Names returns the aliases in the manifest, sorted.
+func (m Manifest) Names() []string {
+    names := make([]string, 0, len(m.Models))
+    for _, e := range m.Models { names = append(names, e.Name) }
+    sort.Strings(names)
+    return names
+}
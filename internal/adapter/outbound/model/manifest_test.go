@@ -0,0 +1,172 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestManifest_Lookup tests alias, file name and stem resolution
+func TestManifest_Lookup(t *testing.T) {
+	m := BuiltinManifest()
+	for _, name := range []string{"base.en", "ggml-base.en.bin", "ggml-base.en"} {
+		e, ok := m.Lookup(name)
+		if !ok || e.File != "ggml-base.en.bin" {
+			t.Errorf("Lookup(%q) = %+v, %v", name, e, ok)
+		}
+	}
+	if e, _ := m.Lookup("small.en-q5_1"); e.Quantization != "q5_1" || e.Multilingual {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if _, ok := m.Lookup("no-such-model"); ok {
+		t.Error("expected unknown model to be absent")
+	}
+}
+
+// TestManifest_Merge tests that local entries replace built-in ones
+func TestManifest_Merge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	os.WriteFile(path, []byte(`{"models":[{"name":"base.en","sha256":"abc"},{"name":"custom","file":"my.bin"}]}`), 0644)
+
+	local, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	m := BuiltinManifest().Merge(local)
+	if e, _ := m.Lookup("base.en"); e.SHA256 != "abc" || e.File != "ggml-base.en.bin" {
+		t.Errorf("override not applied: %+v", e)
+	}
+	if e, ok := m.Lookup("custom"); !ok || e.File != "my.bin" {
+		t.Errorf("custom entry missing: %+v", e)
+	}
+	if len(m.Models) != len(builtinManifest.Models)+1 {
+		t.Errorf("unexpected model count %d", len(m.Models))
+	}
+}
+
+// TestFSRepo_Ensure_Alias tests that aliases download the manifest file name
+// and are verified against the manifest digest
+func TestFSRepo_Ensure_Alias(t *testing.T) {
+	content := []byte("base english model")
+	sum := sha256.Sum256(content)
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	manifest := filepath.Join(cacheDir, "manifest.json")
+	os.WriteFile(manifest, []byte(`{"models":[{"name":"base.en","sha256":"`+hex.EncodeToString(sum[:])+`"}]}`), 0644)
+
+	repo := &FSRepo{BaseURL: server.URL, CacheDir: cacheDir, Retry: 1}
+	got, err := repo.Ensure(context.Background(), "base.en")
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if gotPath != "/ggml-base.en.bin" || got != filepath.Join(cacheDir, "ggml-base.en.bin") {
+		t.Errorf("Ensure() = %q (fetched %q)", got, gotPath)
+	}
+
+	// The file name resolves to the same cached model without a download
+	gotPath = ""
+	if again, err := repo.Ensure(context.Background(), "ggml-base.en.bin"); err != nil || again != got || gotPath != "" {
+		t.Errorf("second Ensure() = %q, %v (fetched %q)", again, err, gotPath)
+	}
+}
+
+// TestFSRepo_Ensure_PinsFirstDigestOfRawFile tests trust-on-first-use
+// verification, which only applies to files outside the manifest
+func TestFSRepo_Ensure_PinsFirstDigestOfRawFile(t *testing.T) {
+	cacheDir := t.TempDir()
+	path := filepath.Join(cacheDir, "custom.bin")
+	os.WriteFile(path, []byte("original"), 0644)
+
+	repo := &FSRepo{CacheDir: cacheDir}
+	if _, err := repo.Ensure(context.Background(), "custom.bin"); err != nil {
+		t.Fatalf("first Ensure() error = %v", err)
+	}
+
+	// Tamper with the cached file; the pinned digest no longer matches
+	os.WriteFile(path, []byte("tampered"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if _, err := repo.Ensure(context.Background(), "custom.bin"); err == nil {
+		t.Fatal("expected checksum mismatch for tampered model")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("without a BaseURL the mismatching file must be kept")
+	}
+}
+
+// TestFSRepo_Ensure_BuiltinManifestOnly tests that a built-in model works
+// with nothing but the built-in manifest, pinning its first download when
+// the manifest has no digest for it
+func TestFSRepo_Ensure_BuiltinManifestOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("base model"))
+	}))
+	defer server.Close()
+	withBuiltinDigest(t, "ggml-base.en.bin", "")
+
+	cacheDir := t.TempDir()
+	repo := &FSRepo{BaseURL: server.URL, CacheDir: cacheDir}
+	path, err := repo.Ensure(context.Background(), "base.en")
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if path != filepath.Join(cacheDir, "ggml-base.en.bin") {
+		t.Errorf("Ensure() = %q", path)
+	}
+	if rec := loadVerified(cacheDir)["ggml-base.en.bin"]; rec.SHA256 != digestOf("base model") {
+		t.Errorf("first download not pinned: %+v", rec)
+	}
+}
+
+// TestFSRepo_Ensure_RejectsMismatchOnFirstDownload tests that the built-in
+// digest rejects a tampered file the first time it is fetched
+func TestFSRepo_Ensure_RejectsMismatchOnFirstDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer server.Close()
+	withBuiltinDigest(t, "ggml-tiny.bin", digestOf("genuine"))
+
+	cacheDir := t.TempDir()
+	repo := &FSRepo{BaseURL: server.URL, CacheDir: cacheDir, Retry: 1, RetryDelay: time.Millisecond}
+	if _, err := repo.Ensure(context.Background(), "tiny"); err == nil {
+		t.Fatal("expected the tampered download to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "ggml-tiny.bin")); !os.IsNotExist(err) {
+		t.Error("tampered download left in the cache")
+	}
+	if _, ok := loadVerified(cacheDir)["ggml-tiny.bin"]; ok {
+		t.Error("tampered download recorded as verified")
+	}
+}
+
+// withBuiltinDigest replaces the built-in digest of file for the test.
+func withBuiltinDigest(t *testing.T, file, sum string) {
+	t.Helper()
+	for i := range builtinManifest.Models {
+		if e := &builtinManifest.Models[i]; e.File == file {
+			old := e.SHA256
+			e.SHA256 = sum
+			t.Cleanup(func() { e.SHA256 = old })
+			return
+		}
+	}
+	t.Fatalf("%s is not a built-in model", file)
+}
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
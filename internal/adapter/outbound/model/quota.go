@@ -0,0 +1,141 @@
+package model
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "sync"
+    "time"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+)
+
+var (
+    _ port.ModelRepo   = (*FSRepo)(nil)
+    _ port.ModelHolder = (*FSRepo)(nil)
+    _ port.ModelCache  = (*FSRepo)(nil)
+)
+
+// usageFile is the name of the per-cache record of last-used times and pins.
+const usageFile = ".usage.json"
+
+type usageRecord struct {
+    LastUsed time.Time `json:"last_used"`
+    Pinned   bool      `json:"pinned,omitempty"`
+}
+
+// holds counts in-process users of cached model files, by path.
+type holds struct {
+    mu sync.Mutex
+    n  map[string]int
+}
+
+func (h *holds) held(path string) bool {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    return h.n[path] > 0
+}
+
+// Hold marks a cached model as in use so eviction skips it until release
+// is called. release may be called more than once.
+func (r *FSRepo) Hold(localPath string) (release func()) {
+    h := &r.holds
+    h.mu.Lock()
+    if h.n == nil { h.n = map[string]int{} }
+    h.n[localPath]++
+    h.mu.Unlock()
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            h.mu.Lock()
+            defer h.mu.Unlock()
+            if h.n[localPath]--; h.n[localPath] <= 0 { delete(h.n, localPath) }
+        })
+    }
+}
+
+// touch records that a cached model was just used.
+func (r *FSRepo) touch(cacheDir, file string) {
+    now := time.Now()
+    updateRecords(cacheDir, usageFile, func(m map[string]usageRecord) {
+        u := m[file]
+        u.LastUsed = now
+        m[file] = u
+    })
+}
+
+// Pin marks a model, by alias or file name, as never to be evicted, or
+// clears the mark. The model need not be cached yet.
+func (r *FSRepo) Pin(name string, pinned bool) error {
+    m, err := r.Manifest()
+    if err != nil { return err }
+    e := r.resolve(m, name)
+    cacheDir := r.cacheDir()
+    if err := os.MkdirAll(cacheDir, 0o755); err != nil { return err }
+    updateRecords(cacheDir, usageFile, func(m map[string]usageRecord) {
+        u := m[e.File]
+        u.Pinned = pinned
+        if u == (usageRecord{}) {
+            delete(m, e.File)
+            return
+        }
+        m[e.File] = u
+    })
+    return nil
+}
+
+// pinnedByConfig reports whether FSRepo.Pinned names the file.
+func (r *FSRepo) pinnedByConfig(m Manifest, file string) bool {
+    for _, name := range r.Pinned {
+        if r.resolve(m, name).File == file { return true }
+    }
+    return false
+}
+
+// CacheUsage reports the size of the cache, its quota and, for each cached
+// model, when it was last used and whether it may be evicted.
+func (r *FSRepo) CacheUsage(ctx context.Context) (domain.CacheUsage, error) {
+    models, err := r.Cached()
+    if err != nil { return domain.CacheUsage{}, err }
+    u := domain.CacheUsage{Dir: r.cacheDir(), QuotaBytes: r.MaxCacheBytes}
+    for _, c := range models {
+        u.UsedBytes += c.Size
+        u.Models = append(u.Models, domain.CachedModel{
+            Name: c.Entry.Name, File: c.Entry.File, Size: c.Size,
+            LastUsed: c.LastUsed, Pinned: c.Pinned, InUse: c.InUse,
+        })
+    }
+    return u, nil
+}
+
+// Prune evicts least-recently-used models until the cache fits its quota,
+// returning what was removed. It does nothing when MaxCacheBytes is 0.
+func (r *FSRepo) Prune() ([]CachedModel, error) { return r.evict(0, "") }
+
+// evict removes least-recently-used models until the cache plus reserve
+// bytes fits within MaxCacheBytes. Pinned and held models and the file keep
+// are never removed, so the cache may stay over quota when nothing else
+// can go.
+func (r *FSRepo) evict(reserve int64, keep string) ([]CachedModel, error) {
+    if r.MaxCacheBytes <= 0 { return nil, nil }
+    models, err := r.Cached()
+    if err != nil { return nil, err }
+    var used int64
+    for _, c := range models { used += c.Size }
+    if used+reserve <= r.MaxCacheBytes { return nil, nil }
+
+    sort.SliceStable(models, func(i, j int) bool { return models[i].LastUsed.Before(models[j].LastUsed) })
+    var removed []CachedModel
+    for _, c := range models {
+        if used+reserve <= r.MaxCacheBytes { break }
+        if c.Pinned || c.InUse || c.Entry.File == keep { continue }
+        if err := r.Remove(c.Entry.File); err != nil {
+            return removed, fmt.Errorf("evict %s: %w", c.Entry.File, err)
+        }
+        used -= c.Size
+        removed = append(removed, c)
+    }
+    return removed, nil
+}
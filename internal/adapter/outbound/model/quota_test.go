@@ -0,0 +1,119 @@
+package model
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedCache writes model files of the given sizes and records their last use
+// in the given order, oldest first.
+func seedCache(t *testing.T, cacheDir string, files map[string]int, order []string) {
+	t.Helper()
+	for name, size := range files {
+		if err := os.WriteFile(filepath.Join(cacheDir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := time.Now().Add(-time.Hour)
+	updateRecords(cacheDir, usageFile, func(m map[string]usageRecord) {
+		for i, name := range order {
+			m[name] = usageRecord{LastUsed: base.Add(time.Duration(i) * time.Minute)}
+		}
+	})
+}
+
+func cachedFiles(t *testing.T, repo *FSRepo) string {
+	t.Helper()
+	models, err := repo.Cached()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range models {
+		names = append(names, m.Entry.File)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestFSRepo_Prune_EvictsLeastRecentlyUsed(t *testing.T) {
+	cacheDir := t.TempDir()
+	seedCache(t, cacheDir,
+		map[string]int{"a.bin": 100, "b.bin": 100, "c.bin": 100, "d.bin": 100},
+		[]string{"a.bin", "b.bin", "c.bin", "d.bin"})
+	repo := &FSRepo{CacheDir: cacheDir, MaxCacheBytes: 250, Pinned: []string{"a.bin"}}
+	if err := repo.Pin("b.bin", true); err != nil {
+		t.Fatal(err)
+	}
+	release := repo.Hold(filepath.Join(cacheDir, "c.bin"))
+
+	// a is pinned by config, b by Pin and c is held: only d can go, and the
+	// cache stays over quota.
+	removed, err := repo.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Entry.File != "d.bin" {
+		t.Fatalf("removed = %+v, want d.bin", removed)
+	}
+
+	release()
+	if err := repo.Pin("b.bin", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedFiles(t, repo); got != "a.bin,c.bin" {
+		t.Errorf("cached after prune = %s, want a.bin,c.bin", got)
+	}
+}
+
+func TestFSRepo_Ensure_EvictsToFitQuota(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 100))
+	}))
+	defer ts.Close()
+	cacheDir := t.TempDir()
+	seedCache(t, cacheDir, map[string]int{"old.bin": 100, "recent.bin": 100}, []string{"old.bin", "recent.bin"})
+
+	repo := &FSRepo{BaseURL: ts.URL, CacheDir: cacheDir, MaxCacheBytes: 200}
+	if _, err := repo.Ensure(context.Background(), "new.bin"); err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if got := cachedFiles(t, repo); got != "new.bin,recent.bin" {
+		t.Errorf("cached = %s, want new.bin,recent.bin", got)
+	}
+
+	// Using a model refreshes it, so the other one is evicted next.
+	if _, err := repo.Ensure(context.Background(), "recent.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Ensure(context.Background(), "newer.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedFiles(t, repo); got != "newer.bin,recent.bin" {
+		t.Errorf("cached = %s, want newer.bin,recent.bin", got)
+	}
+}
+
+func TestFSRepo_CacheUsage(t *testing.T) {
+	cacheDir := t.TempDir()
+	seedCache(t, cacheDir, map[string]int{"ggml-tiny.en.bin": 30, "x.bin": 12}, []string{"x.bin"})
+	repo := &FSRepo{CacheDir: cacheDir, MaxCacheBytes: 1000, Pinned: []string{"tiny.en"}}
+	u, err := repo.CacheUsage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.UsedBytes != 42 || u.QuotaBytes != 1000 || len(u.Models) != 2 {
+		t.Fatalf("usage = %+v", u)
+	}
+	if u.Models[0].Name != "tiny.en" || !u.Models[0].Pinned || u.Models[1].Pinned {
+		t.Errorf("models = %+v", u.Models)
+	}
+}
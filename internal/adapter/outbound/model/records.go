@@ -0,0 +1,47 @@
+package model
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "sync"
+)
+
+// recordsMu serialises read-modify-write cycles of the bookkeeping files in
+// the cache directory within a process; across processes the atomic rename
+// keeps each file intact, though a concurrent update may be lost.
+var recordsMu sync.Mutex
+
+// loadRecords reads a JSON map kept in the cache directory; a missing or
+// corrupt file yields an empty map.
+func loadRecords[T any](cacheDir, name string) map[string]T {
+    m := map[string]T{}
+    if b, err := os.ReadFile(filepath.Join(cacheDir, name)); err == nil {
+        _ = json.Unmarshal(b, &m)
+    }
+    return m
+}
+
+// updateRecords applies fn to a record file and saves it. Errors are ignored:
+// the records are caches and hints, never the source of truth.
+func updateRecords[T any](cacheDir, name string, fn func(map[string]T)) {
+    recordsMu.Lock()
+    defer recordsMu.Unlock()
+    m := loadRecords[T](cacheDir, name)
+    fn(m)
+    _ = saveRecords(cacheDir, name, m)
+}
+
+func saveRecords[T any](cacheDir, name string, m map[string]T) error {
+    b, err := json.MarshalIndent(m, "", "  ")
+    if err != nil { return err }
+    f, err := os.CreateTemp(cacheDir, name+".*.tmp")
+    if err != nil { return err }
+    _, err = f.Write(b)
+    if cerr := f.Close(); err == nil { err = cerr }
+    if err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+    return os.Rename(f.Name(), filepath.Join(cacheDir, name))
+}
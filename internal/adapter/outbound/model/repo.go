@@ -2,11 +2,11 @@ package model
 
 import (
     "context"
+    "errors"
     "crypto/sha256"
     "encoding/hex"
     "fmt"
     "io"
-    "net/http"
     "os"
     "path/filepath"
     "strings"
@@ -14,82 +14,144 @@ import (
 )
 
 type FSRepo struct {
-    BaseURL   string            // optional remote base
-    CacheDir  string            // defaults to user cache dir
-    Checksums map[string]string // optional sha256 for model files
-    Retry     int               // download retries (default 2)
+    BaseURL      string            // optional remote base, tried after Sources
+    Sources      []Source          // ordered model sources: local directories and HTTP mirrors
+    CacheDir     string            // defaults to user cache dir
+    Checksums    map[string]string // optional sha256 by model name or file name; overrides the manifest
+    Retry        int               // download retries (default 2)
+    RetryDelay   time.Duration     // base backoff between retries (default 1s)
+    ManifestPath string            // optional manifest merged over the built-in one; defaults to <CacheDir>/manifest.json
+
+    // MaxCacheBytes bounds the total size of cached models; when a download
+    // would exceed it, least-recently-used models are evicted. 0 means no limit.
+    MaxCacheBytes int64
+    // Pinned lists models (by alias or file name) that are never evicted, in
+    // addition to those pinned with Pin.
+    Pinned []string
+
+    // Progress, if set, is called as a download proceeds; done includes
+    // bytes from a resumed partial download, and total is -1 when the server
+    // does not report a length.
+    Progress func(file string, done, total int64)
+
+    // CacheLookup, if set, is called when a model file is looked up in the
+    // cache, with whether it was there. Concurrent lookups of the same file
+    // share one call.
+    CacheLookup func(file string, hit bool)
+
+    flights flightGroup
+    holds   holds
 }
 
+// DefaultBaseURL is where whisper.cpp publishes its ggml models.
+const DefaultBaseURL = "https://huggingface.co/ggerganov/whisper.cpp/resolve/main"
+
+// lockPollInterval is how often a caller waiting on another process's
+// download retries the cache lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// Ensure resolves modelName to a verified local model file: from the cache,
+// in place from a local source directory, or downloaded from the first HTTP
+// source that serves it. modelName may be an existing file path, a manifest
+// alias ("base.en"), a file name ("ggml-base.en.bin") or, for models not in
+// the manifest, a raw file name to look up in the sources. There is no
+// default model here: callers pass the configured one.
 func (r *FSRepo) Ensure(ctx context.Context, modelName string) (string, error) {
-    if modelName == "" { modelName = "ggml-tiny.en.bin" }
+    if modelName == "" { return "", errors.New("model name required") }
     // If modelName is a path that exists, return it
     if fi, err := os.Stat(modelName); err == nil && fi.Mode().IsRegular() {
         abs, _ := filepath.Abs(modelName)
         return abs, nil
     }
-    // Resolve cache path
-    cacheDir := r.CacheDir
-    if cacheDir == "" {
-        if d, err := os.UserCacheDir(); err == nil {
-            cacheDir = filepath.Join(d, "gosper", "models")
-        } else {
-            cacheDir = filepath.Join(os.TempDir(), "gosper", "models")
-        }
-    }
+    cacheDir := r.cacheDir()
     if err := os.MkdirAll(cacheDir, 0o755); err != nil {
         return "", err
     }
-    local := filepath.Join(cacheDir, modelName)
-    if fi, err := os.Stat(local); err == nil && fi.Mode().IsRegular() {
-        if ok, _ := r.verify(local, modelName); ok {
-            return local, nil
-        }
-        // bad checksum: remove and redownload
-        _ = os.Remove(local)
+    manifest, err := r.Manifest()
+    if err != nil {
+        return "", err
     }
-    // Attempt download if BaseURL configured
-    if r.BaseURL == "" {
-        return "", fmt.Errorf("model %q not found locally; set BaseURL to enable download", modelName)
+    entry := r.resolve(manifest, modelName)
+    if entry.File != filepath.Base(entry.File) {
+        return "", fmt.Errorf("invalid model name %q", modelName)
     }
-    url := fmt.Sprintf("%s/%s", trimSlash(r.BaseURL), modelName)
-    // Download with simple client
-    attempts := r.Retry
-    if attempts <= 0 { attempts = 2 }
-    var lastErr error
-    for i := 0; i < attempts; i++ {
-        req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-        if err != nil { return "", err }
-        resp, err := http.DefaultClient.Do(req)
-        if err != nil { lastErr = err; continue }
-        func() {
-            defer resp.Body.Close()
-            if resp.StatusCode != http.StatusOK { lastErr = fmt.Errorf("download failed: %s", resp.Status); return }
-            tmp := local + ".part"
-            if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil { lastErr = err; return }
-            f, err := os.Create(tmp)
-            if err != nil { lastErr = err; return }
-            defer func(){ f.Close(); os.Remove(tmp) }()
-            if _, err := ioCopy(ctx, f, resp.Body); err != nil { lastErr = err; return }
-            if err := f.Sync(); err != nil { lastErr = err; return }
-            if err := f.Close(); err != nil { lastErr = err; return }
-            if err := os.Rename(tmp, local); err != nil { lastErr = err; return }
-            lastErr = nil
-        }()
-        if lastErr == nil {
-            // verify checksum if available
-            if ok, verr := r.verify(local, modelName); verr == nil && ok {
-                return local, nil
-            } else if r.Checksums != nil { // checksum configured but mismatch
-                _ = os.Remove(local)
-                lastErr = fmt.Errorf("checksum mismatch for %s", modelName)
-            } else {
+    local := filepath.Join(cacheDir, entry.File)
+    path, err := r.flights.do(ctx, entry.File, func() (string, error) {
+        return r.ensureFile(ctx, cacheDir, local, entry, modelName)
+    })
+    if err != nil { return "", err }
+    if path == local { r.touch(cacheDir, entry.File) }
+    return path, nil
+}
+
+// ensureFile returns a verified copy of entry: local, once it holds one, or
+// a file in a local source. Downloads happen under the cache lock, trying
+// HTTP sources in order until one succeeds.
+func (r *FSRepo) ensureFile(ctx context.Context, cacheDir, local string, entry ManifestEntry, modelName string) (string, error) {
+    ok, err := r.cached(cacheDir, local, entry)
+    if err != nil { return "", err }
+    if r.CacheLookup != nil { r.CacheLookup(entry.File, ok) }
+    if ok { return local, nil }
+    sources := r.sources()
+    if len(sources) == 0 {
+        return "", fmt.Errorf("model %q not found locally; set BaseURL or Sources to enable download", modelName)
+    }
+    var errs []error
+    locked := false
+    for _, src := range sources {
+        if dir := src.dir(); dir != "" {
+            p := findLocal(dir, entry)
+            if p == "" { continue }
+            if ok, err := r.verify(cacheDir, p, entry); err != nil || !ok {
+                errs = append(errs, fmt.Errorf("%s: checksum mismatch for %s", src, p))
+                continue
+            }
+            return p, nil
+        }
+        if !locked {
+            unlock, err := lockFile(ctx, local+".lock")
+            if err != nil { return "", err }
+            defer unlock()
+            locked = true
+            // Another process may have finished the download while we waited.
+            if ok, err := r.cached(cacheDir, local, entry); err != nil {
+                return "", err
+            } else if ok {
                 return local, nil
             }
+            // Make room up front when the size is known; eviction is best
+            // effort and a full cache does not stop the download.
+            _, _ = r.evict(entry.Size, entry.File)
         }
-        // simple backoff
-        select { case <-time.After(time.Duration(i+1)*time.Second): case <-ctx.Done(): return "", ctx.Err() }
+        if err := r.fetch(ctx, src, cacheDir, local, entry); err != nil {
+            if ctx.Err() != nil { return "", ctx.Err() }
+            errs = append(errs, fmt.Errorf("%s: %w", src, err))
+            continue
+        }
+        _, _ = r.evict(0, entry.File)
+        return local, nil
+    }
+    if len(errs) == 0 {
+        return "", fmt.Errorf("model %q not found in any local source", modelName)
     }
-    return "", lastErr
+    return "", fmt.Errorf("model %q: %w", modelName, errors.Join(errs...))
+}
+
+// cached reports whether local exists and passes verification. A file that
+// fails is removed so it can be downloaded again, or reported as an error
+// when there is nowhere to download it from.
+func (r *FSRepo) cached(cacheDir, local string, entry ManifestEntry) (bool, error) {
+    fi, err := os.Stat(local)
+    if err != nil || !fi.Mode().IsRegular() { return false, nil }
+    if ok, _ := r.verify(cacheDir, local, entry); ok {
+        return true, nil
+    }
+    if len(r.sources()) == 0 {
+        return false, fmt.Errorf("checksum mismatch for cached model %s", local)
+    }
+    // bad checksum: remove and redownload
+    _ = os.Remove(local)
+    return false, nil
 }
 
 func trimSlash(s string) string {
@@ -98,7 +160,7 @@ func trimSlash(s string) string {
 }
 
 // Simplified io copy with context; avoids pulling extra deps.
-func ioCopy(ctx context.Context, dst *os.File, src io.Reader) (int64, error) {
+func ioCopy(ctx context.Context, dst io.Writer, src io.Reader, progress func(done int64)) (int64, error) {
     buf := make([]byte, 1<<20)
     var n int64
     for {
@@ -107,6 +169,7 @@ func ioCopy(ctx context.Context, dst *os.File, src io.Reader) (int64, error) {
         if nr > 0 {
             nw, ew := dst.Write(buf[:nr])
             if nw > 0 { n += int64(nw) }
+            if progress != nil { progress(n) }
             if ew != nil { return n, ew }
             if nr != nw { return n, io.ErrShortWrite }
         }
@@ -115,13 +178,89 @@ func ioCopy(ctx context.Context, dst *os.File, src io.Reader) (int64, error) {
     return n, nil
 }
 
-// verify checks sha256 if provided in FSRepo.Checksums; if no checksum available, returns (true, nil)
-func (r *FSRepo) verify(path, name string) (bool, error) {
-    sum, ok := r.Checksums[name]
-    if !ok || sum == "" { return true, nil }
+// cacheDir returns CacheDir or the per-user default.
+func (r *FSRepo) cacheDir() string {
+    if r.CacheDir != "" { return r.CacheDir }
+    if d, err := os.UserCacheDir(); err == nil {
+        return filepath.Join(d, "gosper", "models")
+    }
+    return filepath.Join(os.TempDir(), "gosper", "models")
+}
+
+// Manifest returns the built-in manifest merged with the local manifest
+// file, if one exists.
+func (r *FSRepo) Manifest() (Manifest, error) {
+    m := BuiltinManifest()
+    path := r.ManifestPath
+    if path == "" {
+        path = filepath.Join(r.cacheDir(), "manifest.json")
+        if _, err := os.Stat(path); err != nil { return m, nil }
+    }
+    local, err := LoadManifest(path)
+    if err != nil { return m, err }
+    return m.Merge(local), nil
+}
+
+// resolve maps a model name to its manifest entry. Unknown names are used
+// as raw file names so arbitrary models on BaseURL keep working.
+func (r *FSRepo) resolve(m Manifest, name string) ManifestEntry {
+    e, ok := m.Lookup(name)
+    if !ok { e = ManifestEntry{Name: name, File: name} }
+    for _, k := range []string{name, e.Name, e.File} {
+        if sum := r.Checksums[k]; sum != "" {
+            e.SHA256 = sum
+            break
+        }
+    }
+    return e
+}
+
+// verify checks the sha256 of a cached model, or of one in a local source.
+// The expected digest comes from Checksums or the manifest; without one, the
+// digest recorded when the file was first seen is used (trust on first use).
+// Files unchanged since their last verification are not re-hashed.
+func (r *FSRepo) verify(cacheDir, path string, e ManifestEntry) (bool, error) {
+    fi, err := os.Stat(path)
+    if err != nil { return false, err }
+    records := loadVerified(cacheDir)
+    key := recordKey(cacheDir, path, e)
+    rec, seen := records[key]
+    want := e.SHA256
+    if want == "" && seen { want = rec.SHA256 }
+    if seen && rec.matches(fi) && strings.EqualFold(rec.SHA256, orDefault(want, rec.SHA256)) {
+        return true, nil
+    }
     got, err := fileSha256(path)
     if err != nil { return false, err }
-    return strings.EqualFold(got, sum), nil
+    return record(cacheDir, path, key, want, got)
+}
+
+// recordKey names a file in the verification record: cached files by file
+// name, files in local sources by path.
+func recordKey(cacheDir, path string, e ManifestEntry) string {
+    if filepath.Dir(path) == filepath.Clean(cacheDir) { return e.File }
+    return path
+}
+
+// accept checks a fresh download whose digest was computed while streaming
+// against Checksums or the manifest; it replaces any pinned record.
+func (r *FSRepo) accept(cacheDir, path string, e ManifestEntry, got string) (bool, error) {
+    return record(cacheDir, path, e.File, e.SHA256, got)
+}
+
+func record(cacheDir, path, key, want, got string) (bool, error) {
+    if want != "" && !strings.EqualFold(got, want) { return false, nil }
+    fi, err := os.Stat(path)
+    if err != nil { return false, err }
+    updateVerified(cacheDir, func(records map[string]verifiedRecord) {
+        records[key] = verifiedRecord{SHA256: got, Size: fi.Size(), ModTime: fi.ModTime()}
+    })
+    return true, nil
+}
+
+func orDefault(v, def string) string {
+    if v == "" { return def }
+    return v
 }
 
 func fileSha256(path string) (string, error) {
@@ -94,10 +94,12 @@ func TestFSRepo_Ensure_DownloadSuccess(t *testing.T) {
 	tmpDir := t.TempDir()
 	cacheDir := filepath.Join(tmpDir, "cache")
 
+	var lookups []bool
 	repo := &FSRepo{
-		BaseURL:  server.URL,
-		CacheDir: cacheDir,
-		Retry:    1,
+		BaseURL:     server.URL,
+		CacheDir:    cacheDir,
+		Retry:       1,
+		CacheLookup: func(file string, hit bool) { lookups = append(lookups, hit) },
 	}
 
 	modelName := "ggml-tiny.en.bin"
@@ -120,6 +122,14 @@ func TestFSRepo_Ensure_DownloadSuccess(t *testing.T) {
 	if string(content) != string(modelContent) {
 		t.Errorf("Downloaded content mismatch")
 	}
+
+	// A second lookup is a cache hit
+	if _, err := repo.Ensure(context.Background(), modelName); err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if len(lookups) != 2 || lookups[0] || !lookups[1] {
+		t.Errorf("cache lookups = %v, want [false true]", lookups)
+	}
 }
 
 // TestFSRepo_Ensure_ChecksumValidation tests SHA256 verification
@@ -216,29 +226,18 @@ func TestFSRepo_Ensure_RetryOnFailure(t *testing.T) {
 	}
 }
 
-// TestFSRepo_Ensure_EmptyModelName tests default model handling
+// TestFSRepo_Ensure_EmptyModelName tests that a model name is required
 func TestFSRepo_Ensure_EmptyModelName(t *testing.T) {
-	tmpDir := t.TempDir()
-	cacheDir := filepath.Join(tmpDir, "cache")
-
-	// Create default model in cache
-	defaultModel := "ggml-tiny.en.bin"
-	cachedPath := filepath.Join(cacheDir, defaultModel)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
 	os.MkdirAll(cacheDir, 0755)
-	os.WriteFile(cachedPath, []byte("default"), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "ggml-tiny.en.bin"), []byte("cached"), 0644)
 
-	repo := &FSRepo{
-		CacheDir: cacheDir,
-	}
-
-	// Empty model name should use default
-	got, err := repo.Ensure(context.Background(), "")
-	if err != nil {
-		t.Fatalf("Ensure() error = %v", err)
+	repo := &FSRepo{CacheDir: cacheDir}
+	if got, err := repo.Ensure(context.Background(), ""); err == nil {
+		t.Errorf("Ensure(\"\") = %q, want an error", got)
 	}
-
-	if got != cachedPath {
-		t.Errorf("Ensure() = %q, want default %q", got, cachedPath)
+	if _, _, err := repo.Lookup(""); err == nil {
+		t.Error("Lookup(\"\") should fail")
 	}
 }
 
@@ -0,0 +1,137 @@
+package model
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Source is a place models are fetched from: an HTTP(S) base URL, or a
+// local directory given as a path or file:// URL. Local directories are
+// read-only and their models are used in place rather than copied into the
+// cache.
+type Source struct {
+    URL     string
+    Headers map[string]string // sent with every HTTP request, e.g. Authorization
+    // Timeout bounds connecting and waiting for response headers, and how
+    // long a download may go without receiving data. 0 means no limit.
+    Timeout time.Duration
+}
+
+// dir returns the directory of a local source, or "" for an HTTP source.
+func (s Source) dir() string {
+    if strings.HasPrefix(s.URL, "http://") || strings.HasPrefix(s.URL, "https://") { return "" }
+    if u, err := url.Parse(s.URL); err == nil && u.Scheme == "file" {
+        return filepath.FromSlash(u.Path)
+    }
+    return s.URL
+}
+
+// String names the source without its headers, which may hold credentials.
+func (s Source) String() string { return s.URL }
+
+// sources returns Sources followed by BaseURL, if set.
+func (r *FSRepo) sources() []Source {
+    out := append([]Source(nil), r.Sources...)
+    if r.BaseURL != "" { out = append(out, Source{URL: r.BaseURL}) }
+    return out
+}
+
+// findLocal looks for entry in a local source, by file name or, when its
+// digest is known, in an OCI-style blobs/sha256/<digest> layout.
+func findLocal(dir string, e ManifestEntry) string {
+    candidates := []string{filepath.Join(dir, e.File)}
+    if e.SHA256 != "" {
+        candidates = append(candidates, filepath.Join(dir, "blobs", "sha256", strings.ToLower(e.SHA256)))
+    }
+    for _, p := range candidates {
+        if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() { return p }
+    }
+    return ""
+}
+
+// maxResumes bounds how many failed attempts fetch forgives for getting
+// further than any before them, so a link that keeps dropping gives up.
+const maxResumes = 20
+
+// fetch downloads entry from one HTTP source into local, retrying with
+// backoff. An attempt that fails but leaves more of the file to resume from
+// than any before it does not use up a retry, up to maxResumes times.
+func (r *FSRepo) fetch(ctx context.Context, src Source, cacheDir, local string, entry ManifestEntry) error {
+    url := fmt.Sprintf("%s/%s", trimSlash(src.URL), entry.File)
+    attempts := r.Retry
+    if attempts <= 0 { attempts = 2 }
+    delay := r.RetryDelay
+    if delay <= 0 { delay = time.Second }
+    var lastErr error
+    var best int64
+    resumes := 0
+    for i := 0; i < attempts; i++ {
+        sum, kept, err := r.download(ctx, src, url, local, entry.File)
+        if err == nil {
+            ok, verr := r.accept(cacheDir, local, entry, sum)
+            if verr == nil && ok {
+                return nil
+            }
+            _ = os.Remove(local)
+            lastErr = fmt.Errorf("checksum mismatch for %s from %s", entry.File, src)
+            if verr != nil { lastErr = verr }
+        } else {
+            lastErr = err
+            if ctx.Err() != nil { return ctx.Err() }
+            if kept > best && resumes < maxResumes {
+                best = kept
+                resumes++
+                i--
+            }
+        }
+        if i+1 >= attempts { break }
+        // simple backoff
+        select { case <-time.After(time.Duration(i+1)*delay): case <-ctx.Done(): return ctx.Err() }
+    }
+    return lastErr
+}
+
+// ParseSources parses a source list from configuration. It is either a
+// comma-separated list of URLs and directories, or a JSON array of objects
+// with "url", "headers" and "timeout" (a Go duration such as "30s"). Header
+// values may refer to environment variables as $VAR or ${VAR}, so
+// credentials need not appear in the list itself.
+func ParseSources(spec string) ([]Source, error) {
+    spec = strings.TrimSpace(spec)
+    if spec == "" { return nil, nil }
+    if !strings.HasPrefix(spec, "[") {
+        var out []Source
+        for _, u := range strings.Split(spec, ",") {
+            if u = strings.TrimSpace(u); u != "" { out = append(out, Source{URL: u}) }
+        }
+        return out, nil
+    }
+    var raw []struct {
+        URL     string            `json:"url"`
+        Headers map[string]string `json:"headers"`
+        Timeout string            `json:"timeout"`
+    }
+    if err := json.Unmarshal([]byte(spec), &raw); err != nil { return nil, fmt.Errorf("parse model sources: %w", err) }
+    out := make([]Source, 0, len(raw))
+    for i, s := range raw {
+        if s.URL == "" { return nil, fmt.Errorf("model source %d has no url", i) }
+        src := Source{URL: s.URL}
+        if s.Timeout != "" {
+            d, err := time.ParseDuration(s.Timeout)
+            if err != nil { return nil, fmt.Errorf("model source %s: invalid timeout %q", s.URL, s.Timeout) }
+            src.Timeout = d
+        }
+        if len(s.Headers) > 0 {
+            src.Headers = make(map[string]string, len(s.Headers))
+            for k, v := range s.Headers { src.Headers[k] = os.ExpandEnv(v) }
+        }
+        out = append(out, src)
+    }
+    return out, nil
+}
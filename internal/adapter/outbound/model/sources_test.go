@@ -0,0 +1,162 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFSRepo_Ensure_MirrorFailover(t *testing.T) {
+	var downHits int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downHits, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	var gotAuth string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte("from mirror"))
+	}))
+	defer up.Close()
+
+	repo := &FSRepo{
+		CacheDir: t.TempDir(),
+		Sources: []Source{
+			{URL: down.URL},
+			{URL: up.URL, Headers: map[string]string{"Authorization": "Bearer s3cret"}},
+		},
+		Retry:      2,
+		RetryDelay: time.Millisecond,
+	}
+	path, err := repo.Ensure(context.Background(), "base.en")
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "from mirror" {
+		t.Errorf("content = %q", got)
+	}
+	if downHits != 2 {
+		t.Errorf("failing mirror tried %d times, want 2", downHits)
+	}
+	if gotAuth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+}
+
+func TestFSRepo_Ensure_LocalSourceInPlace(t *testing.T) {
+	bundled := t.TempDir()
+	modelPath := filepath.Join(bundled, "ggml-tiny.en.bin")
+	os.WriteFile(modelPath, []byte("bundled"), 0444)
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("remote"))
+	}))
+	defer ts.Close()
+
+	cacheDir := t.TempDir()
+	repo := &FSRepo{CacheDir: cacheDir, Sources: []Source{{URL: "file://" + filepath.ToSlash(bundled)}}, BaseURL: ts.URL}
+	path, err := repo.Ensure(context.Background(), "tiny.en")
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if path != modelPath {
+		t.Errorf("Ensure() = %q, want the bundled file %q", path, modelPath)
+	}
+	if hits != 0 {
+		t.Error("remote source used although a local source had the model")
+	}
+	// The bundled file is pinned under its own path, not the cache name.
+	if _, ok := loadVerified(cacheDir)[modelPath]; !ok {
+		t.Error("bundled model digest not recorded")
+	}
+
+	// Models missing locally fall through to the next source.
+	if _, err := repo.Ensure(context.Background(), "base.en"); err != nil || hits != 1 {
+		t.Fatalf("fallback to remote: err = %v, hits = %d", err, hits)
+	}
+}
+
+func TestFSRepo_Ensure_LocalSourceBlobLayout(t *testing.T) {
+	content := []byte("blob model")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "blobs", "sha256", digest)
+	os.MkdirAll(filepath.Dir(blob), 0755)
+	os.WriteFile(blob, content, 0444)
+
+	repo := &FSRepo{CacheDir: t.TempDir(), Sources: []Source{{URL: dir}}, Checksums: map[string]string{"small": digest}}
+	path, err := repo.Ensure(context.Background(), "small")
+	if err != nil || path != blob {
+		t.Fatalf("Ensure() = %q, %v; want %q", path, err, blob)
+	}
+
+	// A blob that does not match its digest is rejected.
+	os.Chmod(blob, 0644)
+	os.WriteFile(blob, []byte("tampered"), 0644)
+	if _, err := repo.Ensure(context.Background(), "small"); err == nil {
+		t.Fatal("expected tampered blob to be rejected")
+	}
+}
+
+func TestFSRepo_Download_StallTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	repo := &FSRepo{CacheDir: t.TempDir()}
+	src := Source{URL: ts.URL, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, kept, err := repo.download(context.Background(), src, ts.URL+"/m.bin", filepath.Join(repo.CacheDir, "m.bin"), "m.bin")
+	if err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Fatalf("download error = %v, want stall", err)
+	}
+	if kept != int64(len("partial")) {
+		t.Errorf("kept %d bytes, want the ones received before the stall", kept)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("stalled download was not cut off promptly")
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	got, err := ParseSources(" https://a.example/models , /models,file:///srv/models ")
+	if err != nil || len(got) != 3 || got[0].URL != "https://a.example/models" || got[1].dir() != "/models" || got[2].dir() != "/srv/models" {
+		t.Fatalf("ParseSources(list) = %+v, %v", got, err)
+	}
+	if got[0].dir() != "" {
+		t.Error("HTTP source treated as a directory")
+	}
+
+	t.Setenv("MIRROR_TOKEN", "tok")
+	got, err = ParseSources(`[{"url": "https://mirror.internal/whisper", "headers": {"Authorization": "Bearer ${MIRROR_TOKEN}"}, "timeout": "15s"}, {"url": "/models"}]`)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("ParseSources(json) = %+v, %v", got, err)
+	}
+	if got[0].Headers["Authorization"] != "Bearer tok" || got[0].Timeout != 15*time.Second {
+		t.Errorf("source = %+v", got[0])
+	}
+
+	for _, bad := range []string{`[{"timeout": "1s"}]`, `[{"url": "x", "timeout": "soon"}]`, `[`} {
+		if _, err := ParseSources(bad); err == nil {
+			t.Errorf("ParseSources(%q) should fail", bad)
+		}
+	}
+}
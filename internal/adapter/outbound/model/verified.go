@@ -0,0 +1,30 @@
+package model
+
+import (
+    "os"
+    "time"
+)
+
+// verifiedFile is the name of the per-cache record of verified digests.
+const verifiedFile = ".verified.json"
+
+// verifiedRecord remembers the digest of a cached file together with the
+// size and modification time it had when it was hashed, so unchanged files
+// need not be re-hashed on every Ensure.
+type verifiedRecord struct {
+    SHA256  string    `json:"sha256"`
+    Size    int64     `json:"size"`
+    ModTime time.Time `json:"mod_time"`
+}
+
+func (v verifiedRecord) matches(fi os.FileInfo) bool {
+    return v.Size == fi.Size() && v.ModTime.Equal(fi.ModTime())
+}
+
+func loadVerified(cacheDir string) map[string]verifiedRecord {
+    return loadRecords[verifiedRecord](cacheDir, verifiedFile)
+}
+
+func updateVerified(cacheDir string, fn func(map[string]verifiedRecord)) {
+    updateRecords(cacheDir, verifiedFile, fn)
+}
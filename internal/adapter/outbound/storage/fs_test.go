@@ -205,3 +205,25 @@ func TestStorageFS_WriteTranscript_EdgeCases(t *testing.T) {
 		}
 	})
 }
+
+// TestStorageFS_JSONWords tests that word timing is carried into JSON output
+func TestStorageFS_JSONWords(t *testing.T) {
+	dir := t.TempDir()
+	st := FS{}
+	tr := domain.Transcript{Segments: []domain.TranscriptSegment{{
+		Text:  " hi",
+		Words: []domain.TranscriptWord{{Text: "hi", StartMS: 10, EndMS: 90, Probability: 0.9}},
+	}}}
+
+	path := filepath.Join(dir, "words.json")
+	if err := st.WriteTranscript(context.Background(), path, tr); err != nil {
+		t.Fatalf("WriteTranscript() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "\"Words\"") || !strings.Contains(string(data), "\"EndMS\": 90") {
+		t.Errorf("JSON missing word timing: %s", data)
+	}
+	if strings.Contains(string(data), "\"Tokens\"") {
+		t.Errorf("empty Tokens should be omitted: %s", data)
+	}
+}
@@ -0,0 +1,36 @@
+package whispercpp
+
+import (
+    "bytes"
+    "compress/zlib"
+    "math"
+)
+
+// SegmentConfidence derives per-segment confidence signals from the text
+// tokens of a segment:
+//   - avgLogProb is the mean natural log of the token probabilities;
+//   - compression is len(text)/len(zlib(text)), high for repetitive output.
+//
+// The no-speech probability cannot be derived from the text tokens; only
+// whisper itself knows it, and the Go bindings do not expose it.
+func SegmentConfidence(text string, probs []float32) (avgLogProb, compression float32) {
+    if len(probs) > 0 {
+        var sum float64
+        for _, p := range probs {
+            sum += math.Log(math.Max(float64(p), 1e-10))
+        }
+        avgLogProb = float32(sum / float64(len(probs)))
+    }
+    return avgLogProb, CompressionRatio(text)
+}
+
+// CompressionRatio returns the ratio of raw to zlib-compressed text length.
+func CompressionRatio(text string) float32 {
+    if text == "" { return 0 }
+    var buf bytes.Buffer
+    zw := zlib.NewWriter(&buf)
+    _, _ = zw.Write([]byte(text))
+    _ = zw.Close()
+    if buf.Len() == 0 { return 0 }
+    return float32(len(text)) / float32(buf.Len())
+}
@@ -0,0 +1,24 @@
+package whispercpp
+
+import (
+    "math"
+    "strings"
+    "testing"
+)
+
+func TestSegmentConfidence(t *testing.T) {
+    avg, _ := SegmentConfidence(" hello world", []float32{0.9, 0.5})
+    want := float32((math.Log(0.9) + math.Log(0.5)) / 2)
+    if math.Abs(float64(avg-want)) > 1e-6 { t.Fatalf("avgLogProb = %v, want %v", avg, want) }
+
+    avg, cr := SegmentConfidence("", nil)
+    if avg != 0 || cr != 0 { t.Fatalf("empty segment: avgLogProb=%v compression=%v", avg, cr) }
+}
+
+func TestCompressionRatio_Repetitive(t *testing.T) {
+    plain := CompressionRatio("The quick brown fox jumps over the lazy dog.")
+    loop := CompressionRatio(strings.Repeat("thank you ", 30))
+    if loop <= plain || loop < 2.4 {
+        t.Fatalf("expected repetitive text to compress well: plain=%v loop=%v", plain, loop)
+    }
+}
@@ -0,0 +1,28 @@
+package whispercpp
+
+import (
+    "sort"
+
+    "gosper/internal/domain"
+)
+
+// minLanguageProb drops the long tail of near-zero language probabilities.
+const minLanguageProb = 0.01
+
+// RankLanguages converts whisper's per-language-id probabilities into a
+// detection result sorted by descending probability. name maps a language
+// id to its code.
+func RankLanguages(probs []float32, name func(id int) string) domain.LanguageDetection {
+    var out []domain.LanguageProb
+    for id, p := range probs {
+        if p < minLanguageProb { continue }
+        out = append(out, domain.LanguageProb{Language: name(id), Probability: p})
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Probability > out[j].Probability })
+    det := domain.LanguageDetection{Probabilities: out}
+    if len(out) > 0 { det.Language = out[0].Language }
+    return det
+}
+
+// isAuto reports whether lang asks whisper to detect the language.
+func isAuto(lang string) bool { return lang == "" || lang == "auto" }
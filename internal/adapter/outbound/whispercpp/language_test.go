@@ -0,0 +1,19 @@
+package whispercpp
+
+import "testing"
+
+func TestRankLanguages(t *testing.T) {
+    names := []string{"en", "de", "fr", "es"}
+    det := RankLanguages([]float32{0.2, 0.7, 0.005, 0.095}, func(id int) string { return names[id] })
+    if det.Language != "de" { t.Fatalf("Language = %q, want de", det.Language) }
+    if len(det.Probabilities) != 3 { t.Fatalf("expected tail below threshold to be dropped: %+v", det.Probabilities) }
+    if det.Probabilities[1].Language != "en" || det.Probabilities[2].Language != "es" {
+        t.Fatalf("unexpected order: %+v", det.Probabilities)
+    }
+}
+
+func TestRankLanguages_Empty(t *testing.T) {
+    if det := RankLanguages(nil, nil); det.Language != "" || len(det.Probabilities) != 0 {
+        t.Fatalf("expected empty detection, got %+v", det)
+    }
+}
@@ -0,0 +1,106 @@
+package whispercpp
+
+import (
+    "context"
+    "errors"
+    "io"
+    "sync"
+    "sync/atomic"
+)
+
+// pool keeps preloaded models by path. A whisper model decodes with a single
+// state, so each pooled model serves one job at a time; other jobs for it
+// wait their turn.
+type pool[M io.Closer] struct {
+    mu      sync.Mutex
+    models  map[string]*pooled[M]
+    loading map[string]*loadCall // paths being loaded, outside mu
+    waiting atomic.Int64         // jobs waiting for a model's turn
+}
+
+type loadCall struct {
+    done chan struct{}
+    err  error
+}
+
+type pooled[M io.Closer] struct {
+    model M
+    turn  chan struct{} // holds a token while the model is in use
+}
+
+// add loads path unless it is already pooled. The load runs without the
+// lock, so jobs on other models are not held up; concurrent adds of the same
+// path share one load.
+func (p *pool[M]) add(path string, load func(string) (M, error)) error {
+    p.mu.Lock()
+    if _, ok := p.models[path]; ok { p.mu.Unlock(); return nil }
+    if c, ok := p.loading[path]; ok {
+        p.mu.Unlock()
+        <-c.done
+        return c.err
+    }
+    c := &loadCall{done: make(chan struct{})}
+    if p.loading == nil { p.loading = map[string]*loadCall{} }
+    p.loading[path] = c
+    p.mu.Unlock()
+
+    m, err := load(path)
+
+    p.mu.Lock()
+    delete(p.loading, path)
+    if err == nil {
+        if p.models == nil { p.models = map[string]*pooled[M]{} }
+        p.models[path] = &pooled[M]{model: m, turn: make(chan struct{}, 1)}
+    }
+    p.mu.Unlock()
+    c.err = err
+    close(c.done)
+    return err
+}
+
+// acquire waits for the pooled model at path. ok is false when path is not
+// pooled; otherwise release must be called when done.
+func (p *pool[M]) acquire(ctx context.Context, path string) (m M, release func(), ok bool, err error) {
+    p.mu.Lock()
+    pm, found := p.models[path]
+    p.mu.Unlock()
+    if !found { return m, nil, false, nil }
+    select {
+    case pm.turn <- struct{}{}:
+        return pm.model, func() { <-pm.turn }, true, nil
+    default:
+    }
+    p.waiting.Add(1)
+    defer p.waiting.Add(-1)
+    select {
+    case pm.turn <- struct{}{}:
+    case <-ctx.Done():
+        return m, nil, true, ctx.Err()
+    }
+    return pm.model, func() { <-pm.turn }, true, nil
+}
+
+// waiters returns the number of jobs waiting for a pooled model.
+func (p *pool[M]) waiters() int { return int(p.waiting.Load()) }
+
+// loaded lists the pooled model paths.
+func (p *pool[M]) loaded() []string {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    out := make([]string, 0, len(p.models))
+    for path := range p.models { out = append(out, path) }
+    return out
+}
+
+// close waits for running jobs and frees every pooled model.
+func (p *pool[M]) close() error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    var errs []error
+    for path, pm := range p.models {
+        pm.turn <- struct{}{}
+        errs = append(errs, pm.model.Close())
+        delete(p.models, path)
+    }
+    return errors.Join(errs...)
+}
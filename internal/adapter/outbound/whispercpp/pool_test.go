@@ -0,0 +1,71 @@
+package whispercpp
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+type fakeModel struct{ closed bool }
+
+func (m *fakeModel) Close() error { m.closed = true; return nil }
+
+func TestPool_AddAcquireClose(t *testing.T) {
+    var p pool[*fakeModel]
+    loads := 0
+    load := func(string) (*fakeModel, error) { loads++; return &fakeModel{}, nil }
+    if err := p.add("a.bin", load); err != nil { t.Fatal(err) }
+    if err := p.add("a.bin", load); err != nil || loads != 1 { t.Fatalf("second add loaded again: loads=%d err=%v", loads, err) }
+    if err := p.add("b.bin", func(string) (*fakeModel, error) { return nil, errors.New("bad model") }); err == nil {
+        t.Fatal("expected load error")
+    }
+
+    if _, _, ok, _ := p.acquire(context.Background(), "other.bin"); ok {
+        t.Fatal("unpooled path reported as pooled")
+    }
+    m, release, ok, err := p.acquire(context.Background(), "a.bin")
+    if !ok || err != nil { t.Fatalf("acquire: ok=%v err=%v", ok, err) }
+
+    // The model serves one job at a time; others wait and are counted.
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    done := make(chan error)
+    go func() { _, _, _, err := p.acquire(ctx, "a.bin"); done <- err }()
+    for p.waiters() != 1 { time.Sleep(time.Millisecond) }
+    if err := <-done; err != context.DeadlineExceeded {
+        t.Fatalf("concurrent acquire err = %v, want deadline exceeded", err)
+    }
+    if n := p.waiters(); n != 0 { t.Fatalf("waiters = %d after the wait ended", n) }
+    release()
+    _, release2, _, err := p.acquire(context.Background(), "a.bin")
+    if err != nil { t.Fatal(err) }
+    release2()
+
+    if got := p.loaded(); len(got) != 1 || got[0] != "a.bin" { t.Fatalf("loaded = %v", got) }
+    if err := p.close(); err != nil || !m.closed { t.Fatalf("close: err=%v closed=%v", err, m.closed) }
+    if len(p.loaded()) != 0 { t.Fatal("models remain after close") }
+}
+
+func TestPool_AddLoadsOutsideTheLock(t *testing.T) {
+    var p pool[*fakeModel]
+    started, unblock := make(chan struct{}), make(chan struct{})
+    loads := 0
+    slow := func(string) (*fakeModel, error) { loads++; close(started); <-unblock; return &fakeModel{}, nil }
+    done := make(chan error, 2)
+    go func() { done <- p.add("slow.bin", slow) }()
+    <-started
+
+    // Other models and listings are served while the load runs.
+    if err := p.add("fast.bin", func(string) (*fakeModel, error) { return &fakeModel{}, nil }); err != nil { t.Fatal(err) }
+    if got := p.loaded(); len(got) != 1 || got[0] != "fast.bin" { t.Fatalf("loaded during load = %v", got) }
+    if _, _, ok, _ := p.acquire(context.Background(), "slow.bin"); ok { t.Fatal("model acquired before its load finished") }
+
+    // A second add of the same path waits for the first load.
+    go func() { done <- p.add("slow.bin", slow) }()
+    close(unblock)
+    for range 2 {
+        if err := <-done; err != nil { t.Fatal(err) }
+    }
+    if loads != 1 || len(p.loaded()) != 2 { t.Fatalf("loads = %d, loaded = %v", loads, p.loaded()) }
+}
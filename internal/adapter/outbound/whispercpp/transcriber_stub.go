@@ -5,11 +5,15 @@ package whispercpp
 import (
     "context"
     "fmt"
+    "time"
+
     "gosper/internal/domain"
     "gosper/internal/port"
 )
 
-type Transcriber struct{}
+type Transcriber struct {
+    OnLoad func(path string, d time.Duration) // never called: nothing loads
+}
 
 var _ port.Transcriber = (*Transcriber)(nil)
 
@@ -17,3 +21,21 @@ func (t *Transcriber) Transcribe(ctx context.Context, pcm16k []float32, cfg doma
     return domain.Transcript{}, fmt.Errorf("whisper adapter not built: build with -tags whisper")
 }
 
+
+var _ port.LanguageDetector = (*Transcriber)(nil)
+
+func (t *Transcriber) DetectLanguage(ctx context.Context, pcm16k []float32, cfg domain.ModelConfig) (domain.LanguageDetection, error) {
+    return domain.LanguageDetection{}, fmt.Errorf("whisper adapter not built: build with -tags whisper")
+}
+
+var _ port.ModelLoader = (*Transcriber)(nil)
+
+func (t *Transcriber) Load(ctx context.Context, path string) error {
+    return fmt.Errorf("whisper adapter not built: build with -tags whisper")
+}
+
+func (t *Transcriber) Loaded() []string { return nil }
+
+func (t *Transcriber) Waiting() int { return 0 }
+
+func (t *Transcriber) Close() error { return nil }
@@ -4,47 +4,245 @@ package whispercpp
 
 import (
     "context"
+    "fmt"
+    "path/filepath"
+    "time"
+
+    lw "github.com/ggerganov/whisper.cpp/bindings/go"
     w "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
     "gosper/internal/domain"
     "gosper/internal/port"
+    "gosper/pkg/trace"
 )
 
-type Transcriber struct{}
+// Transcriber runs whisper.cpp. Models passed to Load stay in memory and
+// serve one job at a time; other models are loaded for each job.
+type Transcriber struct {
+    // OnLoad, if set, is called after each model load with how long it took.
+    OnLoad func(path string, d time.Duration)
+
+    models pool[w.Model]
+}
 
 var _ port.Transcriber = (*Transcriber)(nil)
+var _ port.LanguageDetector = (*Transcriber)(nil)
+var _ port.ModelLoader = (*Transcriber)(nil)
+
+// Load loads the model at path and keeps it for later jobs.
+func (t *Transcriber) Load(ctx context.Context, path string) error {
+    return t.models.add(path, func(path string) (w.Model, error) { return t.load(ctx, path) })
+}
+
+func (t *Transcriber) load(ctx context.Context, path string) (w.Model, error) {
+    _, span := trace.Start(ctx, "whisper.load_model", trace.String("model.file", filepath.Base(path)))
+    defer span.End()
+    start := time.Now()
+    m, err := w.New(path)
+    span.Fail(err)
+    if err == nil && t.OnLoad != nil { t.OnLoad(path, time.Since(start)) }
+    return m, err
+}
+
+// Waiting returns the number of jobs waiting for a loaded model.
+func (t *Transcriber) Waiting() int { return t.models.waiters() }
+
+// Loaded lists the paths of the loaded models.
+func (t *Transcriber) Loaded() []string { return t.models.loaded() }
+
+// Close frees all loaded models, waiting for jobs using them to finish.
+func (t *Transcriber) Close() error { return t.models.close() }
+
+// model returns the loaded model for path, or loads it for this job only.
+func (t *Transcriber) model(ctx context.Context, path string) (w.Model, func(), error) {
+    _, span := trace.Start(ctx, "whisper.acquire_model", trace.String("model.file", filepath.Base(path)))
+    m, release, ok, err := t.models.acquire(ctx, path)
+    span.SetAttrs(trace.Bool("whisper.model.preloaded", ok))
+    span.Fail(err)
+    span.End()
+    if ok { return m, release, err }
+    m, err = t.load(ctx, path)
+    if err != nil { return nil, nil, err }
+    return m, func() { m.Close() }, nil
+}
 
 func (t *Transcriber) Transcribe(ctx context.Context, pcm16k []float32, cfg domain.ModelConfig) (domain.Transcript, error) {
-    model, err := w.New(cfg.ModelPath)
+    model, release, err := t.model(ctx, cfg.ModelPath)
     if err != nil { return domain.Transcript{}, err }
-    defer model.Close()
+    defer release()
+
+    // Identify the language up front so the transcript reports what whisper
+    // actually decoded with, along with the per-language probabilities.
+    var det domain.LanguageDetection
+    if isAuto(cfg.Language) {
+        det, err = t.detect(ctx, model, pcm16k, cfg)
+        if err != nil { return domain.Transcript{}, err }
+    }
 
     c, err := model.NewContext()
     if err != nil { return domain.Transcript{}, err }
-    if cfg.Language != "" { _ = c.SetLanguage(cfg.Language) }
+    lang := cfg.Language
+    if det.Language != "" { lang = det.Language }
+    if lang != "" { _ = c.SetLanguage(lang) }
     c.SetTranslate(cfg.Translate)
     if cfg.Threads > 0 { c.SetThreads(cfg.Threads) }
     c.SetTokenTimestamps(cfg.Timestamps)
     if cfg.BeamSize > 0 { c.SetBeamSize(cfg.BeamSize) }
     if cfg.MaxTokens > 0 { c.SetMaxTokensPerSegment(cfg.MaxTokens) }
     if cfg.InitialPrompt != "" { c.SetInitialPrompt(cfg.InitialPrompt) }
+    if err := applyDecoding(c, cfg); err != nil { return domain.Transcript{}, err }
 
-    if err := c.Process(pcm16k, nil, nil, nil); err != nil { return domain.Transcript{}, err }
+    _, span := trace.Start(ctx, "whisper.process",
+        trace.String("model.file", cfg.ModelName), trace.String("language", lang),
+        trace.Seconds("audio.duration", time.Duration(len(pcm16k))*time.Second/16000))
+    err = c.Process(pcm16k, nil, nil, nil)
+    span.Fail(err)
+    span.End()
+    if err != nil { return domain.Transcript{}, err }
 
     var segments []domain.TranscriptSegment
     for {
         seg, err := c.NextSegment()
         if err != nil { break }
-        segments = append(segments, domain.TranscriptSegment{
+        ds := domain.TranscriptSegment{
             Index:   seg.Num,
             StartMS: int64(seg.Start / 1e6),
             EndMS:   int64(seg.End / 1e6),
             Text:    seg.Text,
-        })
+        }
+        toks := textTokens(c, seg.Tokens)
+        probs := make([]float32, len(toks))
+        for i, t := range toks { probs[i] = t.Probability }
+        // NoSpeechProb stays unreported: the Go bindings do not expose it.
+        ds.AvgLogProb, ds.CompressionRatio = SegmentConfidence(seg.Text, probs)
+        if cfg.Timestamps {
+            ds.Tokens = toks
+            ds.Words = GroupWords(toks)
+        }
+        segments = append(segments, ds)
     }
     var full string
     for _, s := range segments { if full == "" { full = s.Text } else { full += s.Text } }
-    tr := domain.Transcript{Language: cfg.Language, Segments: segments, FullText: full}
+    if d := c.DetectedLanguage(); isAuto(lang) && d != "" { lang = d }
+    tr := domain.Transcript{Language: lang, LanguageProbs: det.Probabilities, Segments: segments, FullText: full}
     _ = ctx // reserved for future cancellation integration
     return tr, nil
 }
 
+// DetectLanguage runs whisper's language identification on the first 30 s
+// of audio without decoding any text. English-only models report "en".
+func (t *Transcriber) DetectLanguage(ctx context.Context, pcm16k []float32, cfg domain.ModelConfig) (domain.LanguageDetection, error) {
+    model, release, err := t.model(ctx, cfg.ModelPath)
+    if err != nil { return domain.LanguageDetection{}, err }
+    defer release()
+    det, err := t.detect(ctx, model, pcm16k, cfg)
+    if err == nil && det.Language == "" { err = unsupported("language identification") }
+    return det, err
+}
+
+// langDetector is implemented by binding releases whose Context exposes
+// whisper's per-language probabilities.
+type langDetector interface {
+    WhisperLangAutoDetect(offsetMs int, threads int) ([]float32, error)
+}
+
+// detect identifies the language of pcm16k with m, which the caller holds.
+// It returns an empty detection when the bindings do not expose language
+// probabilities; whisper then detects the language itself while decoding.
+func (t *Transcriber) detect(ctx context.Context, m w.Model, pcm16k []float32, cfg domain.ModelConfig) (_ domain.LanguageDetection, err error) {
+    if !m.IsMultilingual() {
+        return domain.LanguageDetection{Language: "en", Probabilities: []domain.LanguageProb{{Language: "en", Probability: 1}}}, nil
+    }
+    c, err := m.NewContext()
+    if err != nil { return domain.LanguageDetection{}, err }
+    ld, ok := c.(langDetector)
+    if !ok || len(pcm16k) == 0 { return domain.LanguageDetection{}, nil }
+
+    ctx, span := trace.Start(ctx, "whisper.detect_language", trace.String("model.file", cfg.ModelName))
+    defer func() {
+        span.Fail(err)
+        span.End()
+    }()
+    if len(pcm16k) > domain.DetectWindowSamples { pcm16k = pcm16k[:domain.DetectWindowSamples] }
+    threads := int(cfg.Threads)
+    if threads <= 0 { threads = 1 }
+    c.SetThreads(uint(threads))
+    // Process computes the mel spectrogram before anything else. Stopping it
+    // at the encoder leaves the spectrogram for WhisperLangAutoDetect without
+    // decoding any text, and a fixed language skips whisper's own detection.
+    _ = c.SetLanguage("en")
+    _ = c.Process(pcm16k, func() bool { return false }, nil, nil) // aborted on purpose
+    if err := ctx.Err(); err != nil { return domain.LanguageDetection{}, err }
+    probs, err := ld.WhisperLangAutoDetect(0, threads)
+    if err != nil { return domain.LanguageDetection{}, err }
+    return RankLanguages(probs, lw.Whisper_lang_str), nil
+}
+
+// Setters for whisper_full_params fields that not every release of the Go
+// bindings exposes on its Context; used when the linked bindings have them.
+type (
+    bestOfSetter        interface{ SetBestOf(int) }
+    logProbTholdSetter  interface{ SetLogprobThold(float32) }
+    noSpeechTholdSetter interface{ SetNoSpeechThold(float32) }
+    suppressBlankSetter interface{ SetSuppressBlank(bool) }
+    suppressRegexSetter interface{ SetSuppressRegex(string) }
+)
+
+// applyDecoding maps the decoding parameters of cfg onto c. Parameters left
+// at their zero value keep whisper's defaults; a non-default parameter the
+// linked bindings cannot set is an error rather than silently ignored.
+func applyDecoding(c w.Context, cfg domain.ModelConfig) error {
+    if cfg.Temperature > 0 { c.SetTemperature(cfg.Temperature) }
+    if cfg.TemperatureInc > 0 { c.SetTemperatureFallback(cfg.TemperatureInc) }
+    if cfg.TemperatureInc < 0 { c.SetTemperatureFallback(0) }
+    if cfg.EntropyThreshold > 0 { c.SetEntropyThold(cfg.EntropyThreshold) }
+    if cfg.MaxSegmentLength > 0 { c.SetMaxSegmentLength(cfg.MaxSegmentLength) }
+    if cfg.SplitOnWord { c.SetSplitOnWord(true) }
+
+    if cfg.BestOf > 0 {
+        s, ok := c.(bestOfSetter)
+        if !ok { return unsupported("best-of") }
+        s.SetBestOf(cfg.BestOf)
+    }
+    if cfg.LogProbThreshold != 0 {
+        s, ok := c.(logProbTholdSetter)
+        if !ok { return unsupported("log-probability threshold") }
+        s.SetLogprobThold(cfg.LogProbThreshold)
+    }
+    if cfg.NoSpeechThreshold > 0 {
+        s, ok := c.(noSpeechTholdSetter)
+        if !ok { return unsupported("no-speech threshold") }
+        s.SetNoSpeechThold(cfg.NoSpeechThreshold)
+    }
+    if cfg.AllowBlank {
+        s, ok := c.(suppressBlankSetter)
+        if !ok { return unsupported("suppress-blank") }
+        s.SetSuppressBlank(false)
+    }
+    if cfg.SuppressRegex != "" {
+        s, ok := c.(suppressRegexSetter)
+        if !ok { return unsupported("suppress-regex") }
+        s.SetSuppressRegex(cfg.SuppressRegex)
+    }
+    return nil
+}
+
+func unsupported(param string) error {
+    return fmt.Errorf("%s is not supported by the linked whisper.cpp Go bindings", param)
+}
+
+// textTokens drops special tokens (SOT, timestamps, language tags, ...) and
+// converts the rest to domain tokens.
+func textTokens(c w.Context, toks []w.Token) []domain.TranscriptToken {
+    out := make([]domain.TranscriptToken, 0, len(toks))
+    for _, t := range toks {
+        if !c.IsText(t) { continue }
+        out = append(out, domain.TranscriptToken{
+            ID:          t.Id,
+            Text:        t.Text,
+            StartMS:     int64(t.Start / 1e6),
+            EndMS:       int64(t.End / 1e6),
+            Probability: t.P,
+        })
+    }
+    return out
+}
@@ -0,0 +1,56 @@
+package whispercpp
+
+import (
+    "strings"
+    "unicode"
+
+    "gosper/internal/domain"
+)
+
+// GroupWords merges text tokens into words. Whisper's BPE tokens carry the
+// leading space of the word they begin, so a token starting with whitespace
+// opens a new word; punctuation-only tokens stay attached to the previous one.
+func GroupWords(tokens []domain.TranscriptToken) []domain.TranscriptWord {
+    var words []domain.TranscriptWord
+    var cur *domain.TranscriptWord
+    var psum float32
+    var n int
+    flush := func() {
+        if cur == nil { return }
+        cur.Text = strings.TrimSpace(cur.Text)
+        if cur.Text != "" {
+            cur.Probability = psum / float32(n)
+            words = append(words, *cur)
+        }
+        cur, psum, n = nil, 0, 0
+    }
+    for _, t := range tokens {
+        if t.Text == "" { continue }
+        startsWord := cur == nil || (startsWithSpace(t.Text) && !isPunct(t.Text))
+        if startsWord {
+            flush()
+            cur = &domain.TranscriptWord{Text: t.Text, StartMS: t.StartMS, EndMS: t.EndMS}
+        } else {
+            cur.Text += t.Text
+            if t.EndMS > cur.EndMS { cur.EndMS = t.EndMS }
+        }
+        psum += t.Probability
+        n++
+    }
+    flush()
+    return words
+}
+
+func startsWithSpace(s string) bool {
+    for _, r := range s { return unicode.IsSpace(r) }
+    return false
+}
+
+func isPunct(s string) bool {
+    s = strings.TrimSpace(s)
+    if s == "" { return false }
+    for _, r := range s {
+        if !unicode.IsPunct(r) { return false }
+    }
+    return true
+}
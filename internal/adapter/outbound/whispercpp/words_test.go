@@ -0,0 +1,30 @@
+package whispercpp
+
+import (
+    "testing"
+
+    "gosper/internal/domain"
+)
+
+func TestGroupWords(t *testing.T) {
+    toks := []domain.TranscriptToken{
+        {Text: " Hel", StartMS: 0, EndMS: 100, Probability: 0.8},
+        {Text: "lo", StartMS: 100, EndMS: 200, Probability: 0.6},
+        {Text: ",", StartMS: 200, EndMS: 220, Probability: 1.0},
+        {Text: " world", StartMS: 300, EndMS: 500, Probability: 0.9},
+        {Text: " .", StartMS: 500, EndMS: 510, Probability: 0.5},
+    }
+    got := GroupWords(toks)
+    if len(got) != 2 { t.Fatalf("expected 2 words, got %d: %+v", len(got), got) }
+    if got[0].Text != "Hello," || got[0].StartMS != 0 || got[0].EndMS != 220 {
+        t.Fatalf("unexpected first word: %+v", got[0])
+    }
+    if p := got[0].Probability; p < 0.79 || p > 0.81 { t.Fatalf("unexpected probability: %v", p) }
+    if got[1].Text != "world ." || got[1].StartMS != 300 || got[1].EndMS != 510 {
+        t.Fatalf("unexpected second word: %+v", got[1])
+    }
+}
+
+func TestGroupWords_Empty(t *testing.T) {
+    if got := GroupWords(nil); len(got) != 0 { t.Fatalf("expected no words, got %+v", got) }
+}
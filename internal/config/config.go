@@ -1,41 +1,322 @@
+// Package config is the configuration shared by the CLI and the server.
+//
+// Settings are resolved in layers, each overriding the one before: built-in
+// defaults, the config file (YAML, TOML or JSON), environment variables and
+// command-line flags. Every setting has a dotted key, such as
+// "models.cache_dir", used in files, with `gosper config get|set` and with
+// --set key=value.
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"time"
 )
 
 // Config holds the application configuration.
 type Config struct {
-	Addr         string
-	Model        string
-	Language     string
-	ModelBaseURL string
+	Model     string // default model name or local path
+	Language  string // language code or "auto"
+	Threads   int    // inference threads; 0 picks a default
+	LogLevel  string
+	LogFormat string // json or text; empty for each program's default
+
+	Server ServerConfig
+	Models ModelsConfig
+	Audio  AudioConfig
+
+	path   string
+	origin map[string]string
+}
+
+// ServerConfig holds settings only the HTTP server uses.
+type ServerConfig struct {
+	Addr string
+
+	// AllowedModels restricts which models clients may request; empty
+	// allows any model in the manifest or cache. The default model is
+	// always allowed.
+	AllowedModels []string
+
+	// Preload lists the models to load before reporting ready; empty means
+	// the default model and "none" means no models. See PreloadModels.
+	Preload []string
+	Warmup  bool // run a warm-up inference on each preloaded model
+	Metrics bool // serve Prometheus metrics at /metrics
+
+	// Uploads: the largest request body in bytes and the longest audio
+	// (0 for no limit), and the directory they are kept in while processed
+	// (empty for one under the system temp directory).
+	MaxUpload        int64
+	MaxAudioDuration time.Duration
+	TempDir          string
+	AudioURL         AudioURLConfig
+
+	// API keys, inline as id:sha256:<hex> and from a file; with neither the
+	// API is open. Per-key usage resets every QuotaWindow (0 for never).
+	APIKeys     []string
+	APIKeysFile string
+	QuotaWindow time.Duration
+
+	// RateLimit limits how fast each client sends requests and audio.
+	// Clients are told apart by credential, or else by address, taken from
+	// X-Forwarded-For only when the peer is one of TrustedProxies.
+	RateLimit      RateLimitConfig
+	TrustedProxies []string
+
+	CORS    CORSConfig
+	JWT     JWTConfig
+	Tracing TracingConfig
+}
+
+// TracingConfig holds where request traces are exported.
+type TracingConfig struct {
+	Exporter    string  // none, otlp or stdout
+	Endpoint    string  // OTLP/HTTP collector base URL
+	SampleRatio float64 // share of new traces recorded, 0..1
+	ServiceName string
+}
+
+// AudioURLConfig holds where the server may fetch audio from for requests
+// that send a URL instead of a file.
+type AudioURLConfig struct {
+	AllowedHosts []string // exact hosts or *.domain patterns; empty disables audio_url
+	AllowPrivate bool     // allow hosts resolving to private addresses
+	Timeout      time.Duration
+}
+
+// CORSConfig is the cross-origin policy for browser clients.
+type CORSConfig struct {
+	AllowedOrigins   []string // exact origins, https://*.domain patterns or *
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // preflight cache time
+}
+
+// RateLimitConfig holds per-client token bucket limits; each refills
+// continuously over Window and allows bursts of its full size.
+type RateLimitConfig struct {
+	Requests     int     // 0 for no limit
+	AudioSeconds float64 // 0 for no limit
+	Window       time.Duration
+}
+
+// JWTConfig holds how bearer JWTs are verified; they are accepted only
+// when JWKS is set.
+type JWTConfig struct {
+	JWKS         string        // file path or http(s) URL
+	Refresh      time.Duration // maximum age of keys fetched from a URL
+	Issuer       string
+	Audience     []string
+	SubjectClaim string // claim used as the client ID
+}
+
+// ModelsConfig holds where models come from and how they are cached.
+type ModelsConfig struct {
+	BaseURL string
+	Sources string // ordered model sources; see model.ParseSources
+
+	// Model cache: directory (empty for the per-user default), size quota in
+	// bytes (0 for none) and models never evicted.
+	CacheDir      string
+	CacheMaxBytes int64
+	Pinned        []string
 }
 
-// FromEnv loads the configuration from environment variables.
-func FromEnv() *Config {
-	cfg := &Config{
-		Addr:         ":8080",
-		Model:        "base.en",
-		Language:     "en",
-		ModelBaseURL: "",
+// AudioConfig holds recording settings, remembered between CLI runs.
+type AudioConfig struct {
+	Device       string  // input device for recording
+	Feedback     bool    // beep when recording starts and stops
+	OutputDevice string  // output device for the beep
+	BeepVolume   float64 // 0..1; 0 for the default volume
+}
+
+// Default returns the built-in defaults.
+func Default() *Config {
+	c := &Config{
+		Model:    "base.en",
+		Language: "auto",
+		LogLevel: "info",
+		Server: ServerConfig{
+			Addr:        ":8080",
+			Warmup:      true,
+			Metrics:     true,
+			MaxUpload:   100 << 20,
+			QuotaWindow: 24 * time.Hour,
+			AudioURL: AudioURLConfig{
+				Timeout: 30 * time.Second,
+			},
+			RateLimit: RateLimitConfig{
+				Window: time.Minute,
+			},
+			CORS: CORSConfig{
+				AllowedOrigins: []string{"*"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key"},
+				MaxAge:         10 * time.Minute,
+			},
+			JWT: JWTConfig{
+				Refresh:      time.Hour,
+				SubjectClaim: "sub",
+			},
+			Tracing: TracingConfig{
+				Exporter:    "none",
+				Endpoint:    "http://localhost:4318",
+				SampleRatio: 1,
+				ServiceName: "gosper",
+			},
+		},
 	}
+	c.origin = map[string]string{}
+	for _, s := range settings {
+		c.origin[s.key] = "default"
+	}
+	return c
+}
 
-	if p := os.Getenv("PORT"); p != "" {
-		if _, err := strconv.Atoi(p); err == nil {
-			cfg.Addr = ":" + p
+// Options controls Load.
+type Options struct {
+	// Path is the config file. Empty means DefaultPath, which need not exist;
+	// an explicit path must.
+	Path string
+	// Flags maps keys to values given on the command line.
+	Flags map[string]string
+	// LookupEnv reads environment variables; nil means os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+}
+
+// Load resolves the configuration from defaults, the config file,
+// environment variables and opts.Flags, then validates it. All problems are
+// reported together, each naming the key and where its value came from.
+func Load(opts Options) (*Config, error) {
+	c := Default()
+	c.path = opts.Path
+	if c.path == "" {
+		c.path = DefaultPath()
+	}
+
+	var errs []error
+	tree, err := readFile(c.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && opts.Path == "":
+	case err != nil:
+		return c, err
+	default:
+		errs = append(errs, c.applyFile(tree)...)
+	}
+
+	lookup := opts.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	errs = append(errs, c.applyEnv(lookup)...)
+
+	keys := make([]string, 0, len(opts.Flags))
+	for k := range opts.Flags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := c.set(k, opts.Flags[k], "flag"); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	if m := os.Getenv("GOSPER_MODEL"); m != "" {
-		cfg.Model = m
+
+	if err := errors.Join(errs...); err != nil {
+		return c, err
 	}
-	if l := os.Getenv("GOSPER_LANG"); l != "" {
-		cfg.Language = l
+	return c, c.Validate()
+}
+
+// Path is the config file the configuration was loaded from, or would have
+// been had it existed.
+func (c *Config) Path() string { return c.path }
+
+// Get returns the value of key as text.
+func (c *Config) Get(key string) (string, error) {
+	s, err := lookup(key)
+	if err != nil {
+		return "", err
 	}
-	if u := os.Getenv("MODEL_BASE_URL"); u != "" {
-		cfg.ModelBaseURL = u
+	return s.format(c), nil
+}
+
+// Set sets key from text, as if given on the command line.
+func (c *Config) Set(key, value string) error { return c.set(key, value, "flag") }
+
+// Origin describes where the value of key came from: "default", "file
+// <path>", "env <NAME>" or "flag".
+func (c *Config) Origin(key string) string { return c.origin[key] }
+
+// PreloadModels returns the models to load at startup.
+func (c *Config) PreloadModels() []string {
+	switch {
+	case len(c.Server.Preload) == 0:
+		return []string{c.Model}
+	case len(c.Server.Preload) == 1 && c.Server.Preload[0] == "none":
+		return nil
 	}
+	return c.Server.Preload
+}
 
-	return cfg
+func (c *Config) set(key, value, origin string) error {
+	s, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	if err := s.parse(c, value); err != nil {
+		return fmt.Errorf("%s (from %s): %w", key, origin, err)
+	}
+	c.origin[key] = origin
+	return nil
+}
+
+// applyEnv applies each setting's environment variables; when several are
+// set, the first listed wins.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) []error {
+	var errs []error
+	for _, s := range settings {
+		for _, name := range s.env {
+			if v, ok := lookup(name); ok && v != "" {
+				if err := c.set(s.key, v, "env "+name); err != nil {
+					errs = append(errs, err)
+				}
+				break
+			}
+		}
+	}
+	// PORT is the platform convention (Cloud Run, Heroku, ...) and only
+	// overrides the port, keeping any GOSPER_ADDR host.
+	if p, ok := lookup("PORT"); ok && p != "" {
+		if _, err := strconv.Atoi(p); err != nil {
+			errs = append(errs, fmt.Errorf("server.addr (from env PORT): invalid port %q", p))
+		} else {
+			host, _, _ := splitHostPort(c.Server.Addr)
+			c.Server.Addr = host + ":" + p
+			c.origin["server.addr"] = "env PORT"
+		}
+	}
+	return errs
+}
+
+// DefaultPath returns the default config file: $GOSPER_CONFIG, or the first
+// of config.yaml, config.yml, config.toml and config.json that exists in the
+// user config directory, falling back to config.json.
+func DefaultPath() string {
+	if p := os.Getenv("GOSPER_CONFIG"); p != "" {
+		return p
+	}
+	dir := filepath.Join(os.TempDir(), "gosper")
+	if d, err := os.UserConfigDir(); err == nil {
+		dir = filepath.Join(d, "gosper")
+	}
+	for _, name := range []string{"config.yaml", "config.yml", "config.toml", "config.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return filepath.Join(dir, "config.json")
 }
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		v, ok := vars[k]
+		return v, ok
+	}
+}
+
+func write(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := write(t, "config.yaml", `
+model: small
+language: de
+threads: 2
+models:
+  cache_dir: /from/file
+`)
+	c, err := Load(Options{
+		Path:      path,
+		LookupEnv: env(map[string]string{"GOSPER_LANG": "fr", "GOSPER_THREADS": "4"}),
+		Flags:     map[string]string{"threads": "8"},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Model != "small" || c.Language != "fr" || c.Threads != 8 || c.Models.CacheDir != "/from/file" || c.LogLevel != "info" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	for key, want := range map[string]string{
+		"model":     "file " + path,
+		"language":  "env GOSPER_LANG",
+		"threads":   "flag",
+		"log_level": "default",
+	} {
+		if got := c.Origin(key); got != want {
+			t.Errorf("Origin(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	files := map[string]string{
+		"c.yaml": "server:\n  allowed_models: [tiny.en, base.en]\nmodels:\n  cache_max: 2G\n",
+		"c.toml": "[server]\nallowed_models = [\"tiny.en\", \"base.en\"]\n[models]\ncache_max = \"2G\"\n",
+		"c.json": `{"server": {"allowed_models": ["tiny.en", "base.en"]}, "models": {"cache_max": "2G"}}`,
+	}
+	for name, content := range files {
+		c, err := Load(Options{Path: write(t, name, content), LookupEnv: env(nil)})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(c.Server.AllowedModels, []string{"tiny.en", "base.en"}) || c.Models.CacheMaxBytes != 2<<30 {
+			t.Errorf("%s: got %+v", name, c)
+		}
+	}
+}
+
+func TestLoad_AuthSettings(t *testing.T) {
+	path := write(t, "config.toml", "[server]\nquota_window = \"1h\"\n[server.jwt]\njwks = \"https://id.example.com/jwks.json\"\naudience = [\"gosper\"]\n")
+	c, err := Load(Options{Path: path, LookupEnv: env(map[string]string{"GOSPER_JWT_JWKS_REFRESH": "10m"})})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	j := c.Server.JWT
+	if c.Server.QuotaWindow != time.Hour || j.JWKS != "https://id.example.com/jwks.json" || j.Refresh != 10*time.Minute || j.SubjectClaim != "sub" || !reflect.DeepEqual(j.Audience, []string{"gosper"}) {
+		t.Fatalf("unexpected server config %+v", c.Server)
+	}
+
+	_, err = Load(Options{LookupEnv: env(map[string]string{"GOSPER_JWT_ISSUER": "https://id.example.com"}), Path: write(t, "c.json", "{}")})
+	if err == nil || !strings.Contains(err.Error(), "server.jwt.jwks") {
+		t.Fatalf("issuer without a JWKS: %v", err)
+	}
+}
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	path := write(t, "config.yaml", "modle: tiny\nthreads: many\n")
+	_, err := Load(Options{Path: path, LookupEnv: env(map[string]string{"GOSPER_WARMUP": "perhaps"})})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{`unknown key "modle" (did you mean "model"?)`, `threads (from file ` + path + `): invalid integer "many"`, `server.warmup (from env GOSPER_WARMUP)`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	_, err = Load(Options{Path: filepath.Join(t.TempDir(), "missing.yaml"), LookupEnv: env(nil)})
+	if err == nil {
+		t.Fatal("a missing explicit config file should be an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := Load(Options{
+		Path:      write(t, "config.json", "{}"),
+		LookupEnv: env(map[string]string{"PORT": "9000"}),
+		Flags:     map[string]string{"language": "english", "audio.beep_volume": "2", "server.cors.allow_credentials": "true"},
+	})
+	if c.Server.Addr != ":9000" {
+		t.Errorf("PORT not applied: %q", c.Server.Addr)
+	}
+	if err == nil || !strings.Contains(err.Error(), "language (from flag)") || !strings.Contains(err.Error(), "audio.beep_volume") || !strings.Contains(err.Error(), "server.cors.allow_credentials") {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
+
+func TestSetInFile_KeepsOtherValuesAndMigratesLegacyKeys(t *testing.T) {
+	path := write(t, "config.json", `{"LastDeviceID": "index:1", "AudioFeedback": true, "Model": "tiny.en"}`)
+	c, err := Load(Options{Path: path, LookupEnv: env(nil)})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Audio.Device != "index:1" || !c.Audio.Feedback || c.Model != "tiny.en" {
+		t.Fatalf("legacy keys not read: %+v", c)
+	}
+
+	if err := SetInFile(path, "audio.device", "index:2"); err != nil {
+		t.Fatalf("SetInFile: %v", err)
+	}
+	if err := SetInFile(path, "threads", "x"); err == nil {
+		t.Fatal("invalid value written")
+	}
+	c, err = Load(Options{Path: path, LookupEnv: env(nil)})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Audio.Device != "index:2" || !c.Audio.Feedback || c.Model != "tiny.en" {
+		t.Fatalf("mismatch after set: %+v", c)
+	}
+	b, _ := os.ReadFile(path)
+	if strings.Contains(string(b), "LastDeviceID") {
+		t.Fatalf("legacy key kept alongside its replacement: %s", b)
+	}
+}
+
+func TestSetInFile_NestedYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "config.yaml")
+	if err := SetInFile(path, "models.pinned", "tiny.en, base.en"); err != nil {
+		t.Fatalf("SetInFile: %v", err)
+	}
+	if err := SetInFile(path, "server.warmup", "false"); err != nil {
+		t.Fatalf("SetInFile: %v", err)
+	}
+	c, err := Load(Options{Path: path, LookupEnv: env(nil)})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(c.Models.Pinned, []string{"tiny.en", "base.en"}) || c.Server.Warmup {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
+
+func TestPreloadModels(t *testing.T) {
+	c := Default()
+	if got := c.PreloadModels(); !reflect.DeepEqual(got, []string{"base.en"}) {
+		t.Errorf("default preload = %v", got)
+	}
+	c.Server.Preload = []string{"none"}
+	if got := c.PreloadModels(); got != nil {
+		t.Errorf("none preload = %v", got)
+	}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// legacyKeys maps the keys of the old CLI config.json to current keys, so
+// existing files keep working. They are rewritten on the next set.
+var legacyKeys = map[string]string{
+	"Model":          "model",
+	"Language":       "language",
+	"lang":           "language",
+	"Threads":        "threads",
+	"CacheDir":       "models.cache_dir",
+	"cache_dir":      "models.cache_dir",
+	"CacheMaxBytes":  "models.cache_max",
+	"LogLevel":       "log_level",
+	"LastDeviceID":   "audio.device",
+	"AudioFeedback":  "audio.feedback",
+	"OutputDeviceID": "audio.output_device",
+	"BeepVolume":     "audio.beep_volume",
+}
+
+// format returns the file format for path from its extension.
+func format(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return "yaml", nil
+	case ".toml":
+		return "toml", nil
+	case ".json":
+		return "json", nil
+	default:
+		return "", fmt.Errorf("config file %s: unsupported format %q (use .yaml, .toml or .json)", path, ext)
+	}
+}
+
+// readFile parses a config file into nested maps.
+func readFile(path string) (map[string]any, error) {
+	f, err := format(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := map[string]any{}
+	switch f {
+	case "yaml":
+		err = yaml.Unmarshal(b, &tree)
+	case "toml":
+		err = toml.Unmarshal(b, &tree)
+	case "json":
+		if len(bytes.TrimSpace(b)) > 0 {
+			err = json.Unmarshal(b, &tree)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if tree == nil {
+		tree = map[string]any{}
+	}
+	return tree, nil
+}
+
+// writeFile writes tree to path in the format its extension names,
+// atomically.
+func writeFile(path string, tree map[string]any) error {
+	f, err := format(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	switch f {
+	case "yaml":
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(tree)
+	case "toml":
+		err = toml.NewEncoder(&buf).Encode(tree)
+	case "json":
+		var b []byte
+		b, err = json.MarshalIndent(tree, "", "  ")
+		buf.Write(append(b, '\n'))
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// flatten turns nested maps into dotted keys.
+func flatten(prefix string, tree map[string]any, out map[string]any) {
+	for k, v := range tree {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]any); ok {
+			flatten(k, sub, out)
+			continue
+		}
+		out[k] = v
+	}
+}
+
+// applyFile applies the values in a parsed config file.
+func (c *Config) applyFile(tree map[string]any) []error {
+	flat := map[string]any{}
+	flatten("", tree, flat)
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	origin := "file " + c.path
+	for _, k := range keys {
+		key := k
+		if cur, ok := legacyKeys[k]; ok {
+			if _, dup := flat[cur]; dup {
+				continue
+			}
+			key = cur
+		}
+		if _, err := lookup(key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.path, err))
+			continue
+		}
+		if err := c.set(key, text(flat[k]), origin); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// text renders a file value as the text setting.parse accepts. Lists of
+// scalars become comma-separated; anything else structured, such as a list
+// of model sources, becomes JSON.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				b, _ := json.Marshal(v)
+				return string(b)
+			}
+			items = append(items, text(item))
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// SetInFile sets key to value in the config file at path, creating it if
+// needed and keeping its other contents. The value is checked first, so an
+// invalid value never reaches the file.
+func SetInFile(path, key, value string) error {
+	s, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	scratch := Default()
+	if err := scratch.set(key, value, "argument"); err != nil {
+		return err
+	}
+	if err := scratch.Validate(); err != nil {
+		return err
+	}
+	tree, err := readFile(path)
+	if os.IsNotExist(err) {
+		tree, err = map[string]any{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	for old, cur := range legacyKeys {
+		if cur == key {
+			delete(tree, old)
+		}
+	}
+
+	parts := strings.Split(key, ".")
+	m := tree
+	for _, p := range parts[:len(parts)-1] {
+		sub, ok := m[p].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			m[p] = sub
+		}
+		m = sub
+	}
+	v := s.value(scratch)
+	if _, ok := v.(int64); ok {
+		v = strings.TrimSpace(value) // keep sizes as written, e.g. 2G
+	}
+	m[parts[len(parts)-1]] = v
+	return writeFile(path, tree)
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosper/pkg/units"
+)
+
+// setting describes one configuration key: the environment variables that
+// set it and the Config field it holds. Fields are *string, *bool, *int,
+// *float64, *[]string (comma-separated in text), *int64 (a byte size such
+// as "2G") or *time.Duration (such as "24h").
+type setting struct {
+	key   string
+	env   []string
+	doc   string
+	field func(c *Config) any
+}
+
+var settings = []setting{
+	{"model", []string{"GOSPER_MODEL"}, "Default model name or local path", func(c *Config) any { return &c.Model }},
+	{"language", []string{"GOSPER_LANG"}, "Language code or auto", func(c *Config) any { return &c.Language }},
+	{"threads", []string{"GOSPER_THREADS"}, "Inference threads (0 for the default)", func(c *Config) any { return &c.Threads }},
+	{"log_level", []string{"GOSPER_LOG_LEVEL", "GOSPER_LOG"}, "debug, info, warn or error", func(c *Config) any { return &c.LogLevel }},
+	{"log_format", []string{"GOSPER_LOG_FORMAT"}, "json or text (empty for json in the server, text in the CLI)", func(c *Config) any { return &c.LogFormat }},
+
+	{"server.addr", []string{"GOSPER_ADDR"}, "HTTP listen address; PORT overrides the port", func(c *Config) any { return &c.Server.Addr }},
+	{"server.allowed_models", []string{"GOSPER_ALLOWED_MODELS"}, "Models clients may request (empty for all)", func(c *Config) any { return &c.Server.AllowedModels }},
+	{"server.preload", []string{"GOSPER_PRELOAD"}, "Models loaded before ready (empty for the default model, none for none)", func(c *Config) any { return &c.Server.Preload }},
+	{"server.warmup", []string{"GOSPER_WARMUP"}, "Warm up preloaded models", func(c *Config) any { return &c.Server.Warmup }},
+	{"server.metrics", []string{"GOSPER_METRICS"}, "Serve Prometheus metrics at /metrics", func(c *Config) any { return &c.Server.Metrics }},
+	{"server.max_upload", []string{"GOSPER_MAX_UPLOAD"}, "Largest request body accepted, e.g. 100M (0 for no limit)", func(c *Config) any { return &c.Server.MaxUpload }},
+	{"server.max_audio_duration", []string{"GOSPER_MAX_AUDIO_DURATION"}, "Longest audio accepted (0 for no limit)", func(c *Config) any { return &c.Server.MaxAudioDuration }},
+	{"server.temp_dir", []string{"GOSPER_TEMP_DIR"}, "Directory uploads are kept in while processed (empty for one in the system temp dir)", func(c *Config) any { return &c.Server.TempDir }},
+	{"server.audio_url.allowed_hosts", []string{"GOSPER_AUDIO_URL_HOSTS"}, "Hosts audio_url may fetch from: exact or *.example.com (empty disables audio_url)", func(c *Config) any { return &c.Server.AudioURL.AllowedHosts }},
+	{"server.audio_url.allow_private", []string{"GOSPER_AUDIO_URL_ALLOW_PRIVATE"}, "Allow audio_url hosts that resolve to private addresses", func(c *Config) any { return &c.Server.AudioURL.AllowPrivate }},
+	{"server.audio_url.timeout", []string{"GOSPER_AUDIO_URL_TIMEOUT"}, "Time allowed for fetching an audio_url", func(c *Config) any { return &c.Server.AudioURL.Timeout }},
+	{"server.api_keys", []string{"GOSPER_API_KEYS"}, "API keys as id:sha256:<hex> (empty with no key file for an open API)", func(c *Config) any { return &c.Server.APIKeys }},
+	{"server.api_keys_file", []string{"GOSPER_API_KEYS_FILE"}, "YAML or JSON file of API keys and their quotas", func(c *Config) any { return &c.Server.APIKeysFile }},
+	{"server.quota_window", []string{"GOSPER_QUOTA_WINDOW"}, "Period after which per-key usage resets (0 for never)", func(c *Config) any { return &c.Server.QuotaWindow }},
+	{"server.rate_limit.requests", []string{"GOSPER_RATE_LIMIT_REQUESTS"}, "Requests each client may send per rate window (0 for no limit)", func(c *Config) any { return &c.Server.RateLimit.Requests }},
+	{"server.rate_limit.audio_seconds", []string{"GOSPER_RATE_LIMIT_AUDIO"}, "Seconds of audio each client may submit per rate window (0 for no limit)", func(c *Config) any { return &c.Server.RateLimit.AudioSeconds }},
+	{"server.rate_limit.window", []string{"GOSPER_RATE_LIMIT_WINDOW"}, "Window the rate limits refill over", func(c *Config) any { return &c.Server.RateLimit.Window }},
+	{"server.trusted_proxies", []string{"GOSPER_TRUSTED_PROXIES"}, "Proxy addresses or CIDRs whose X-Forwarded-For is believed", func(c *Config) any { return &c.Server.TrustedProxies }},
+	{"server.cors.allowed_origins", []string{"GOSPER_CORS_ORIGINS"}, "Origins browsers may call from: exact, https://*.example.com or * (empty for none)", func(c *Config) any { return &c.Server.CORS.AllowedOrigins }},
+	{"server.cors.allowed_headers", []string{"GOSPER_CORS_HEADERS"}, "Request headers browsers may send cross-origin", func(c *Config) any { return &c.Server.CORS.AllowedHeaders }},
+	{"server.cors.allow_credentials", []string{"GOSPER_CORS_CREDENTIALS"}, "Allow cross-origin requests with cookies", func(c *Config) any { return &c.Server.CORS.AllowCredentials }},
+	{"server.cors.max_age", []string{"GOSPER_CORS_MAX_AGE"}, "How long browsers may cache preflight responses", func(c *Config) any { return &c.Server.CORS.MaxAge }},
+	{"server.jwt.jwks", []string{"GOSPER_JWT_JWKS"}, "JWKS file or URL that verifies bearer JWTs (empty disables JWTs)", func(c *Config) any { return &c.Server.JWT.JWKS }},
+	{"server.jwt.jwks_refresh", []string{"GOSPER_JWT_JWKS_REFRESH"}, "Maximum age of keys fetched from a JWKS URL", func(c *Config) any { return &c.Server.JWT.Refresh }},
+	{"server.jwt.issuer", []string{"GOSPER_JWT_ISSUER"}, "Required iss claim (empty accepts any)", func(c *Config) any { return &c.Server.JWT.Issuer }},
+	{"server.jwt.audience", []string{"GOSPER_JWT_AUDIENCE"}, "Accepted aud claims (empty accepts any)", func(c *Config) any { return &c.Server.JWT.Audience }},
+	{"server.jwt.subject_claim", []string{"GOSPER_JWT_SUBJECT_CLAIM"}, "Claim that identifies the client", func(c *Config) any { return &c.Server.JWT.SubjectClaim }},
+	{"server.tracing.exporter", []string{"GOSPER_TRACING_EXPORTER"}, "Where request traces go: none, otlp or stdout", func(c *Config) any { return &c.Server.Tracing.Exporter }},
+	{"server.tracing.endpoint", []string{"GOSPER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT"}, "OTLP/HTTP collector URL traces are sent to", func(c *Config) any { return &c.Server.Tracing.Endpoint }},
+	{"server.tracing.sample_ratio", []string{"GOSPER_TRACING_SAMPLE_RATIO"}, "Share of new traces recorded, 0..1", func(c *Config) any { return &c.Server.Tracing.SampleRatio }},
+	{"server.tracing.service_name", []string{"GOSPER_SERVICE_NAME", "OTEL_SERVICE_NAME"}, "Service name traces are reported under", func(c *Config) any { return &c.Server.Tracing.ServiceName }},
+
+	{"models.base_url", []string{"MODEL_BASE_URL"}, "Base URL models are downloaded from", func(c *Config) any { return &c.Models.BaseURL }},
+	{"models.sources", []string{"MODEL_SOURCES"}, "Ordered model sources tried before base_url", func(c *Config) any { return &c.Models.Sources }},
+	{"models.cache_dir", []string{"MODEL_CACHE_DIR", "GOSPER_CACHE"}, "Model cache directory (empty for the user cache dir)", func(c *Config) any { return &c.Models.CacheDir }},
+	{"models.cache_max", []string{"MODEL_CACHE_MAX", "GOSPER_CACHE_MAX"}, "Model cache quota, e.g. 2G (0 for none)", func(c *Config) any { return &c.Models.CacheMaxBytes }},
+	{"models.pinned", []string{"MODEL_PINNED"}, "Models never evicted from the cache", func(c *Config) any { return &c.Models.Pinned }},
+
+	{"audio.device", []string{"GOSPER_DEVICE"}, "Input device for recording", func(c *Config) any { return &c.Audio.Device }},
+	{"audio.feedback", []string{"GOSPER_AUDIO_FEEDBACK"}, "Beep when recording starts and stops", func(c *Config) any { return &c.Audio.Feedback }},
+	{"audio.output_device", []string{"GOSPER_OUTPUT_DEVICE"}, "Output device for the beep", func(c *Config) any { return &c.Audio.OutputDevice }},
+	{"audio.beep_volume", []string{"GOSPER_BEEP_VOLUME"}, "Beep volume, 0..1 (0 for the default)", func(c *Config) any { return &c.Audio.BeepVolume }},
+}
+
+// Keys returns every configuration key.
+func Keys() []string {
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.key
+	}
+	return keys
+}
+
+// Describe returns the description and environment variables of key.
+func Describe(key string) (doc string, env []string, err error) {
+	s, err := lookup(key)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.doc, s.env, nil
+}
+
+func lookup(key string) (setting, error) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, nil
+		}
+	}
+	if near := closest(key); near != "" {
+		return setting{}, fmt.Errorf("unknown key %q (did you mean %q?)", key, near)
+	}
+	return setting{}, fmt.Errorf("unknown key %q", key)
+}
+
+func (s setting) parse(c *Config, v string) error {
+	v = strings.TrimSpace(v)
+	switch p := s.field(c).(type) {
+	case *string:
+		*p = v
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q (use true or false)", v)
+		}
+		*p = b
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*p = n
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*p = f
+	case *int64:
+		n, err := units.ParseBytes(v)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (use e.g. 30m or 24h)", v)
+		}
+		*p = d
+	case *[]string:
+		*p = splitList(v)
+	}
+	return nil
+}
+
+func (s setting) format(c *Config) string {
+	switch p := s.field(c).(type) {
+	case *string:
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *float64:
+		return strconv.FormatFloat(*p, 'f', -1, 64)
+	case *int64:
+		return strconv.FormatInt(*p, 10)
+	case *time.Duration:
+		return p.String()
+	case *[]string:
+		return strings.Join(*p, ",")
+	}
+	return ""
+}
+
+// value returns the field as the value to store in a file.
+func (s setting) value(c *Config) any {
+	switch p := s.field(c).(type) {
+	case *string:
+		return *p
+	case *bool:
+		return *p
+	case *int:
+		return *p
+	case *float64:
+		return *p
+	case *int64:
+		return *p
+	case *time.Duration:
+		return p.String()
+	case *[]string:
+		return append([]string{}, *p...)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// closest returns the key nearest to key by edit distance, if it is near
+// enough to be a likely typo.
+func closest(key string) string {
+	best, bestDist := "", 3
+	keys := Keys()
+	sort.Strings(keys)
+	for _, k := range keys {
+		if d := editDistance(key, k); d < bestDist {
+			best, bestDist = k, d
+		}
+		// a missing or extra section ("cache_dir" for "models.cache_dir")
+		if i := strings.IndexByte(k, '.'); i >= 0 && k[i+1:] == key && best == "" {
+			best = k
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Validate checks values that parse but make no sense, reporting every
+// problem with the key and where its value came from.
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s (from %s): %s", key, c.Origin(key), fmt.Sprintf(format, args...)))
+	}
+
+	if c.Model == "" {
+		bad("model", "must not be empty")
+	}
+	if !validLanguage(c.Language) {
+		bad("language", "%q is not a language code such as en, or auto", c.Language)
+	}
+	if c.Threads < 0 {
+		bad("threads", "must not be negative")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		bad("log_level", "%q is not one of debug, info, warn, error", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case "", "json", "text":
+	default:
+		bad("log_format", "%q is not json or text", c.LogFormat)
+	}
+
+	if _, _, err := splitHostPort(c.Server.Addr); err != nil {
+		bad("server.addr", "%v", err)
+	}
+	for _, m := range c.Server.Preload {
+		if m == "none" && len(c.Server.Preload) > 1 {
+			bad("server.preload", "none cannot be combined with models")
+		}
+	}
+
+	if c.Server.MaxUpload < 0 {
+		bad("server.max_upload", "must not be negative")
+	}
+	if c.Server.MaxAudioDuration < 0 {
+		bad("server.max_audio_duration", "must not be negative")
+	}
+
+	for _, h := range c.Server.AudioURL.AllowedHosts {
+		if !validHostPattern(h) {
+			bad("server.audio_url.allowed_hosts", "%q is not a host name, address or *.domain pattern", h)
+		}
+	}
+	if len(c.Server.AudioURL.AllowedHosts) > 0 && c.Server.AudioURL.Timeout <= 0 {
+		bad("server.audio_url.timeout", "must be positive")
+	}
+
+	rl := c.Server.RateLimit
+	if rl.Requests < 0 {
+		bad("server.rate_limit.requests", "must not be negative")
+	}
+	if rl.AudioSeconds < 0 {
+		bad("server.rate_limit.audio_seconds", "must not be negative")
+	}
+	if (rl.Requests > 0 || rl.AudioSeconds > 0) && rl.Window <= 0 {
+		bad("server.rate_limit.window", "must be positive")
+	}
+	for _, p := range c.Server.TrustedProxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				bad("server.trusted_proxies", "%q is not an IP address or CIDR prefix", p)
+			}
+		}
+	}
+	for _, o := range c.Server.CORS.AllowedOrigins {
+		if o == "*" {
+			if c.Server.CORS.AllowCredentials {
+				bad("server.cors.allow_credentials", "cannot be combined with allowed origin *; list the origins")
+			}
+			continue
+		}
+		if !validOrigin(o) {
+			bad("server.cors.allowed_origins", "%q is not an origin such as https://app.example.com or https://*.example.com", o)
+		}
+	}
+	if j := c.Server.JWT; j.JWKS != "" {
+		if strings.Contains(j.JWKS, "://") {
+			if p, err := url.Parse(j.JWKS); err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+				bad("server.jwt.jwks", "%q is not an http(s) URL", j.JWKS)
+			}
+		}
+		if j.SubjectClaim == "" {
+			bad("server.jwt.subject_claim", "must not be empty")
+		}
+	} else if j.Issuer != "" || len(j.Audience) > 0 {
+		bad("server.jwt.jwks", "must be set to verify tokens for server.jwt.issuer or server.jwt.audience")
+	}
+	switch t := c.Server.Tracing; t.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if p, err := url.Parse(t.Endpoint); err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+			bad("server.tracing.endpoint", "%q is not an http(s) URL", t.Endpoint)
+		}
+	default:
+		bad("server.tracing.exporter", "%q is not one of none, otlp, stdout", t.Exporter)
+	}
+	if r := c.Server.Tracing.SampleRatio; r < 0 || r > 1 {
+		bad("server.tracing.sample_ratio", "%v is outside 0..1", r)
+	}
+
+	if u := c.Models.BaseURL; u != "" {
+		if p, err := url.Parse(u); err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+			bad("models.base_url", "%q is not an http(s) URL", u)
+		}
+	}
+	if c.Models.CacheMaxBytes < 0 {
+		bad("models.cache_max", "must not be negative")
+	}
+
+	if v := c.Audio.BeepVolume; v < 0 || v > 1 {
+		bad("audio.beep_volume", "%v is outside 0..1", v)
+	}
+	return errors.Join(errs...)
+}
+
+// validOrigin reports whether o is scheme://host[:port], where host may
+// start with "*." to match subdomains.
+func validOrigin(o string) bool {
+	u, err := url.Parse(strings.Replace(o, "://*.", "://wildcard.", 1))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.User == nil && !strings.Contains(u.Host, "*")
+}
+
+// validHostPattern reports whether h is a bare host name or address, or
+// one prefixed with "*." to match subdomains.
+func validHostPattern(h string) bool {
+	h = strings.TrimPrefix(h, "*.")
+	if h == "" || strings.ContainsAny(h, "/:@*? ") {
+		_, err := netip.ParseAddr(h)
+		return err == nil
+	}
+	return true
+}
+
+func validLanguage(l string) bool {
+	if l == "auto" {
+		return true
+	}
+	if len(l) < 2 || len(l) > 3 {
+		return false
+	}
+	for _, r := range l {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitHostPort is net.SplitHostPort with a numeric port check and a hint
+// for the common mistake of a bare port.
+func splitHostPort(addr string) (host, port string, err error) {
+	host, port, err = net.SplitHostPort(addr)
+	if err != nil {
+		if _, convErr := strconv.Atoi(addr); convErr == nil {
+			return "", "", fmt.Errorf("%q is missing a colon, use %q", addr, ":"+addr)
+		}
+		return "", "", fmt.Errorf("%q is not host:port", addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", "", fmt.Errorf("invalid port %q", port)
+	}
+	return host, port, nil
+}
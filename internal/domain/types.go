@@ -1,18 +1,81 @@
 package domain
 
+import "time"
+
+// TranscriptToken is a single decoder token with its timing and probability.
+type TranscriptToken struct {
+    ID          int
+    Text        string
+    StartMS     int64
+    EndMS       int64
+    Probability float32
+}
+
+// TranscriptWord is a word assembled from one or more tokens.
+type TranscriptWord struct {
+    Text        string
+    StartMS     int64
+    EndMS       int64
+    Probability float32 // mean of the token probabilities
+}
+
 // TranscriptSegment represents a segment of transcribed audio.
+// Words and Tokens are only populated when timestamps were requested.
 type TranscriptSegment struct {
     Index   int
     StartMS int64
     EndMS   int64
     Text    string
+    Words   []TranscriptWord  `json:",omitempty"`
+    Tokens  []TranscriptToken `json:",omitempty"`
+
+    // Confidence signals derived from the decoder token data. NoSpeechProb
+    // is whisper's own no-speech probability, 0 when the bindings do not
+    // report it.
+    AvgLogProb       float32
+    NoSpeechProb     float32 `json:",omitempty"`
+    CompressionRatio float32
+    LowConfidence    bool // set when any ModelConfig confidence threshold is crossed
 }
 
 // Transcript is the full transcription result.
+// Language is the language whisper decoded with: the requested one, or the
+// detected one when "auto" was requested (LanguageProbs is then populated).
 type Transcript struct {
-    Language string
-    Segments []TranscriptSegment
-    FullText string
+    Language      string
+    LanguageProbs []LanguageProb `json:",omitempty"`
+    Segments      []TranscriptSegment
+    FullText      string
+    Removed       []RemovedSegment `json:",omitempty"` // what post-processing took out
+    Duration      time.Duration    `json:"-"`          // length of the audio transcribed
+}
+
+// RemovedSegment records text removed from a transcript by post-processing.
+// Text is the removed text, which for a collapsed loop is only the repeats.
+type RemovedSegment struct {
+    Index   int
+    StartMS int64
+    EndMS   int64
+    Text    string
+    Reason  string // e.g. "duplicate", "ngram_loop", "hallucination", "zero_duration", "low_confidence"
+}
+
+// LanguageProb is the probability whisper assigns to a spoken language.
+type LanguageProb struct {
+    Language    string // ISO 639-1 code, e.g. "en"
+    Probability float32
+}
+
+// DetectWindowSamples is how much 16 kHz audio language identification
+// looks at: whisper's single 30 s encoder window.
+const DetectWindowSamples = 30 * 16000
+
+// LanguageDetection is the result of language identification, with
+// Probabilities sorted from most to least likely.
+type LanguageDetection struct {
+    Language      string
+    Probabilities []LanguageProb
+    Duration      time.Duration `json:"-"` // length of the audio analysed
 }
 
 // ModelConfig holds model/runtime parameters for transcription.
@@ -26,6 +89,24 @@ type ModelConfig struct {
     BeamSize      int
     MaxTokens     uint
     InitialPrompt string
+
+    // Decoding parameters; zero values keep whisper's defaults.
+    Temperature       float32 // initial sampling temperature (0 = greedy)
+    TemperatureInc    float32 // fallback step when a segment fails the thresholds below; negative disables fallback
+    BestOf            int     // candidates sampled when temperature > 0
+    EntropyThreshold  float32 // fall back when token entropy exceeds this
+    LogProbThreshold  float32 // fall back when the average log-probability is below this
+    NoSpeechThreshold float32 // treat a segment as silence above this no-speech probability
+    AllowBlank        bool    // disable whisper's suppression of blank output at segment start
+    SuppressRegex     string  // tokens matching this regular expression are never emitted
+    MaxSegmentLength  uint    // maximum segment length in characters (0 = unlimited)
+    SplitOnWord       bool    // split segments on word rather than token boundaries
+
+    // Confidence thresholds; zero disables the corresponding check.
+    MinAvgLogProb       float32 // flag segments with a lower average log-probability
+    MaxNoSpeechProb     float32 // flag segments with a higher no-speech probability
+    MaxCompressionRatio float32 // flag segments with a higher compression ratio (repetitive text)
+    DropLowConfidence   bool    // drop flagged segments instead of only marking them
 }
 
 // AudioFormat describes captured/decoded audio format.
@@ -41,3 +122,57 @@ type Device struct {
     Name string
 }
 
+// CachedModel describes a model file held in the local model cache.
+type CachedModel struct {
+    Name     string
+    File     string
+    Size     int64
+    LastUsed time.Time
+    Pinned   bool // never evicted
+    InUse    bool // held by a running job in this process
+}
+
+// CacheUsage summarises the local model cache. QuotaBytes is 0 when the
+// cache is unbounded.
+type CacheUsage struct {
+    Dir        string
+    UsedBytes  int64
+    QuotaBytes int64
+    Models     []CachedModel
+}
+
+// ModelInfo describes a model a client may ask for.
+type ModelInfo struct {
+    Name         string // canonical alias, e.g. "base.en"
+    File         string
+    Size         int64 // bytes on disk when cached, else the approximate download size
+    Quantization string
+    Multilingual bool
+    Cached       bool
+    Path         string // local path when cached
+    Loaded       bool   // kept in memory by the transcriber
+    Default      bool
+}
+
+// Principal is an authenticated API client.
+type Principal struct {
+    ID      string         // stable identifier: the key ID, or the token subject
+    Method  string         // how it authenticated: "api_key" or "jwt"
+    Claims  map[string]any `json:",omitempty"` // token claims, for JWTs
+    Quota   Quota
+}
+
+// Quota limits what a principal may use per quota window; zero fields are
+// unlimited.
+type Quota struct {
+    Requests     int64
+    AudioSeconds float64
+}
+
+// Usage is what a principal has used in the current quota window.
+type Usage struct {
+    Requests     int64
+    AudioSeconds float64
+    Since        time.Time // start of the window
+    Resets       time.Time // end of the window; zero when quotas never reset
+}
@@ -21,11 +21,50 @@ type ModelRepo interface {
     Ensure(ctx context.Context, modelName string) (localPath string, err error)
 }
 
+// ModelHolder is implemented by repositories that may evict cached models.
+// A held model is not evicted until release is called.
+type ModelHolder interface {
+    Hold(localPath string) (release func())
+}
+
+// ModelCatalog describes the models a repository can provide.
+type ModelCatalog interface {
+    // Catalog lists known models with their cache state.
+    Catalog(ctx context.Context) ([]domain.ModelInfo, error)
+    // Resolve maps an alias or file name to its catalog entry.
+    Resolve(ctx context.Context, name string) (domain.ModelInfo, bool, error)
+}
+
+// ModelCache reports on the models a repository keeps locally.
+type ModelCache interface {
+    CacheUsage(ctx context.Context) (domain.CacheUsage, error)
+}
+
+// Authenticator verifies a client credential, such as an API key or a
+// bearer token, and returns who presented it. Unknown or invalid
+// credentials are Unauthenticated errors; valid ones that may not be used
+// are PermissionDenied.
+type Authenticator interface {
+    Authenticate(ctx context.Context, credential string) (domain.Principal, error)
+}
+
 type Transcriber interface {
     // Accepts mono PCM @16kHz float32 samples.
     Transcribe(ctx context.Context, pcm16k []float32, cfg domain.ModelConfig) (domain.Transcript, error)
 }
 
+// ModelLoader is implemented by transcribers that can keep a model loaded
+// between jobs instead of loading it for each one.
+type ModelLoader interface {
+    Load(ctx context.Context, localPath string) error
+    Loaded() []string // paths of the loaded models
+}
+
+type LanguageDetector interface {
+    // Accepts mono PCM @16kHz float32 samples; only the first 30 s are used.
+    DetectLanguage(ctx context.Context, pcm16k []float32, cfg domain.ModelConfig) (domain.LanguageDetection, error)
+}
+
 type AudioInput interface {
     ListDevices(ctx context.Context) ([]domain.Device, error)
     Open(ctx context.Context, deviceID string, fmt domain.AudioFormat) (AudioStream, error)
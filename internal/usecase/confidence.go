@@ -0,0 +1,43 @@
+package usecase
+
+import "gosper/internal/domain"
+
+// Reference thresholds, matching the values OpenAI's whisper uses for its
+// own fallback decisions. Inbound adapters use them as defaults.
+const (
+    DefaultMinAvgLogProb       float32 = -1.0
+    DefaultMaxNoSpeechProb     float32 = 0.6
+    DefaultMaxCompressionRatio float32 = 2.4
+)
+
+// applyConfidence marks segments that cross the confidence thresholds in cfg
+// and, when cfg.DropLowConfidence is set, removes them and rebuilds FullText.
+// As in whisper, a high no-speech probability only counts when the average
+// log-probability is also low (or that check is disabled).
+func applyConfidence(tr domain.Transcript, cfg domain.ModelConfig) domain.Transcript {
+    if cfg.MinAvgLogProb == 0 && cfg.MaxNoSpeechProb == 0 && cfg.MaxCompressionRatio == 0 {
+        return tr
+    }
+    kept := tr.Segments[:0:0]
+    for _, s := range tr.Segments {
+        lowProb := cfg.MinAvgLogProb != 0 && s.AvgLogProb < cfg.MinAvgLogProb
+        noSpeech := cfg.MaxNoSpeechProb != 0 && s.NoSpeechProb > cfg.MaxNoSpeechProb &&
+            (cfg.MinAvgLogProb == 0 || lowProb)
+        repetitive := cfg.MaxCompressionRatio != 0 && s.CompressionRatio > cfg.MaxCompressionRatio
+        s.LowConfidence = lowProb || noSpeech || repetitive
+        if s.LowConfidence && cfg.DropLowConfidence {
+            tr.Removed = append(tr.Removed, removed(s, s.Text, ReasonLowConfidence))
+            continue
+        }
+        kept = append(kept, s)
+    }
+    tr.Segments = kept
+    if cfg.DropLowConfidence { tr.FullText = joinText(kept) }
+    return tr
+}
+
+func joinText(segs []domain.TranscriptSegment) string {
+    var full string
+    for _, s := range segs { full += s.Text }
+    return full
+}
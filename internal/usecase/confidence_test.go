@@ -0,0 +1,42 @@
+package usecase
+
+import (
+    "testing"
+
+    "gosper/internal/domain"
+)
+
+func TestApplyConfidence_FlagAndDrop(t *testing.T) {
+    tr := domain.Transcript{
+        FullText: " good bad loop",
+        Segments: []domain.TranscriptSegment{
+            {Index: 0, Text: " good", AvgLogProb: -0.2, NoSpeechProb: 0.7, CompressionRatio: 1.1},
+            {Index: 1, Text: " bad", AvgLogProb: -1.5, CompressionRatio: 1.0},
+            {Index: 2, Text: " loop", AvgLogProb: -0.3, CompressionRatio: 3.0},
+        },
+    }
+    cfg := domain.ModelConfig{
+        MinAvgLogProb:       DefaultMinAvgLogProb,
+        MaxNoSpeechProb:     DefaultMaxNoSpeechProb,
+        MaxCompressionRatio: DefaultMaxCompressionRatio,
+    }
+
+    flagged := applyConfidence(tr, cfg)
+    if len(flagged.Segments) != 3 { t.Fatalf("flagging must not drop segments") }
+    got := []bool{flagged.Segments[0].LowConfidence, flagged.Segments[1].LowConfidence, flagged.Segments[2].LowConfidence}
+    if got[0] || !got[1] || !got[2] { t.Fatalf("unexpected flags: %v", got) }
+    if tr.Segments[1].LowConfidence { t.Fatalf("input transcript was mutated") }
+
+    cfg.DropLowConfidence = true
+    dropped := applyConfidence(tr, cfg)
+    if len(dropped.Segments) != 1 || dropped.FullText != " good" {
+        t.Fatalf("unexpected result after drop: %+v", dropped)
+    }
+}
+
+func TestApplyConfidence_DisabledIsNoop(t *testing.T) {
+    tr := domain.Transcript{Segments: []domain.TranscriptSegment{{AvgLogProb: -5}}}
+    if got := applyConfidence(tr, domain.ModelConfig{DropLowConfidence: true}); len(got.Segments) != 1 || got.Segments[0].LowConfidence {
+        t.Fatalf("expected no-op, got %+v", got)
+    }
+}
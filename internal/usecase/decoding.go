@@ -0,0 +1,38 @@
+package usecase
+
+import (
+    "fmt"
+    "regexp"
+
+    "gosper/internal/domain"
+)
+
+// maxDecoders mirrors WHISPER_MAX_DECODERS, the upper bound for both beam
+// size and best-of.
+const maxDecoders = 8
+
+// validateDecoding rejects decoding parameters whisper cannot honour.
+func validateDecoding(cfg domain.ModelConfig) error {
+    switch {
+    case cfg.BeamSize < 0 || cfg.BeamSize > maxDecoders:
+        return fmt.Errorf("beam size must be between 0 and %d, got %d", maxDecoders, cfg.BeamSize)
+    case cfg.BestOf < 0 || cfg.BestOf > maxDecoders:
+        return fmt.Errorf("best-of must be between 0 and %d, got %d", maxDecoders, cfg.BestOf)
+    case cfg.Temperature < 0 || cfg.Temperature > 1:
+        return fmt.Errorf("temperature must be between 0 and 1, got %g", cfg.Temperature)
+    case cfg.TemperatureInc > 1:
+        return fmt.Errorf("temperature increment must be at most 1, got %g", cfg.TemperatureInc)
+    case cfg.EntropyThreshold < 0:
+        return fmt.Errorf("entropy threshold must not be negative, got %g", cfg.EntropyThreshold)
+    case cfg.LogProbThreshold > 0:
+        return fmt.Errorf("log-probability threshold must not be positive, got %g", cfg.LogProbThreshold)
+    case cfg.NoSpeechThreshold < 0 || cfg.NoSpeechThreshold > 1:
+        return fmt.Errorf("no-speech threshold must be between 0 and 1, got %g", cfg.NoSpeechThreshold)
+    }
+    if cfg.SuppressRegex != "" {
+        if _, err := regexp.Compile(cfg.SuppressRegex); err != nil {
+            return fmt.Errorf("invalid suppress regex: %w", err)
+        }
+    }
+    return nil
+}
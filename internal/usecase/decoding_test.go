@@ -0,0 +1,24 @@
+package usecase
+
+import (
+    "testing"
+
+    "gosper/internal/domain"
+)
+
+func TestValidateDecoding(t *testing.T) {
+    ok := []domain.ModelConfig{
+        {},
+        {Temperature: 0.2, TemperatureInc: -1, BestOf: 5, BeamSize: 5, EntropyThreshold: 2.4, LogProbThreshold: -1, NoSpeechThreshold: 0.6, SuppressRegex: `^\[.*\]$`},
+    }
+    for _, c := range ok {
+        if err := validateDecoding(c); err != nil { t.Errorf("validateDecoding(%+v) = %v", c, err) }
+    }
+    bad := []domain.ModelConfig{
+        {BeamSize: 9}, {BestOf: -1}, {Temperature: 1.5}, {TemperatureInc: 2},
+        {EntropyThreshold: -1}, {LogProbThreshold: 0.5}, {NoSpeechThreshold: 2}, {SuppressRegex: "("},
+    }
+    for _, c := range bad {
+        if err := validateDecoding(c); err == nil { t.Errorf("expected error for %+v", c) }
+    }
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+    "context"
+    "fmt"
+    "path/filepath"
+    "time"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+    "gosper/pkg/trace"
+)
+
+// DetectLanguage identifies the spoken language of an audio file without
+// transcribing it.
+type DetectLanguage struct {
+    Repo     port.ModelRepo
+    Detector port.LanguageDetector
+    Log      port.Logger // stage timings; nil for none
+    Clock    port.Clock  // nil for the system clock
+    Factory  DecoderFactory
+}
+
+type DetectInput struct {
+    Path      string
+    ModelName string // must be a multilingual model for a meaningful answer
+    Threads   uint
+
+    MaxDuration time.Duration // longer audio is refused; 0 for no limit
+}
+
+func (uc *DetectLanguage) Execute(ctx context.Context, in DetectInput) (det domain.LanguageDetection, err error) {
+    ctx, span := trace.Start(ctx, "DetectLanguage.Execute", trace.String("model.name", in.ModelName))
+    defer func() {
+        if err == nil { span.SetAttrs(trace.Seconds("audio.duration", det.Duration), trace.String("language.detected", det.Language)) }
+        span.Fail(err)
+        span.End()
+    }()
+    if in.Path == "" {
+        return domain.LanguageDetection{}, herr.Wrap(herr.InvalidArgs, fmt.Errorf("missing input file path"))
+    }
+    ev := events{uc.Log, uc.Clock}
+    pcm16k, err := decode16k(ctx, ev, uc.Factory, in.Path, in.MaxDuration)
+    if err != nil {
+        return domain.LanguageDetection{}, audioError(err)
+    }
+    if len(pcm16k) > domain.DetectWindowSamples {
+        pcm16k = pcm16k[:domain.DetectWindowSamples]
+    }
+
+    modelPath, err := ensureModel(ctx, ev, uc.Repo, in.ModelName)
+    if err != nil {
+        return domain.LanguageDetection{}, herr.Wrap(herr.ModelError, err)
+    }
+    defer holdModel(uc.Repo, modelPath)()
+    cfg := domain.ModelConfig{
+        ModelName: filepath.Base(modelPath),
+        ModelPath: modelPath,
+        Language:  "auto",
+        Threads:   in.Threads,
+    }
+    start := ev.now()
+    det, err = uc.Detector.DetectLanguage(ctx, pcm16k, cfg)
+    if err != nil {
+        return domain.LanguageDetection{}, herr.Wrap(herr.TranscriptionError, err)
+    }
+    det.Duration = samplesDuration(len(pcm16k))
+    ev.inferred(ctx, "language detected", cfg.ModelName, det.Duration, ev.since(start), "language", det.Language)
+    return det, nil
+}
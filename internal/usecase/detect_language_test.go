@@ -0,0 +1,44 @@
+package usecase
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "gosper/internal/adapter/outbound/audio/decoder"
+    "gosper/internal/domain"
+)
+
+type fakeDetector struct{ gotPCM int; cfg domain.ModelConfig; err error }
+func (d *fakeDetector) DetectLanguage(ctx context.Context, pcm []float32, cfg domain.ModelConfig) (domain.LanguageDetection, error) {
+    d.gotPCM = len(pcm)
+    d.cfg = cfg
+    if d.err != nil { return domain.LanguageDetection{}, d.err }
+    return domain.LanguageDetection{Language: "de", Probabilities: []domain.LanguageProb{{Language: "de", Probability: 0.9}}}, nil
+}
+
+func TestDetectLanguage_UsesFirst30Seconds(t *testing.T) {
+    // 60 s @ 16 kHz; only the first 30 s should reach the detector
+    dec := &fakeDecoder{sr: 16000, ch: 1, pcm: make([]float32, 60*16000)}
+    det := &fakeDetector{}
+    uc := &DetectLanguage{
+        Repo: &fakeRepo{path: "/models/ggml-base.bin"}, Detector: det,
+        Factory: func(string)(decoder.Decoder,error){ return dec, nil },
+    }
+    got, err := uc.Execute(context.Background(), DetectInput{Path: "x.wav"})
+    if err != nil { t.Fatalf("unexpected error: %v", err) }
+    if got.Language != "de" { t.Fatalf("Language = %q", got.Language) }
+    if det.gotPCM != domain.DetectWindowSamples { t.Fatalf("detector got %d samples, want %d", det.gotPCM, domain.DetectWindowSamples) }
+    if det.cfg.ModelPath != "/models/ggml-base.bin" || det.cfg.Language != "auto" { t.Fatalf("unexpected cfg: %+v", det.cfg) }
+}
+
+func TestDetectLanguage_PropagatesErrors(t *testing.T) {
+    if _, err := (&DetectLanguage{}).Execute(context.Background(), DetectInput{}); err == nil { t.Fatal("expected error for missing path") }
+
+    dec := &fakeDecoder{sr: 16000, ch: 1, pcm: make([]float32, 16000)}
+    uc := &DetectLanguage{
+        Repo: &fakeRepo{path: "/m"}, Detector: &fakeDetector{err: errors.New("boom")},
+        Factory: func(string)(decoder.Decoder,error){ return dec, nil },
+    }
+    if _, err := uc.Execute(context.Background(), DetectInput{Path: "x"}); err == nil { t.Fatal("expected error") }
+}
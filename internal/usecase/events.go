@@ -0,0 +1,56 @@
+package usecase
+
+import (
+    "context"
+    "time"
+
+    "gosper/internal/port"
+)
+
+// events times the stages of a use case with its clock and reports them
+// to its logger; either may be nil, for the system clock and no logging.
+type events struct {
+    log   port.Logger
+    clock port.Clock
+}
+
+func (e events) now() time.Time {
+    if e.clock != nil { return e.clock.Now() }
+    return time.Now()
+}
+
+func (e events) since(t time.Time) time.Duration { return e.now().Sub(t) }
+
+func (e events) debug(ctx context.Context, msg string, kv ...any) {
+    if e.log != nil { e.log.Debug(ctx, msg, kv...) }
+}
+
+func (e events) info(ctx context.Context, msg string, kv ...any) {
+    if e.log != nil { e.log.Info(ctx, msg, kv...) }
+}
+
+// decoded reports a decoded file.
+func (e events) decoded(ctx context.Context, format string, rate, channels, samples int, took time.Duration) {
+    e.debug(ctx, "audio decoded", "format", format, "sample_rate", rate, "channels", channels,
+        "audio_ms", (time.Duration(samples) * time.Second / time.Duration(max(rate, 1))).Milliseconds(), "took_ms", took.Milliseconds())
+}
+
+// resampled reports resampling from rate to 16 kHz.
+func (e events) resampled(ctx context.Context, rate, in, out int, took time.Duration) {
+    e.debug(ctx, "audio resampled", "from_rate", rate, "to_rate", 16000, "ratio", 16000/float64(max(rate, 1)),
+        "samples_in", in, "samples_out", out, "took_ms", took.Milliseconds())
+}
+
+// resolved reports the model a name resolved to.
+func (e events) resolved(ctx context.Context, name, path string, took time.Duration) {
+    e.debug(ctx, "model resolved", "model", name, "path", path, "took_ms", took.Milliseconds())
+}
+
+// inferred reports an inference run on audio of the given length, with its
+// real-time factor: processing time over audio time, below 1 being faster
+// than real time.
+func (e events) inferred(ctx context.Context, op, model string, audio, took time.Duration, kv ...any) {
+    rtf := 0.0
+    if audio > 0 { rtf = took.Seconds() / audio.Seconds() }
+    e.info(ctx, op, append([]any{"model", model, "audio_ms", audio.Milliseconds(), "took_ms", took.Milliseconds(), "rtf", rtf}, kv...)...)
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "gosper/internal/adapter/outbound/audio/decoder"
+)
+
+// stepClock advances by step on every reading.
+type stepClock struct{ t time.Time; step time.Duration }
+
+func (c *stepClock) Now() time.Time { c.t = c.t.Add(c.step); return c.t }
+
+type logEntry struct{ level, msg string; kv map[string]any }
+
+// recordLogger keeps what is logged through it.
+type recordLogger struct{ entries []logEntry }
+
+func (l *recordLogger) log(level, msg string, kv []any) {
+    e := logEntry{level: level, msg: msg, kv: map[string]any{}}
+    for i := 0; i+1 < len(kv); i += 2 { e.kv[kv[i].(string)] = kv[i+1] }
+    l.entries = append(l.entries, e)
+}
+
+func (l *recordLogger) Debug(_ context.Context, msg string, kv ...any) { l.log("debug", msg, kv) }
+func (l *recordLogger) Info(_ context.Context, msg string, kv ...any)  { l.log("info", msg, kv) }
+func (l *recordLogger) Warn(_ context.Context, msg string, kv ...any)  { l.log("warn", msg, kv) }
+func (l *recordLogger) Error(_ context.Context, msg string, kv ...any) { l.log("error", msg, kv) }
+
+func TestTranscribeFile_Events(t *testing.T) {
+    dec := &fakeDecoder{sr: 8000, ch: 1, pcm: make([]float32, 16000)} // 2 s
+    logger := &recordLogger{}
+    uc := &TranscribeFile{
+        Repo: &fakeRepo{path: "/models/ggml-tiny.en.bin"}, Trans: &fakeTranscriber{}, Store: &fakeStorage{},
+        Log: logger, Clock: &stepClock{t: time.Unix(0, 0), step: 100 * time.Millisecond},
+        Factory: func(string) (decoder.Decoder, error) { return dec, nil },
+    }
+    if _, err := uc.Execute(context.Background(), TranscribeInput{Path: "a.wav", ModelName: "tiny.en", Language: "en"}); err != nil {
+        t.Fatal(err)
+    }
+
+    want := []struct{ level, msg string; kv map[string]any }{
+        {"debug", "audio decoded", map[string]any{"format": "wav", "sample_rate": 8000, "channels": 1, "audio_ms": int64(2000), "took_ms": int64(100)}},
+        {"debug", "audio resampled", map[string]any{"from_rate": 8000, "ratio": 2.0, "samples_in": 16000, "took_ms": int64(100)}},
+        {"debug", "model resolved", map[string]any{"model": "tiny.en", "path": "/models/ggml-tiny.en.bin", "took_ms": int64(100)}},
+        {"info", "transcribed", map[string]any{"model": "ggml-tiny.en.bin", "took_ms": int64(100), "rtf": 0.05, "language": "en"}},
+    }
+    if len(logger.entries) != len(want) { t.Fatalf("got %d events: %+v", len(logger.entries), logger.entries) }
+    for i, w := range want {
+        got := logger.entries[i]
+        if got.level != w.level || got.msg != w.msg { t.Errorf("event %d: %s %q, want %s %q", i, got.level, got.msg, w.level, w.msg); continue }
+        for k, v := range w.kv {
+            if got.kv[k] != v { t.Errorf("%s: %s = %v (%T), want %v", w.msg, k, got.kv[k], got.kv[k], v) }
+        }
+    }
+}
+
+func TestEvents_NilLoggerAndClock(t *testing.T) {
+    var ev events
+    start := ev.now()
+    ev.inferred(context.Background(), "transcribed", "m", time.Second, ev.since(start))
+    if ev.since(start) < 0 { t.Fatal("system clock went backwards") }
+}
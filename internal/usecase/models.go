@@ -0,0 +1,86 @@
+package usecase
+
+import (
+    "context"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+// Models answers which models clients may use. It is the single source for
+// model listings, request validation and the available-model count.
+type Models struct {
+    Catalog port.ModelCatalog
+    Cache   port.ModelCache  // optional
+    Loader  port.ModelLoader // optional, for the loaded state
+    // Allowed lists the models clients may request, by alias or file name.
+    // Empty allows every model in the catalog; names outside the catalog,
+    // such as file paths, are always rejected.
+    Allowed []string
+    Default string
+}
+
+// List returns the models clients may request.
+func (uc *Models) List(ctx context.Context) ([]domain.ModelInfo, error) {
+    all, err := uc.Catalog.Catalog(ctx)
+    if err != nil { return nil, herr.Wrap(herr.ModelError, err) }
+    allowed, err := uc.allowedFiles(ctx)
+    if err != nil { return nil, err }
+    def, _, _ := uc.Catalog.Resolve(ctx, uc.Default)
+    loaded := map[string]bool{}
+    if uc.Loader != nil {
+        for _, p := range uc.Loader.Loaded() { loaded[p] = true }
+    }
+    out := make([]domain.ModelInfo, 0, len(all))
+    for _, m := range all {
+        if allowed != nil && !allowed[m.File] { continue }
+        m.Default = m.File == def.File
+        m.Loaded = m.Path != "" && loaded[m.Path]
+        out = append(out, m)
+    }
+    return out, nil
+}
+
+// Available returns how many models clients may request.
+func (uc *Models) Available(ctx context.Context) (int, error) {
+    l, err := uc.List(ctx)
+    return len(l), err
+}
+
+// Check validates a requested model name, with "" meaning the default, and
+// returns the file name to pass to the repository. Unknown or disallowed
+// models are InvalidArgs, coded ModelNotFound or ModelNotAllowed.
+func (uc *Models) Check(ctx context.Context, name string) (string, error) {
+    if name == "" { name = uc.Default }
+    m, ok, err := uc.Catalog.Resolve(ctx, name)
+    if err != nil { return "", herr.Wrap(herr.ModelError, err) }
+    if !ok { return "", herr.Newf(herr.ModelNotFound, "unknown model %q", name).WithDetail("model", name) }
+    allowed, err := uc.allowedFiles(ctx)
+    if err != nil { return "", err }
+    if allowed != nil && !allowed[m.File] {
+        return "", herr.Newf(herr.ModelNotAllowed, "model %q is not allowed on this server", name).WithDetail("model", name)
+    }
+    return m.File, nil
+}
+
+// CacheUsage reports model cache usage, when the repository has a cache.
+func (uc *Models) CacheUsage(ctx context.Context) (domain.CacheUsage, bool, error) {
+    if uc.Cache == nil { return domain.CacheUsage{}, false, nil }
+    u, err := uc.Cache.CacheUsage(ctx)
+    if err != nil { return u, true, herr.Wrap(herr.FsError, err) }
+    return u, true, nil
+}
+
+// allowedFiles resolves Allowed to file names; nil means no restriction.
+// The default model is always allowed.
+func (uc *Models) allowedFiles(ctx context.Context) (map[string]bool, error) {
+    if len(uc.Allowed) == 0 { return nil, nil }
+    files := map[string]bool{}
+    for _, name := range append([]string{uc.Default}, uc.Allowed...) {
+        m, ok, err := uc.Catalog.Resolve(ctx, name)
+        if err != nil { return nil, herr.Wrap(herr.ModelError, err) }
+        if ok { files[m.File] = true }
+    }
+    return files, nil
+}
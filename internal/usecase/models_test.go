@@ -0,0 +1,60 @@
+package usecase
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "gosper/internal/domain"
+    herr "gosper/pkg/errors"
+)
+
+type fakeCatalog struct{ models []domain.ModelInfo }
+func (c *fakeCatalog) Catalog(ctx context.Context) ([]domain.ModelInfo, error) { return c.models, nil }
+func (c *fakeCatalog) Resolve(ctx context.Context, name string) (domain.ModelInfo, bool, error) {
+    for _, m := range c.models {
+        if m.Name == name || m.File == name { return m, true, nil }
+    }
+    return domain.ModelInfo{}, false, nil
+}
+
+func testCatalog() *fakeCatalog {
+    return &fakeCatalog{models: []domain.ModelInfo{
+        {Name: "tiny.en", File: "ggml-tiny.en.bin", Cached: true, Path: "/c/ggml-tiny.en.bin"},
+        {Name: "base.en", File: "ggml-base.en.bin", Cached: true, Path: "/c/ggml-base.en.bin"},
+        {Name: "large-v3", File: "ggml-large-v3.bin"},
+    }}
+}
+
+func TestModels_ListAndCheck_NoAllowlist(t *testing.T) {
+    uc := &Models{Catalog: testCatalog(), Loader: &fakeLoader{loaded: []string{"/c/ggml-base.en.bin"}}, Default: "tiny.en"}
+    list, err := uc.List(context.Background())
+    if err != nil || len(list) != 3 { t.Fatalf("List() = %+v, %v", list, err) }
+    if !list[0].Default || list[1].Default || !list[1].Loaded || list[0].Loaded {
+        t.Fatalf("default/loaded flags wrong: %+v", list)
+    }
+    if got, err := uc.Check(context.Background(), "large-v3"); err != nil || got != "ggml-large-v3.bin" {
+        t.Fatalf("Check(large-v3) = %q, %v", got, err)
+    }
+    if got, _ := uc.Check(context.Background(), ""); got != "ggml-tiny.en.bin" {
+        t.Fatalf("Check(\"\") = %q, want the default", got)
+    }
+    for _, bad := range []string{"/etc/passwd", "no-such-model"} {
+        if _, err := uc.Check(context.Background(), bad); !errors.Is(err, herr.InvalidArgs) || herr.From(err).Code != herr.ModelNotFound {
+            t.Errorf("Check(%q) err = %v, want InvalidArgs coded model_not_found", bad, err)
+        }
+    }
+}
+
+func TestModels_Allowlist(t *testing.T) {
+    uc := &Models{Catalog: testCatalog(), Allowed: []string{"ggml-base.en.bin"}, Default: "tiny.en"}
+    list, _ := uc.List(context.Background())
+    if len(list) != 2 || list[0].Name != "tiny.en" || list[1].Name != "base.en" {
+        t.Fatalf("List() = %+v, want the default and the allowed model", list)
+    }
+    if n, _ := uc.Available(context.Background()); n != 2 { t.Fatalf("Available() = %d", n) }
+    if _, err := uc.Check(context.Background(), "base.en"); err != nil { t.Fatalf("allowed model rejected: %v", err) }
+    if _, err := uc.Check(context.Background(), "large-v3"); !errors.Is(err, herr.InvalidArgs) || herr.From(err).Code != herr.ModelNotAllowed {
+        t.Fatalf("Check(large-v3) err = %v, want InvalidArgs coded model_not_allowed", err)
+    }
+}
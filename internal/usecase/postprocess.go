@@ -0,0 +1,217 @@
+package usecase
+
+import (
+    "strings"
+    "unicode"
+
+    "gosper/internal/domain"
+)
+
+// Reasons reported in domain.RemovedSegment.
+const (
+    ReasonDuplicate     = "duplicate"
+    ReasonNgramLoop     = "ngram_loop"
+    ReasonHallucination = "hallucination"
+    ReasonZeroDuration  = "zero_duration"
+    ReasonLowConfidence = "low_confidence"
+)
+
+// Filters toggles the transcript post-processing stages. The zero value
+// disables all of them.
+type Filters struct {
+    Duplicates     bool // drop a segment repeating the previous one
+    NgramLoops     bool // collapse a phrase repeated back to back inside a segment
+    Hallucinations bool // drop doubtful segments that are only a known phantom phrase
+    ZeroDuration   bool // drop segments whose end is not after their start
+}
+
+// DefaultFilters enables every stage; inbound adapters use it as default.
+var DefaultFilters = Filters{Duplicates: true, NgramLoops: true, Hallucinations: true, ZeroDuration: true}
+
+// Loop detection parameters: phrases of up to maxLoopN words repeated at
+// least minLoopRepeats times in a row are collapsed to one occurrence.
+const (
+    maxLoopN       = 6
+    minLoopRepeats = 4
+)
+
+// hallucinationPhrases lists text whisper commonly emits on silence or
+// music, keyed by language and stored normalized (see normalizeText). People
+// say these too, so a segment is only dropped for one when it is doubtful.
+var hallucinationPhrases = map[string][]string{
+    "en": {"thank you", "thanks for watching", "thank you for watching", "thank you so much for watching",
+        "please subscribe", "subscribe to my channel", "subtitles by the amara org community", "you"},
+    "de": {"untertitel im auftrag des zdf für funk 2017", "untertitel der amara org community", "vielen dank", "danke fürs zuschauen"},
+    "es": {"gracias", "gracias por ver", "subtítulos realizados por la comunidad de amara org", "suscríbete"},
+    "fr": {"merci", "merci d avoir regardé", "sous titres réalisés par la communauté d amara org"},
+    "it": {"grazie", "grazie per la visione", "sottotitoli creati dalla comunità amara org"},
+    "pt": {"obrigado", "obrigado por assistir", "legendas pela comunidade amara org"},
+    "ja": {"ご視聴ありがとうございました", "チャンネル登録よろしくお願いします"},
+    "ko": {"시청해주셔서 감사합니다", "구독과 좋아요 부탁드립니다"},
+    "zh": {"请不吝点赞 订阅 转发 打赏支持明镜与点点栏目", "谢谢观看", "字幕由amara org社区提供"},
+}
+
+// refine is the post-processing every transcription goes through:
+// confidence flags first, as the hallucination filter relies on them, then
+// the filters, with the same no-speech threshold.
+func refine(tr domain.Transcript, cfg domain.ModelConfig, f Filters) domain.Transcript {
+    return postProcess(applyConfidence(tr, cfg), f, cfg.MaxNoSpeechProb)
+}
+
+// postProcess applies the enabled filters in order and rebuilds FullText
+// when anything was removed. maxNoSpeech is the no-speech probability above
+// which a segment is doubtful; 0 means DefaultMaxNoSpeechProb. The input
+// transcript is not modified.
+func postProcess(tr domain.Transcript, f Filters, maxNoSpeech float32) domain.Transcript {
+    if f == (Filters{}) {
+        return tr
+    }
+    phrases := hallucinationPhrases[baseLanguage(tr.Language)]
+    maxNoSpeech = orDefault(maxNoSpeech, DefaultMaxNoSpeechProb)
+    removedList := append([]domain.RemovedSegment(nil), tr.Removed...)
+    kept := make([]domain.TranscriptSegment, 0, len(tr.Segments))
+    changed := false
+    for _, s := range tr.Segments {
+        norm := normalizeText(s.Text)
+        switch {
+        case f.ZeroDuration && s.EndMS <= s.StartMS:
+            removedList = append(removedList, removed(s, s.Text, ReasonZeroDuration))
+            changed = true
+            continue
+        case f.Hallucinations && doubtful(s, maxNoSpeech) && containsString(phrases, norm):
+            removedList = append(removedList, removed(s, s.Text, ReasonHallucination))
+            changed = true
+            continue
+        case f.Duplicates && len(kept) > 0 && norm != "" && norm == normalizeText(kept[len(kept)-1].Text):
+            removedList = append(removedList, removed(s, s.Text, ReasonDuplicate))
+            changed = true
+            continue
+        }
+        if f.NgramLoops {
+            if text, loop := collapseLoops(s.Text); loop != "" {
+                removedList = append(removedList, removed(s, loop, ReasonNgramLoop))
+                s.Text = text
+                s.Words, s.Tokens = nil, nil // no longer line up with the text
+                changed = true
+            }
+        }
+        kept = append(kept, s)
+    }
+    if !changed {
+        return tr
+    }
+    tr.Segments = kept
+    tr.Removed = removedList
+    tr.FullText = joinText(kept)
+    return tr
+}
+
+// doubtful reports whether s looks like whisper filling silence rather than
+// transcribing speech: flagged low confidence, or a no-speech probability
+// above maxNoSpeech.
+func doubtful(s domain.TranscriptSegment, maxNoSpeech float32) bool {
+    return s.LowConfidence || s.NoSpeechProb > maxNoSpeech
+}
+
+// collapseLoops finds phrases of 1..maxLoopN words repeated at least
+// minLoopRepeats times consecutively and keeps a single occurrence. It returns
+// the new text and the removed repeats ("" when nothing changed). Words are
+// compared normalized, so "Go, go, go" is a loop.
+func collapseLoops(text string) (string, string) {
+    words := strings.Fields(text)
+    if len(words) < minLoopRepeats {
+        return text, ""
+    }
+    norm := make([]string, len(words))
+    for i, w := range words {
+        norm[i] = normalizeText(w)
+    }
+    var out, cut []string
+    for i := 0; i < len(words); {
+        n, reps := longestLoop(norm, i)
+        if reps >= minLoopRepeats {
+            out = append(out, words[i:i+n]...)
+            cut = append(cut, words[i+n:i+n*reps]...)
+            i += n * reps
+            continue
+        }
+        out = append(out, words[i])
+        i++
+    }
+    if len(cut) == 0 {
+        return text, ""
+    }
+    lead := ""
+    if len(text) > 0 && unicode.IsSpace(rune(text[0])) {
+        lead = " " // keep whisper's leading space so segments still join cleanly
+    }
+    return lead + strings.Join(out, " "), strings.Join(cut, " ")
+}
+
+// longestLoop returns the phrase length n and repeat count of the loop that
+// starts at i and covers the most words.
+func longestLoop(words []string, i int) (int, int) {
+    bestN, bestReps := 0, 0
+    for n := 1; n <= maxLoopN && i+2*n <= len(words); n++ {
+        reps := 1
+        for j := i + n; j+n <= len(words) && equalWords(words[i:i+n], words[j:j+n]); j += n {
+            reps++
+        }
+        if reps >= minLoopRepeats && n*reps > bestN*bestReps {
+            bestN, bestReps = n, reps
+        }
+    }
+    return bestN, bestReps
+}
+
+func equalWords(a, b []string) bool {
+    for i := range a {
+        if a[i] == "" || a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+// normalizeText lowercases s and reduces punctuation and whitespace runs to
+// single spaces, so "Thank you." and " thank you!" compare equal.
+func normalizeText(s string) string {
+    var b strings.Builder
+    space := false
+    for _, r := range strings.ToLower(s) {
+        if unicode.IsLetter(r) || unicode.IsNumber(r) {
+            if space && b.Len() > 0 {
+                b.WriteByte(' ')
+            }
+            b.WriteRune(r)
+            space = false
+            continue
+        }
+        space = true
+    }
+    return b.String()
+}
+
+// baseLanguage strips any region suffix ("en-US" -> "en").
+func baseLanguage(lang string) string {
+    if i := strings.IndexAny(lang, "-_"); i > 0 {
+        lang = lang[:i]
+    }
+    return strings.ToLower(lang)
+}
+
+func containsString(list []string, s string) bool {
+    if s == "" {
+        return false
+    }
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+func removed(s domain.TranscriptSegment, text, reason string) domain.RemovedSegment {
+    return domain.RemovedSegment{Index: s.Index, StartMS: s.StartMS, EndMS: s.EndMS, Text: text, Reason: reason}
+}
@@ -0,0 +1,104 @@
+package usecase
+
+import (
+    "testing"
+
+    "gosper/internal/domain"
+)
+
+func seg(i int, start, end int64, text string) domain.TranscriptSegment {
+    return domain.TranscriptSegment{Index: i, StartMS: start, EndMS: end, Text: text}
+}
+
+func TestPostProcess_Filters(t *testing.T) {
+    tr := domain.Transcript{
+        Language: "en",
+        Segments: []domain.TranscriptSegment{
+            seg(0, 0, 1000, " Hello there."),
+            seg(1, 1000, 2000, " hello there!"),
+            seg(2, 2000, 2000, " Empty"),
+            seg(3, 2000, 3000, " and then and then and then and then we left"),
+            {Index: 4, StartMS: 3000, EndMS: 4000, Text: " Thank you.", NoSpeechProb: 0.9},
+        },
+    }
+    got := postProcess(tr, DefaultFilters, 0)
+
+    if got.FullText != " Hello there. and then we left" {
+        t.Fatalf("unexpected FullText: %q", got.FullText)
+    }
+    reasons := map[string]int{}
+    for _, r := range got.Removed { reasons[r.Reason]++ }
+    for _, want := range []string{ReasonDuplicate, ReasonZeroDuration, ReasonNgramLoop, ReasonHallucination} {
+        if reasons[want] != 1 { t.Errorf("expected one %q removal, got %+v", want, got.Removed) }
+    }
+    if len(tr.Segments) != 5 || tr.Segments[3].Text != " and then and then and then and then we left" {
+        t.Fatalf("input transcript was mutated")
+    }
+}
+
+func TestPostProcess_HallucinationsNeedDoubt(t *testing.T) {
+    thanks := seg(0, 0, 1000, " Thank you.")
+    tr := domain.Transcript{Language: "en", FullText: " Thank you.", Segments: []domain.TranscriptSegment{thanks}}
+    if got := postProcess(tr, DefaultFilters, 0); len(got.Segments) != 1 {
+        t.Fatalf("confidently spoken phrase removed: %+v", got)
+    }
+    tr.Segments[0].LowConfidence = true
+    if got := postProcess(tr, DefaultFilters, 0); len(got.Segments) != 0 || got.Removed[0].Reason != ReasonHallucination {
+        t.Fatalf("low-confidence phantom phrase kept: %+v", got)
+    }
+
+    // refine flags before filtering
+    tr.Segments[0] = thanks
+    tr.Segments[0].AvgLogProb = -2
+    if got := refine(tr, domain.ModelConfig{MinAvgLogProb: DefaultMinAvgLogProb}, DefaultFilters); len(got.Segments) != 0 {
+        t.Fatalf("refine kept a low-confidence phantom phrase: %+v", got)
+    }
+
+    // The no-speech threshold is the configured one, not the default
+    tr.Segments[0] = thanks
+    tr.Segments[0].NoSpeechProb = 0.5
+    if got := postProcess(tr, DefaultFilters, 0); len(got.Segments) != 1 {
+        t.Fatalf("phrase below the default no-speech threshold removed: %+v", got)
+    }
+    if got := refine(tr, domain.ModelConfig{MaxNoSpeechProb: 0.4}, DefaultFilters); len(got.Segments) != 0 {
+        t.Fatalf("phrase above a lowered no-speech threshold kept: %+v", got)
+    }
+    tr.Segments[0].NoSpeechProb = 0.7
+    if got := refine(tr, domain.ModelConfig{MaxNoSpeechProb: 0.9}, DefaultFilters); len(got.Segments) != 1 {
+        t.Fatalf("phrase below a raised no-speech threshold removed: %+v", got)
+    }
+}
+
+func TestPostProcess_Toggles(t *testing.T) {
+    tr := domain.Transcript{Language: "en", FullText: " Thank you. Thank you.", Segments: []domain.TranscriptSegment{
+        seg(0, 0, 1000, " Thank you."), seg(1, 1000, 2000, " Thank you."),
+    }}
+    for i := range tr.Segments { tr.Segments[i].LowConfidence = true }
+    if got := postProcess(tr, Filters{}, 0); len(got.Segments) != 2 || len(got.Removed) != 0 {
+        t.Fatalf("zero Filters should be a no-op: %+v", got)
+    }
+    got := postProcess(tr, Filters{Duplicates: true}, 0)
+    if len(got.Segments) != 1 || got.Removed[0].Reason != ReasonDuplicate {
+        t.Fatalf("expected only duplicate removal: %+v", got)
+    }
+    // phrase lists are per language
+    tr.Language = "de"
+    if got := postProcess(tr, Filters{Hallucinations: true}, 0); len(got.Segments) != 2 {
+        t.Fatalf("English phrase removed from German transcript: %+v", got)
+    }
+}
+
+func TestCollapseLoops(t *testing.T) {
+    cases := []struct{ in, want, cut string }{
+        {" la la la la la", " la", "la la la la"},
+        {" I think I think I think I think so", " I think so", "I think I think I think"},
+        {" no no no", " no no no", ""},
+        {" a normal sentence", " a normal sentence", ""},
+    }
+    for _, c := range cases {
+        got, cut := collapseLoops(c.in)
+        if got != c.want || cut != c.cut {
+            t.Errorf("collapseLoops(%q) = %q, %q; want %q, %q", c.in, got, cut, c.want, c.cut)
+        }
+    }
+}
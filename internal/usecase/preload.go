@@ -0,0 +1,77 @@
+package usecase
+
+import (
+    "context"
+    "fmt"
+    "path/filepath"
+    "sync"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+// WarmupSamples is the length of the silent clip run through each preloaded
+// model: one second at 16 kHz, enough to exercise the whole pipeline.
+const WarmupSamples = 16000
+
+// Preload makes models ready to serve before traffic arrives: it fetches
+// each one, keeps it loaded when the transcriber supports that, and
+// optionally runs a short warm-up inference so the first real request does
+// not pay for lazy initialisation.
+type Preload struct {
+    Repo   port.ModelRepo
+    Trans  port.Transcriber
+    Loader port.ModelLoader // optional
+
+    mu   sync.Mutex
+    held map[string]bool // paths held by an earlier Execute
+}
+
+type PreloadInput struct {
+    Models  []string
+    Warmup  bool
+    Threads uint
+}
+
+// Execute prepares each model in turn and stops at the first failure.
+// Loaded models are held in the repository so they are never evicted; each
+// path is held once, however often Execute is retried.
+func (uc *Preload) Execute(ctx context.Context, in PreloadInput) error {
+    for _, name := range in.Models {
+        path, err := uc.Repo.Ensure(ctx, name)
+        if err != nil {
+            return herr.Wrap(herr.ModelError, fmt.Errorf("preload %s: %w", name, err))
+        }
+        if uc.Loader != nil {
+            if err := uc.Loader.Load(ctx, path); err != nil {
+                return herr.Wrap(herr.ModelError, fmt.Errorf("load %s: %w", name, err))
+            }
+            uc.hold(path)
+        }
+        if !in.Warmup {
+            continue
+        }
+        cfg := domain.ModelConfig{
+            ModelName: filepath.Base(path),
+            ModelPath: path,
+            Language:  "en", // a fixed language skips detection on multilingual models
+            Threads:   in.Threads,
+        }
+        if _, err := uc.Trans.Transcribe(ctx, make([]float32, WarmupSamples), cfg); err != nil {
+            return herr.Wrap(herr.TranscriptionError, fmt.Errorf("warm up %s: %w", name, err))
+        }
+    }
+    return nil
+}
+
+// hold keeps path from eviction until the process exits, unless an earlier
+// call already did.
+func (uc *Preload) hold(path string) {
+    uc.mu.Lock()
+    defer uc.mu.Unlock()
+    if uc.held[path] { return }
+    if uc.held == nil { uc.held = map[string]bool{} }
+    holdModel(uc.Repo, path)
+    uc.held[path] = true
+}
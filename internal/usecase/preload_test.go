@@ -0,0 +1,57 @@
+package usecase
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "gosper/internal/domain"
+    herr "gosper/pkg/errors"
+)
+
+type fakeLoader struct{ loaded []string; err error }
+func (l *fakeLoader) Load(ctx context.Context, path string) error { l.loaded = append(l.loaded, path); return l.err }
+func (l *fakeLoader) Loaded() []string { return l.loaded }
+
+type countingTranscriber struct{ calls int; samples int; lang string }
+func (c *countingTranscriber) Transcribe(ctx context.Context, pcm []float32, cfg domain.ModelConfig) (domain.Transcript, error) {
+    c.calls++
+    c.samples = len(pcm)
+    c.lang = cfg.Language
+    return domain.Transcript{}, nil
+}
+
+func TestPreload_LoadsHoldsAndWarmsUp(t *testing.T) {
+    rep := &holdingRepo{fakeRepo: fakeRepo{path: "/models/ggml-base.bin"}, held: map[string]int{}}
+    loader := &fakeLoader{}
+    tr := &countingTranscriber{}
+    uc := &Preload{Repo: rep, Trans: tr, Loader: loader}
+    if err := uc.Execute(context.Background(), PreloadInput{Models: []string{"base"}, Warmup: true}); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(loader.loaded) != 1 || loader.loaded[0] != rep.path { t.Fatalf("loaded = %v", loader.loaded) }
+    if rep.held[rep.path] != 1 { t.Fatalf("loaded model should stay held, held = %d", rep.held[rep.path]) }
+    if tr.calls != 1 || tr.samples != WarmupSamples || tr.lang != "en" { t.Fatalf("warm-up = %+v", tr) }
+
+    // A retry, as after a later model failed, does not pile up holds.
+    if err := uc.Execute(context.Background(), PreloadInput{Models: []string{"base"}}); err != nil { t.Fatal(err) }
+    if rep.held[rep.path] != 1 { t.Fatalf("held = %d after a retry, want 1", rep.held[rep.path]) }
+}
+
+func TestPreload_NoWarmup(t *testing.T) {
+    tr := &countingTranscriber{}
+    uc := &Preload{Repo: &fakeRepo{path: "/m.bin"}, Trans: tr}
+    if err := uc.Execute(context.Background(), PreloadInput{Models: []string{"a", "b"}}); err != nil { t.Fatal(err) }
+    if tr.calls != 0 { t.Fatalf("warm-up ran %d times", tr.calls) }
+}
+
+func TestPreload_Errors(t *testing.T) {
+    uc := &Preload{Repo: &fakeRepo{err: errors.New("offline")}, Trans: &countingTranscriber{}}
+    if err := uc.Execute(context.Background(), PreloadInput{Models: []string{"a"}}); !errors.Is(err, herr.ModelError) {
+        t.Fatalf("err = %v, want ModelError", err)
+    }
+    uc = &Preload{Repo: &fakeRepo{path: "/m.bin"}, Trans: &countingTranscriber{}, Loader: &fakeLoader{err: errors.New("bad file")}}
+    if err := uc.Execute(context.Background(), PreloadInput{Models: []string{"a"}}); !errors.Is(err, herr.ModelError) {
+        t.Fatalf("err = %v, want ModelError", err)
+    }
+}
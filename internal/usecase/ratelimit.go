@@ -0,0 +1,149 @@
+package usecase
+
+import (
+    "fmt"
+    "math"
+    "sync"
+    "time"
+
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+// Limit allows N units per Window, refilled continuously, with bursts of
+// up to N. A zero N means no limit.
+type Limit struct {
+    N      float64
+    Window time.Duration
+}
+
+func (l Limit) enabled() bool { return l.N > 0 && l.Window > 0 }
+
+func (l Limit) perSecond() float64 { return l.N / l.Window.Seconds() }
+
+// RateStatus describes a client's request bucket after a check, for
+// RateLimit-* response headers.
+type RateStatus struct {
+    Limit     int
+    Remaining int
+    Reset     time.Duration // until the bucket is full again
+}
+
+// RateLimiter limits how fast each client sends requests and audio, with
+// a token bucket per client for each. The audio bucket is charged after a
+// request, once the audio's length is known, and may go into debt; the
+// client's next request then waits until it is paid back. State is kept
+// in memory; buckets that have refilled are dropped periodically.
+type RateLimiter struct {
+    Requests Limit      // requests
+    Audio    Limit      // seconds of audio
+    Clock    port.Clock // nil for the system clock
+
+    mu      sync.Mutex
+    clients map[string]*rateBuckets
+    swept   time.Time
+}
+
+type rateBuckets struct {
+    requests, audio float64 // tokens left
+    at              time.Time
+}
+
+// sweepInterval is how often idle buckets are dropped.
+const sweepInterval = time.Minute
+
+// Enabled reports whether any limit is set.
+func (l *RateLimiter) Enabled() bool { return l.Requests.enabled() || l.Audio.enabled() }
+
+// Allow takes a request token from key's bucket, or returns a RateLimited
+// error when the bucket is empty or key's audio is in debt.
+func (l *RateLimiter) Allow(key string) (RateStatus, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := l.now()
+    b := l.bucket(key, now)
+    st := l.status(b)
+
+    var wait time.Duration
+    var which string
+    switch {
+    case l.Requests.enabled() && b.requests < 1:
+        wait, which = secondsFor(1-b.requests, l.Requests), "requests"
+    case l.Audio.enabled() && b.audio <= 0:
+        wait, which = secondsFor(-b.audio, l.Audio), "audio_seconds"
+    }
+    if which != "" {
+        msg := fmt.Sprintf("rate limit of %g requests per %s exceeded", l.Requests.N, l.Requests.Window)
+        if which == "audio_seconds" { msg = fmt.Sprintf("rate limit of %gs of audio per %s exceeded", l.Audio.N, l.Audio.Window) }
+        return st, herr.New(herr.RateLimited, msg).
+            WithDetail("limit", which).
+            WithDetail("retry_after", int(math.Max(1, math.Ceil(wait.Seconds()))))
+    }
+    if l.Requests.enabled() {
+        b.requests--
+        st = l.status(b)
+    }
+    return st, nil
+}
+
+// Charge takes audio seconds from key's audio bucket.
+func (l *RateLimiter) Charge(key string, audio time.Duration) {
+    if !l.Audio.enabled() { return }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.bucket(key, l.now()).audio -= audio.Seconds()
+}
+
+// bucket returns key's buckets refilled up to now. l.mu must be held.
+func (l *RateLimiter) bucket(key string, now time.Time) *rateBuckets {
+    if l.clients == nil { l.clients = map[string]*rateBuckets{} }
+    if now.Sub(l.swept) >= sweepInterval {
+        l.sweep(now)
+        l.swept = now
+    }
+    b := l.clients[key]
+    if b == nil {
+        b = &rateBuckets{requests: l.Requests.N, audio: l.Audio.N, at: now}
+        l.clients[key] = b
+        return b
+    }
+    l.refill(b, now)
+    return b
+}
+
+func (l *RateLimiter) refill(b *rateBuckets, now time.Time) {
+    elapsed := now.Sub(b.at).Seconds()
+    if elapsed <= 0 { return }
+    if l.Requests.enabled() { b.requests = math.Min(l.Requests.N, b.requests+elapsed*l.Requests.perSecond()) }
+    if l.Audio.enabled() { b.audio = math.Min(l.Audio.N, b.audio+elapsed*l.Audio.perSecond()) }
+    b.at = now
+}
+
+// sweep drops buckets that have refilled, which behave the same as new
+// ones. l.mu must be held.
+func (l *RateLimiter) sweep(now time.Time) {
+    for key, b := range l.clients {
+        l.refill(b, now)
+        if b.requests >= l.Requests.N && b.audio >= l.Audio.N { delete(l.clients, key) }
+    }
+}
+
+func (l *RateLimiter) status(b *rateBuckets) RateStatus {
+    if !l.Requests.enabled() { return RateStatus{} }
+    return RateStatus{
+        Limit:     int(l.Requests.N),
+        Remaining: int(math.Max(0, math.Floor(b.requests))),
+        Reset:     secondsFor(l.Requests.N-b.requests, l.Requests),
+    }
+}
+
+// secondsFor returns how long lim takes to refill n units.
+func secondsFor(n float64, lim Limit) time.Duration {
+    if n <= 0 { return 0 }
+    return time.Duration(n / lim.perSecond() * float64(time.Second))
+}
+
+func (l *RateLimiter) now() time.Time {
+    if l.Clock != nil { return l.Clock.Now() }
+    return time.Now()
+}
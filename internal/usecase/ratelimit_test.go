@@ -0,0 +1,49 @@
+package usecase
+
+import (
+    "testing"
+    "time"
+
+    herr "gosper/pkg/errors"
+)
+
+func TestRateLimiter_Requests(t *testing.T) {
+    clk := &fakeClock{t: time.Unix(0, 0)}
+    l := &RateLimiter{Requests: Limit{N: 3, Window: time.Minute}, Clock: clk}
+
+    for i := 0; i < 3; i++ {
+        st, err := l.Allow("a")
+        if err != nil || st.Limit != 3 || st.Remaining != 2-i { t.Fatalf("request %d: %+v, %v", i, st, err) }
+    }
+    st, err := l.Allow("a")
+    e := herr.From(err)
+    if e == nil || e.Code != herr.RateLimited || e.Details["retry_after"] != 20 || st.Remaining != 0 || st.Reset != time.Minute {
+        t.Fatalf("fourth request: %+v, %+v", st, e)
+    }
+    if _, err := l.Allow("b"); err != nil { t.Fatalf("other client limited: %v", err) }
+
+    clk.t = clk.t.Add(20 * time.Second) // one token back
+    if _, err := l.Allow("a"); err != nil { t.Fatalf("after refill: %v", err) }
+    if _, err := l.Allow("a"); err == nil { t.Fatal("only one token should have refilled") }
+}
+
+func TestRateLimiter_AudioDebtAndSweep(t *testing.T) {
+    clk := &fakeClock{t: time.Unix(0, 0)}
+    l := &RateLimiter{Audio: Limit{N: 60, Window: time.Minute}, Clock: clk}
+
+    if _, err := l.Allow("a"); err != nil { t.Fatal(err) }
+    l.Charge("a", 90*time.Second) // 30s in debt
+    e := herr.From(func() error { _, err := l.Allow("a"); return err }())
+    if e == nil || e.Details["limit"] != "audio_seconds" || e.Details["retry_after"] != 30 { t.Fatalf("audio in debt: %+v", e) }
+
+    clk.t = clk.t.Add(31 * time.Second)
+    if _, err := l.Allow("a"); err != nil { t.Fatalf("after paying back: %v", err) }
+
+    // Once refilled, buckets are dropped on the next sweep.
+    clk.t = clk.t.Add(2 * time.Minute)
+    _, _ = l.Allow("b")
+    l.mu.Lock()
+    _, kept := l.clients["a"]
+    l.mu.Unlock()
+    if kept { t.Fatal("idle client not swept") }
+}
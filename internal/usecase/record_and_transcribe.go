@@ -2,6 +2,7 @@ package usecase
 
 import (
     "context"
+    "path/filepath"
     "time"
 
     "gosper/internal/adapter/outbound/audio/resample"
@@ -19,6 +20,13 @@ type RecordInput struct {
     Threads    uint
     Timestamps bool
     OutPath    string
+    Filters    Filters
+
+    // Confidence thresholds (zero disables); see domain.ModelConfig.
+    MinAvgLogProb       float32
+    MaxNoSpeechProb     float32
+    MaxCompressionRatio float32
+    DropLowConfidence   bool
 }
 
 type RecordAndTranscribe struct {
@@ -26,7 +34,8 @@ type RecordAndTranscribe struct {
     Repo   port.ModelRepo
     Trans  port.Transcriber
     Store  port.Storage
-    Logger port.Logger
+    Logger port.Logger // stage timings; nil for none
+    Clock  port.Clock  // nil for the system clock
 }
 
 func (uc *RecordAndTranscribe) Execute(ctx context.Context, in RecordInput) (domain.Transcript, error) {
@@ -58,16 +67,27 @@ func (uc *RecordAndTranscribe) Execute(ctx context.Context, in RecordInput) (dom
     }
     if err := stream.Err(); err != nil { return domain.Transcript{}, herr.Wrap(herr.AudioError, err) }
 
+    ev := events{uc.Logger, uc.Clock}
+    ev.debug(ctx, "audio recorded", "device", in.DeviceID, "sample_rate", fmt.SampleRate, "audio_ms", samplesDuration(len(buf)).Milliseconds())
+
     // buf is 16k mono already per contract; but resample anyway for safety
+    start := ev.now()
     pcm16k := resample.Linear(buf, fmt.SampleRate, 16000)
+    ev.resampled(ctx, fmt.SampleRate, len(buf), len(pcm16k), ev.since(start))
 
     // Resolve model
-    modelPath, err := uc.Repo.Ensure(ctx, in.ModelName)
+    modelPath, err := ensureModel(ctx, ev, uc.Repo, in.ModelName)
     if err != nil { return domain.Transcript{}, herr.Wrap(herr.ModelError, err) }
+    defer holdModel(uc.Repo, modelPath)()
 
-    cfg := domain.ModelConfig{ModelPath: modelPath, Language: in.Language, Translate: in.Translate, Threads: in.Threads, Timestamps: in.Timestamps}
+    cfg := domain.ModelConfig{ModelPath: modelPath, ModelName: filepath.Base(modelPath), Language: in.Language, Translate: in.Translate, Threads: in.Threads, Timestamps: in.Timestamps,
+        MinAvgLogProb: in.MinAvgLogProb, MaxNoSpeechProb: in.MaxNoSpeechProb, MaxCompressionRatio: in.MaxCompressionRatio, DropLowConfidence: in.DropLowConfidence}
+    start = ev.now()
     tr, err := uc.Trans.Transcribe(ctx, pcm16k, cfg)
     if err != nil { return domain.Transcript{}, herr.Wrap(herr.TranscriptionError, err) }
+    ev.inferred(ctx, "transcribed", cfg.ModelName, samplesDuration(len(pcm16k)), ev.since(start), "language", tr.Language, "segments", len(tr.Segments))
+    tr = refine(tr, cfg, in.Filters)
+    tr.Duration = samplesDuration(len(pcm16k))
     if in.OutPath != "" {
         if err := uc.Store.WriteTranscript(ctx, in.OutPath, tr); err != nil { return tr, herr.Wrap(herr.FsError, err) }
     }
@@ -25,10 +25,11 @@ func (a *fakeAudio) Open(ctx context.Context, deviceID string, fmt domain.AudioF
 type fakeRepo2 struct{ path string; err error }
 func (f *fakeRepo2) Ensure(ctx context.Context, name string) (string, error) { return f.path, f.err }
 
-type fakeTranscriber2 struct{ called bool; err error }
+type fakeTranscriber2 struct{ called bool; err error; tr domain.Transcript }
 func (t *fakeTranscriber2) Transcribe(ctx context.Context, pcm []float32, cfg domain.ModelConfig) (domain.Transcript, error) {
     t.called = true
     if t.err != nil { return domain.Transcript{}, t.err }
+    if t.tr.Segments != nil { return t.tr, nil }
     return domain.Transcript{FullText: "ok"}, nil
 }
 
@@ -73,3 +74,18 @@ func TestRecordAndTranscribe_PropagatesErrors(t *testing.T) {
     uc2 := &RecordAndTranscribe{ Audio: audio, Repo: repo, Trans: tr, Store: &fakeStorage2{} }
     if _, err := uc2.Execute(context.Background(), RecordInput{ Duration: 1 * time.Millisecond, ModelName: "/m" }); err == nil { t.Fatal("expected error") }
 }
+
+func TestRecordAndTranscribe_Confidence(t *testing.T) {
+    frames := make(chan []float32, 1)
+    frames <- make([]float32, 1600)
+    tr := &fakeTranscriber2{ tr: domain.Transcript{Language: "en", FullText: " Hi. Thank you.", Segments: []domain.TranscriptSegment{
+        {Index: 0, EndMS: 500, Text: " Hi.", AvgLogProb: -0.1},
+        {Index: 1, StartMS: 500, EndMS: 1000, Text: " Thank you.", AvgLogProb: -2},
+    }} }
+    uc := &RecordAndTranscribe{ Audio: &fakeAudio{ stream: &fakeStream{ ch: frames } }, Repo: &fakeRepo2{ path: "/m" }, Trans: tr, Store: &fakeStorage2{} }
+    got, err := uc.Execute(context.Background(), RecordInput{ Duration: time.Millisecond, ModelName: "/m", MinAvgLogProb: DefaultMinAvgLogProb, Filters: DefaultFilters })
+    if err != nil { t.Fatal(err) }
+    if got.FullText != " Hi." || len(got.Removed) != 1 || got.Removed[0].Reason != ReasonHallucination {
+        t.Fatalf("expected the low-confidence phrase to be dropped: %+v", got)
+    }
+}
@@ -2,14 +2,18 @@ package usecase
 
 import (
     "context"
+    "errors"
     "fmt"
     "path/filepath"
+    "strings"
+    "time"
 
     "gosper/internal/adapter/outbound/audio/decoder"
     "gosper/internal/adapter/outbound/audio/resample"
     "gosper/internal/domain"
     "gosper/internal/port"
     herr "gosper/pkg/errors"
+    "gosper/pkg/trace"
 )
 
 // DecoderFactory allows tests to inject fake decoders.
@@ -19,7 +23,8 @@ type TranscribeFile struct {
     Repo    port.ModelRepo
     Trans   port.Transcriber
     Store   port.Storage
-    Log     port.Logger
+    Log     port.Logger // stage timings; nil for none
+    Clock   port.Clock  // nil for the system clock
     Factory DecoderFactory
 }
 
@@ -34,50 +39,92 @@ type TranscribeInput struct {
     BeamSize      int
     MaxTokens     uint
     InitialPrompt string
+
+    // Decoding parameters (zero keeps whisper's defaults); see domain.ModelConfig.
+    Temperature       float32
+    TemperatureInc    float32
+    BestOf            int
+    EntropyThreshold  float32
+    LogProbThreshold  float32
+    NoSpeechThreshold float32
+    AllowBlank        bool
+    SuppressRegex     string
+    MaxSegmentLength  uint
+    SplitOnWord       bool
+
+    // Confidence thresholds (zero disables); see domain.ModelConfig.
+    MinAvgLogProb       float32
+    MaxNoSpeechProb     float32
+    MaxCompressionRatio float32
+    DropLowConfidence   bool
+
+    Filters Filters // hallucination/repetition post-processing
+
+    MaxDuration time.Duration // longer audio is refused; 0 for no limit
 }
 
-func (uc *TranscribeFile) Execute(ctx context.Context, in TranscribeInput) (domain.Transcript, error) {
+func (uc *TranscribeFile) Execute(ctx context.Context, in TranscribeInput) (tr domain.Transcript, err error) {
+    ctx, span := trace.Start(ctx, "TranscribeFile.Execute", trace.String("model.name", in.ModelName), trace.String("language", orDefault(in.Language, "auto")))
+    defer func() {
+        if err == nil { span.SetAttrs(trace.Seconds("audio.duration", tr.Duration), trace.String("language.detected", tr.Language)) }
+        span.Fail(err)
+        span.End()
+    }()
     if in.Path == "" {
         return domain.Transcript{}, herr.Wrap(herr.InvalidArgs, fmt.Errorf("missing input file path"))
     }
-    if uc.Factory == nil {
-        uc.Factory = decoder.New
+    cfg := domain.ModelConfig{
+        Language:      orDefault(in.Language, "auto"),
+        Translate:     in.Translate,
+        Threads:       in.Threads,
+        Timestamps:    in.Timestamps,
+        BeamSize:      in.BeamSize,
+        MaxTokens:     in.MaxTokens,
+        InitialPrompt: in.InitialPrompt,
+
+        Temperature:       in.Temperature,
+        TemperatureInc:    in.TemperatureInc,
+        BestOf:            in.BestOf,
+        EntropyThreshold:  in.EntropyThreshold,
+        LogProbThreshold:  in.LogProbThreshold,
+        NoSpeechThreshold: in.NoSpeechThreshold,
+        AllowBlank:        in.AllowBlank,
+        SuppressRegex:     in.SuppressRegex,
+        MaxSegmentLength:  in.MaxSegmentLength,
+        SplitOnWord:       in.SplitOnWord,
+
+        MinAvgLogProb:       in.MinAvgLogProb,
+        MaxNoSpeechProb:     in.MaxNoSpeechProb,
+        MaxCompressionRatio: in.MaxCompressionRatio,
+        DropLowConfidence:   in.DropLowConfidence,
     }
-    dec, err := uc.Factory(in.Path)
-    if err != nil {
-        return domain.Transcript{}, herr.Wrap(herr.AudioError, err)
+    if err := validateDecoding(cfg); err != nil {
+        return domain.Transcript{}, herr.Wrap(herr.InvalidArgs, err)
     }
-    defer dec.Close()
 
-    pcm, err := dec.DecodeAll()
+    ev := events{uc.Log, uc.Clock}
+    pcm16k, err := decode16k(ctx, ev, uc.Factory, in.Path, in.MaxDuration)
     if err != nil {
-        return domain.Transcript{}, herr.Wrap(herr.AudioError, err)
+        return domain.Transcript{}, audioError(err)
     }
-    // resample to 16k mono
-    pcm16k := resample.Linear(pcm, dec.Info().SampleRate, 16000)
 
     // model resolution
-    modelPath, err := uc.Repo.Ensure(ctx, in.ModelName)
+    modelPath, err := ensureModel(ctx, ev, uc.Repo, in.ModelName)
     if err != nil {
         return domain.Transcript{}, herr.Wrap(herr.ModelError, err)
     }
+    defer holdModel(uc.Repo, modelPath)()
+    cfg.ModelName = filepath.Base(modelPath)
+    cfg.ModelPath = modelPath
 
-    cfg := domain.ModelConfig{
-        ModelName:     filepath.Base(modelPath),
-        ModelPath:     modelPath,
-        Language:      orDefault(in.Language, "auto"),
-        Translate:     in.Translate,
-        Threads:       in.Threads,
-        Timestamps:    in.Timestamps,
-        BeamSize:      in.BeamSize,
-        MaxTokens:     in.MaxTokens,
-        InitialPrompt: in.InitialPrompt,
-    }
-
-    tr, err := uc.Transcribe(ctx, pcm16k, cfg)
+    start := ev.now()
+    tr, err = uc.Transcribe(ctx, pcm16k, cfg)
     if err != nil {
         return domain.Transcript{}, herr.Wrap(herr.TranscriptionError, err)
     }
+    ev.inferred(ctx, "transcribed", cfg.ModelName, samplesDuration(len(pcm16k)), ev.since(start), "language", tr.Language, "segments", len(tr.Segments))
+    tr = refine(tr, cfg, in.Filters)
+    tr.Duration = samplesDuration(len(pcm16k))
 
     if in.OutPath != "" {
         if err := uc.Store.WriteTranscript(ctx, in.OutPath, tr); err != nil {
@@ -91,6 +138,97 @@ func (uc *TranscribeFile) Transcribe(ctx context.Context, pcm16k []float32, cfg
     return uc.Trans.Transcribe(ctx, pcm16k, cfg)
 }
 
+// decode16k decodes the file at path and resamples it to 16 kHz mono,
+// refusing audio longer than max unless max is 0. The length is checked
+// from the header when it has one, before anything is decoded.
+func decode16k(ctx context.Context, ev events, factory DecoderFactory, path string, max time.Duration) ([]float32, error) {
+    if factory == nil {
+        factory = decoder.New
+    }
+    format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+    _, span := trace.Start(ctx, "audio.decode", trace.String("audio.format", format))
+    defer span.End()
+    start := ev.now()
+    dec, err := factory(path)
+    if err != nil {
+        span.Fail(err)
+        return nil, err
+    }
+    defer dec.Close()
+    if info := dec.Info(); info.Frames > 0 {
+        if err := checkDuration(info.Frames, info.SampleRate, max); err != nil {
+            span.Fail(err)
+            return nil, err
+        }
+    }
+
+    pcm, err := dec.DecodeAll()
+    if err != nil {
+        span.Fail(err)
+        return nil, err
+    }
+    info := dec.Info()
+    if err := checkDuration(int64(len(pcm)), info.SampleRate, max); err != nil {
+        span.Fail(err)
+        return nil, err
+    }
+    span.SetAttrs(trace.Int("audio.sample_rate", int64(info.SampleRate)), trace.Int("audio.samples", int64(len(pcm))))
+    span.End()
+    ev.decoded(ctx, format, info.SampleRate, info.Channels, len(pcm), ev.since(start))
+
+    _, span = trace.Start(ctx, "audio.resample", trace.Int("audio.sample_rate", int64(info.SampleRate)), trace.Int("audio.target_rate", 16000))
+    defer span.End()
+    start = ev.now()
+    pcm16k := resample.Linear(pcm, info.SampleRate, 16000)
+    ev.resampled(ctx, info.SampleRate, len(pcm), len(pcm16k), ev.since(start))
+    return pcm16k, nil
+}
+
+// checkDuration refuses frames of audio at rate longer than max, unless max
+// is 0.
+func checkDuration(frames int64, rate int, max time.Duration) error {
+    if max <= 0 || rate <= 0 { return nil }
+    if d := time.Duration(frames) * time.Second / time.Duration(rate); d > max {
+        return herr.Newf(herr.PayloadTooLarge, "audio is %s long; the limit is %s", d.Round(time.Second), max).
+            WithDetail("duration_ms", d.Milliseconds()).WithDetail("limit_ms", max.Milliseconds())
+    }
+    return nil
+}
+
+// samplesDuration is the length of n samples of 16 kHz audio.
+func samplesDuration(n int) time.Duration { return time.Duration(n) * time.Second / 16000 }
+
+// audioError classifies a decoding failure: unsupported formats apart from
+// audio that could not be decoded.
+func audioError(err error) error {
+    if errors.Is(err, decoder.ErrUnsupportedFormat) {
+        return herr.Wrap(herr.AudioError, herr.Newf(herr.UnsupportedFormat, "%w", err))
+    }
+    return herr.Wrap(herr.AudioError, err)
+}
+
+// ensureModel resolves the named model, downloading it if need be.
+func ensureModel(ctx context.Context, ev events, repo port.ModelRepo, name string) (string, error) {
+    ctx, span := trace.Start(ctx, "model.ensure", trace.String("model.name", name))
+    defer span.End()
+    start := ev.now()
+    path, err := repo.Ensure(ctx, name)
+    if err != nil {
+        span.Fail(err)
+        return "", err
+    }
+    span.SetAttrs(trace.String("model.file", filepath.Base(path)))
+    ev.resolved(ctx, name, path, ev.since(start))
+    return path, nil
+}
+
+// holdModel keeps the model from being evicted from the cache while it is
+// in use, for repositories that evict; the returned func releases it.
+func holdModel(repo port.ModelRepo, path string) func() {
+    if h, ok := repo.(port.ModelHolder); ok { return h.Hold(path) }
+    return func() {}
+}
+
 func orDefault[T comparable](v, def T) T {
     var zero T
     if v == zero {
@@ -6,9 +6,11 @@ import (
     "io"
     "os"
     "testing"
+    "time"
 
     "gosper/internal/adapter/outbound/audio/decoder"
     "gosper/internal/domain"
+    herr "gosper/pkg/errors"
 )
 
 // Fake implementations
@@ -150,6 +152,64 @@ func TestTranscribeFile_MP3_InvalidFile(t *testing.T) {
     if err == nil {
         t.Error("expected error for invalid MP3")
     }
+    if code := herr.From(err).Code; code != herr.InvalidAudio {
+        t.Errorf("code = %s, want %s", code, herr.InvalidAudio)
+    }
 
     t.Logf("Got expected error: %v", err)
 }
+
+func TestTranscribeFile_UnsupportedFormat(t *testing.T) {
+    uc := &TranscribeFile{Repo: &fakeRepo{path: "/m"}, Trans: &fakeTranscriber{}}
+    _, err := uc.Execute(context.Background(), TranscribeInput{Path: "clip.flac"})
+    if !errors.Is(err, herr.AudioError) || !errors.Is(err, decoder.ErrUnsupportedFormat) {
+        t.Fatalf("err = %v, want an unsupported format AudioError", err)
+    }
+    if e := herr.From(err); e.Code != herr.UnsupportedFormat || e.HTTPStatus() != 415 {
+        t.Fatalf("classified as %s (%d)", e.Code, e.HTTPStatus())
+    }
+}
+
+func TestTranscribeFile_MaxDuration(t *testing.T) {
+    dec := &fakeDecoder{sr: 8000, ch: 1, pcm: make([]float32, 3*8000)} // 3 s
+    tr := &fakeTranscriber{}
+    uc := &TranscribeFile{Repo: &fakeRepo{path: "/m"}, Trans: tr, Factory: func(string) (decoder.Decoder, error) { return dec, nil }}
+
+    _, err := uc.Execute(context.Background(), TranscribeInput{Path: "a.wav", MaxDuration: 2 * time.Second})
+    if e := herr.From(err); e == nil || e.Code != herr.PayloadTooLarge || e.HTTPStatus() != 413 || e.Details["duration_ms"] != int64(3000) {
+        t.Fatalf("err = %#v, want payload_too_large", e)
+    }
+    if tr.gotPCM != 0 { t.Fatal("transcribed audio over the limit") }
+
+    if _, err := uc.Execute(context.Background(), TranscribeInput{Path: "a.wav", MaxDuration: 3 * time.Second}); err != nil {
+        t.Fatalf("audio at the limit: %v", err)
+    }
+}
+
+func TestTranscribeFile_RejectsInvalidDecodingParams(t *testing.T) {
+    decoded := false
+    uc := &TranscribeFile{Factory: func(string)(decoder.Decoder,error){ decoded = true; return nil, errors.New("unreachable") }}
+    _, err := uc.Execute(context.Background(), TranscribeInput{Path: "x.wav", Temperature: 3})
+    if !errors.Is(err, herr.InvalidArgs) { t.Fatalf("expected InvalidArgs, got %v", err) }
+    if decoded { t.Fatal("audio decoded before parameters were validated") }
+}
+
+type holdingRepo struct{ fakeRepo; held map[string]int }
+func (h *holdingRepo) Hold(path string) func() { h.held[path]++; return func() { h.held[path]-- } }
+
+type checkHeld struct{ fakeTranscriber; repo *holdingRepo; during int }
+func (c *checkHeld) Transcribe(ctx context.Context, pcm []float32, cfg domain.ModelConfig) (domain.Transcript, error) {
+    c.during = c.repo.held[cfg.ModelPath]
+    return c.fakeTranscriber.Transcribe(ctx, pcm, cfg)
+}
+
+func TestTranscribeFile_HoldsModelWhileTranscribing(t *testing.T) {
+    rep := &holdingRepo{fakeRepo: fakeRepo{path: "/models/ggml-tiny.en.bin"}, held: map[string]int{}}
+    tr := &checkHeld{repo: rep}
+    uc := &TranscribeFile{
+        Repo: rep, Trans: tr, Factory: func(string)(decoder.Decoder,error){ return &fakeDecoder{sr: 16000, ch: 1, pcm: make([]float32, 160)}, nil },
+    }
+    if _, err := uc.Execute(context.Background(), TranscribeInput{Path: "dummy.wav"}); err != nil { t.Fatalf("unexpected error: %v", err) }
+    if tr.during != 1 { t.Fatalf("model held %d times during transcription, want 1", tr.during) }
+    if rep.held[rep.path] != 0 { t.Fatalf("model still held after Execute") }
+}
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+    "fmt"
+    "sync"
+    "time"
+
+    "gosper/internal/domain"
+    "gosper/internal/port"
+    herr "gosper/pkg/errors"
+)
+
+// Usage counts the requests and audio each principal uses and enforces
+// their quotas over fixed windows. Counters are kept in memory, so they
+// start again when the process restarts.
+type Usage struct {
+    Window time.Duration // quota window; 0 means usage never resets
+    Clock  port.Clock    // nil for the system clock
+
+    mu   sync.Mutex
+    used map[string]*domain.Usage
+}
+
+// Begin counts a request by p, or returns a RateLimited error when p has
+// used up its request or audio quota for the window.
+func (uc *Usage) Begin(p domain.Principal) error {
+    uc.mu.Lock()
+    defer uc.mu.Unlock()
+    u := uc.current(p.ID)
+    q := p.Quota
+    switch {
+    case q.Requests > 0 && u.Requests >= q.Requests:
+        return uc.exhausted(u, "requests", fmt.Sprintf("request quota of %d used up", q.Requests), q.Requests)
+    case q.AudioSeconds > 0 && u.AudioSeconds >= q.AudioSeconds:
+        return uc.exhausted(u, "audio_seconds", fmt.Sprintf("audio quota of %gs used up", q.AudioSeconds), q.AudioSeconds)
+    }
+    u.Requests++
+    return nil
+}
+
+// Record adds audio processed for p. A request may take p past its audio
+// quota; the next one is then refused.
+func (uc *Usage) Record(p domain.Principal, audio time.Duration) {
+    uc.mu.Lock()
+    defer uc.mu.Unlock()
+    uc.current(p.ID).AudioSeconds += audio.Seconds()
+}
+
+// Get returns what the principal with id has used in the current window.
+func (uc *Usage) Get(id string) domain.Usage {
+    uc.mu.Lock()
+    defer uc.mu.Unlock()
+    return *uc.current(id)
+}
+
+// current returns the counters for id, starting a new window when the
+// previous one has ended. uc.mu must be held.
+func (uc *Usage) current(id string) *domain.Usage {
+    now := uc.now()
+    if uc.used == nil { uc.used = map[string]*domain.Usage{} }
+    u := uc.used[id]
+    if u == nil || (!u.Resets.IsZero() && !now.Before(u.Resets)) {
+        u = &domain.Usage{Since: now}
+        if uc.Window > 0 { u.Resets = now.Add(uc.Window) }
+        uc.used[id] = u
+    }
+    return u
+}
+
+func (uc *Usage) exhausted(u *domain.Usage, quota, msg string, limit any) error {
+    e := herr.New(herr.RateLimited, msg).WithDetail("quota", quota).WithDetail("limit", limit)
+    if !u.Resets.IsZero() {
+        e.WithDetail("retry_after", int(u.Resets.Sub(uc.now()).Seconds()+0.5))
+    } else {
+        e.Retryable = false
+    }
+    return e
+}
+
+func (uc *Usage) now() time.Time {
+    if uc.Clock != nil { return uc.Clock.Now() }
+    return time.Now()
+}
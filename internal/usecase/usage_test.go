@@ -0,0 +1,49 @@
+package usecase
+
+import (
+    "testing"
+    "time"
+
+    "gosper/internal/domain"
+    herr "gosper/pkg/errors"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func TestUsage_RequestQuotaResetsWithWindow(t *testing.T) {
+    clk := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+    uc := &Usage{Window: time.Hour, Clock: clk}
+    p := domain.Principal{ID: "ci", Quota: domain.Quota{Requests: 2}}
+
+    for i := 0; i < 2; i++ {
+        if err := uc.Begin(p); err != nil { t.Fatalf("request %d refused: %v", i, err) }
+    }
+    err := uc.Begin(p)
+    e := herr.From(err)
+    if e == nil || e.Code != herr.RateLimited || e.Details["retry_after"] != 3600 {
+        t.Fatalf("third request: %+v", e)
+    }
+    if got := uc.Get("ci"); got.Requests != 2 { t.Fatalf("requests = %d, want 2", got.Requests) }
+
+    clk.t = clk.t.Add(time.Hour)
+    if err := uc.Begin(p); err != nil { t.Fatalf("request after the window refused: %v", err) }
+    if got := uc.Get("ci"); got.Requests != 1 || !got.Since.Equal(clk.t) { t.Fatalf("window not reset: %+v", got) }
+}
+
+func TestUsage_AudioQuota(t *testing.T) {
+    uc := &Usage{Clock: &fakeClock{t: time.Unix(0, 0)}}
+    p := domain.Principal{ID: "k", Quota: domain.Quota{AudioSeconds: 60}}
+    other := domain.Principal{ID: "other"}
+
+    if err := uc.Begin(p); err != nil { t.Fatal(err) }
+    uc.Record(p, 90*time.Second) // one request may overshoot
+    uc.Record(other, time.Hour)
+
+    if e := herr.From(uc.Begin(p)); e == nil || e.Code != herr.RateLimited || e.Retryable {
+        t.Fatalf("expected a non-retryable RateLimited error without a window, got %+v", e)
+    }
+    if err := uc.Begin(other); err != nil { t.Fatalf("unlimited principal refused: %v", err) }
+    if got := uc.Get("k").AudioSeconds; got != 90 { t.Fatalf("audio seconds = %v", got) }
+}
@@ -0,0 +1,91 @@
+package errors
+
+import "net/http"
+
+// Code is a stable, machine-readable error classification shared by the
+// HTTP API, gRPC and the CLI.
+type Code string
+
+const (
+    InvalidArgument     Code = "invalid_argument"
+    UnsupportedFormat   Code = "unsupported_format"
+    InvalidAudio        Code = "invalid_audio"
+    PayloadTooLarge     Code = "payload_too_large"
+    ModelNotFound       Code = "model_not_found"
+    ModelNotAllowed     Code = "model_not_allowed"
+    ModelUnavailable    Code = "model_unavailable"
+    TranscriptionFailed Code = "transcription_failed"
+    StorageError        Code = "storage_error"
+    Unauthenticated     Code = "unauthenticated"
+    PermissionDenied    Code = "permission_denied"
+    RateLimited         Code = "rate_limited"
+    NotFound            Code = "not_found"
+    MethodNotAllowed    Code = "method_not_allowed"
+    Unavailable         Code = "unavailable"
+    Canceled            Code = "canceled"
+    DeadlineExceeded    Code = "deadline_exceeded"
+    Internal            Code = "internal"
+)
+
+// GRPCCode is a gRPC status code; the values are those of
+// google.golang.org/grpc/codes, so codes.Code(c) converts it.
+type GRPCCode uint32
+
+const (
+    GRPCCanceled           GRPCCode = 1
+    GRPCInvalidArgument    GRPCCode = 3
+    GRPCDeadlineExceeded   GRPCCode = 4
+    GRPCNotFound           GRPCCode = 5
+    GRPCPermissionDenied   GRPCCode = 7
+    GRPCResourceExhausted  GRPCCode = 8
+    GRPCUnimplemented      GRPCCode = 12
+    GRPCInternal           GRPCCode = 13
+    GRPCUnavailable        GRPCCode = 14
+    GRPCUnauthenticated    GRPCCode = 16
+)
+
+// statusClientClosedRequest is the de facto status for requests the client
+// gave up on; nobody receives it, but it keeps them out of 5xx counts.
+const statusClientClosedRequest = 499
+
+const genericMessage = "the server encountered a problem and could not process your request"
+
+type codeInfo struct {
+    class     error // sentinel matched by errors.Is
+    http      int
+    grpc      GRPCCode
+    exit      int
+    retryable bool
+    public    string // replaces the message for remote clients, if set
+}
+
+// Exit codes: 1 general failure, 2 invalid usage or input, 3 bad audio,
+// 4 unknown model, 5 model or service unavailable, 6 transcription failed,
+// 7 storage failure, 8 not authorised, 9 rate limited, 124 timed out and
+// 130 interrupted.
+var codes = map[Code]codeInfo{
+    InvalidArgument:     {InvalidArgs, http.StatusBadRequest, GRPCInvalidArgument, 2, false, ""},
+    UnsupportedFormat:   {AudioError, http.StatusUnsupportedMediaType, GRPCInvalidArgument, 3, false, ""},
+    InvalidAudio:        {AudioError, http.StatusUnprocessableEntity, GRPCInvalidArgument, 3, false, ""},
+    PayloadTooLarge:     {InvalidArgs, http.StatusRequestEntityTooLarge, GRPCResourceExhausted, 2, false, ""},
+    ModelNotFound:       {InvalidArgs, http.StatusBadRequest, GRPCNotFound, 4, false, ""},
+    ModelNotAllowed:     {InvalidArgs, http.StatusBadRequest, GRPCPermissionDenied, 4, false, ""},
+    ModelUnavailable:    {ModelError, http.StatusServiceUnavailable, GRPCUnavailable, 5, true, "the requested model is not available"},
+    TranscriptionFailed: {TranscriptionError, http.StatusInternalServerError, GRPCInternal, 6, false, genericMessage},
+    StorageError:        {FsError, http.StatusInternalServerError, GRPCInternal, 7, false, genericMessage},
+    Unauthenticated:     {nil, http.StatusUnauthorized, GRPCUnauthenticated, 8, false, ""},
+    PermissionDenied:    {nil, http.StatusForbidden, GRPCPermissionDenied, 8, false, ""},
+    RateLimited:         {nil, http.StatusTooManyRequests, GRPCResourceExhausted, 9, true, ""},
+    NotFound:            {nil, http.StatusNotFound, GRPCNotFound, 1, false, ""},
+    MethodNotAllowed:    {nil, http.StatusMethodNotAllowed, GRPCUnimplemented, 1, false, ""},
+    Unavailable:         {nil, http.StatusServiceUnavailable, GRPCUnavailable, 5, true, ""},
+    Canceled:            {nil, statusClientClosedRequest, GRPCCanceled, 130, false, ""},
+    DeadlineExceeded:    {nil, http.StatusGatewayTimeout, GRPCDeadlineExceeded, 124, true, ""},
+    Internal:            {nil, http.StatusInternalServerError, GRPCInternal, 1, false, genericMessage},
+}
+
+// info returns the mapping for c; unknown codes are treated as Internal.
+func (c Code) info() codeInfo {
+    if i, ok := codes[c]; ok { return i }
+    return codes[Internal]
+}
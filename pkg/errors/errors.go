@@ -1,6 +1,10 @@
 package errors
 
-import "errors"
+import (
+    "context"
+    "errors"
+    "fmt"
+)
 
 var (
     InvalidArgs        = errors.New("invalid arguments")
@@ -10,11 +14,111 @@ var (
     FsError            = errors.New("filesystem error")
 )
 
-// Wrap labels an error with a sentinel cause for errors.Is checks.
+// Error is a classified error: a stable Code clients can branch on, a
+// human-readable message, optional details and whether retrying the same
+// request may succeed. It matches the sentinel of its code's class with
+// errors.Is, and any sentinel it was wrapped with.
+type Error struct {
+    Code      Code
+    Message   string
+    Details   map[string]any
+    Retryable bool
+
+    cause error // sentinel passed to Wrap, if any
+    err   error // underlying error, if any
+}
+
+// New returns an error with the given code and message.
+func New(code Code, message string) *Error {
+    return &Error{Code: code, Message: message, Retryable: code.info().retryable}
+}
+
+// Newf is like New but formats the message; an error operand of %w is kept
+// as the underlying error.
+func Newf(code Code, format string, args ...any) *Error {
+    err := fmt.Errorf(format, args...)
+    return &Error{Code: code, Message: err.Error(), Retryable: code.info().retryable, err: errors.Unwrap(err)}
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.err }
+
+func (e *Error) Is(target error) bool {
+    return target != nil && (target == e.cause || target == e.Code.info().class)
+}
+
+// WithDetail adds a detail for clients and returns e.
+func (e *Error) WithDetail(key string, value any) *Error {
+    if e.Details == nil { e.Details = map[string]any{} }
+    e.Details[key] = value
+    return e
+}
+
+// PublicMessage is the message to show to remote clients. Codes for server
+// faults get a generic message, since theirs may name paths or internals.
+func (e *Error) PublicMessage() string {
+    if m := e.Code.info().public; m != "" { return m }
+    return e.Message
+}
+
+func (e *Error) HTTPStatus() int { return e.Code.info().http }
+
+func (e *Error) GRPCCode() GRPCCode { return e.Code.info().grpc }
+
+func (e *Error) ExitCode() int { return e.Code.info().exit }
+
+// Wrap labels an error with a sentinel cause for errors.Is checks and
+// classifies it: an already classified error keeps its code, context errors
+// become Canceled or DeadlineExceeded, and anything else takes the code of
+// the cause.
 func Wrap(cause, err error) error {
     if err == nil { return nil }
-    return struct{ error }{error: join(cause, err)}
+    var typed *Error
+    if errors.As(err, &typed) {
+        c := *typed
+        c.cause, c.err = cause, err
+        return &c
+    }
+    code := codeFor(cause, err)
+    return &Error{Code: code, Message: err.Error(), Retryable: code.info().retryable, cause: cause, err: err}
+}
+
+// From classifies any error, returning nil for nil. Errors that carry no
+// classification are Internal.
+func From(err error) *Error {
+    if err == nil { return nil }
+    var typed *Error
+    if errors.As(err, &typed) { return typed }
+    code := codeFor(nil, err)
+    return &Error{Code: code, Message: err.Error(), Retryable: code.info().retryable, err: err}
 }
 
-func join(a, b error) error { return errors.Join(a, b) }
+// ExitCode is the process exit status for err: 0 for nil, otherwise the
+// exit code of its classification.
+func ExitCode(err error) int {
+    if err == nil { return 0 }
+    return From(err).ExitCode()
+}
 
+func codeFor(cause, err error) Code {
+    switch {
+    case errors.Is(err, context.Canceled):
+        return Canceled
+    case errors.Is(err, context.DeadlineExceeded):
+        return DeadlineExceeded
+    }
+    for _, s := range []struct {
+        sentinel error
+        code     Code
+    }{
+        {InvalidArgs, InvalidArgument},
+        {AudioError, InvalidAudio},
+        {ModelError, ModelUnavailable},
+        {TranscriptionError, TranscriptionFailed},
+        {FsError, StorageError},
+    } {
+        if cause == s.sentinel || errors.Is(err, s.sentinel) { return s.code }
+    }
+    return Internal
+}
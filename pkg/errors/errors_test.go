@@ -0,0 +1,80 @@
+package errors
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+)
+
+func TestWrap_IsCause(t *testing.T) {
+    base := errors.New("boom")
+    err := Wrap(ModelError, base)
+    if !errors.Is(err, ModelError) || !errors.Is(err, base) { t.Fatalf("errors.Is failed for %v", err) }
+    if errors.Is(err, AudioError) { t.Fatalf("unexpected cause match") }
+    if Wrap(ModelError, nil) != nil { t.Fatalf("Wrap(nil) should be nil") }
+}
+
+func TestWrap_Classifies(t *testing.T) {
+    cases := []struct {
+        cause error
+        err   error
+        want  Code
+    }{
+        {InvalidArgs, errors.New("x"), InvalidArgument},
+        {AudioError, errors.New("x"), InvalidAudio},
+        {ModelError, errors.New("x"), ModelUnavailable},
+        {TranscriptionError, errors.New("x"), TranscriptionFailed},
+        {FsError, errors.New("x"), StorageError},
+        {ModelError, fmt.Errorf("download: %w", context.Canceled), Canceled},
+        {TranscriptionError, context.DeadlineExceeded, DeadlineExceeded},
+        {AudioError, New(UnsupportedFormat, "unsupported audio format: .ogg"), UnsupportedFormat},
+    }
+    for _, c := range cases {
+        e := From(Wrap(c.cause, c.err))
+        if e.Code != c.want { t.Errorf("Wrap(%v, %v) code = %s, want %s", c.cause, c.err, e.Code, c.want) }
+        if !errors.Is(e, c.cause) { t.Errorf("Wrap(%v, %v) does not match its cause", c.cause, c.err) }
+        if !errors.Is(e, c.err) { t.Errorf("Wrap(%v, %v) does not match the wrapped error", c.cause, c.err) }
+    }
+}
+
+func TestFrom_Unclassified(t *testing.T) {
+    if From(nil) != nil { t.Fatalf("From(nil) should be nil") }
+    e := From(errors.New("open /var/secret: permission denied"))
+    if e.Code != Internal || e.HTTPStatus() != 500 || e.ExitCode() != 1 {
+        t.Fatalf("unexpected classification %+v", e)
+    }
+    if e.PublicMessage() == e.Message { t.Fatalf("internal message exposed: %q", e.PublicMessage()) }
+}
+
+func TestError_Mappings(t *testing.T) {
+    cases := []struct {
+        code      Code
+        http      int
+        grpc      GRPCCode
+        exit      int
+        retryable bool
+    }{
+        {InvalidArgument, 400, GRPCInvalidArgument, 2, false},
+        {UnsupportedFormat, 415, GRPCInvalidArgument, 3, false},
+        {ModelNotFound, 400, GRPCNotFound, 4, false},
+        {ModelUnavailable, 503, GRPCUnavailable, 5, true},
+        {RateLimited, 429, GRPCResourceExhausted, 9, true},
+        {Canceled, 499, GRPCCanceled, 130, false},
+        {Code("bogus"), 500, GRPCInternal, 1, false},
+    }
+    for _, c := range cases {
+        e := New(c.code, "m")
+        if e.HTTPStatus() != c.http || e.GRPCCode() != c.grpc || e.ExitCode() != c.exit || e.Retryable != c.retryable {
+            t.Errorf("%s: got http=%d grpc=%d exit=%d retryable=%v", c.code, e.HTTPStatus(), e.GRPCCode(), e.ExitCode(), e.Retryable)
+        }
+    }
+    if ExitCode(nil) != 0 { t.Fatalf("ExitCode(nil) should be 0") }
+}
+
+func TestNewf_KeepsCause(t *testing.T) {
+    base := errors.New("boom")
+    e := Newf(ModelNotFound, "unknown model %q: %w", "x", base).WithDetail("model", "x")
+    if !errors.Is(e, base) || !errors.Is(e, InvalidArgs) { t.Fatalf("errors.Is failed for %v", e) }
+    if e.Message != `unknown model "x": boom` || e.Details["model"] != "x" { t.Fatalf("unexpected %+v", e) }
+}
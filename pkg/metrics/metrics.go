@@ -0,0 +1,280 @@
+// Package metrics keeps counters, gauges and histograms and writes them in
+// the Prometheus text exposition format, without a client library.
+package metrics
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+// Registry holds metrics by name and writes them for scraping.
+type Registry struct {
+    mu      sync.Mutex
+    metrics map[string]metric
+}
+
+type metric interface {
+    write(w *bufio.Writer, name string)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry { return &Registry{metrics: map[string]metric{}} }
+
+// register adds m under name, panicking on duplicates like any other
+// programming error at startup.
+func (r *Registry) register(name, help, typ string, m metric) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if _, dup := r.metrics[name]; dup { panic("metrics: " + name + " registered twice") }
+    r.metrics[name] = described{help: help, typ: typ, metric: m}
+}
+
+type described struct {
+    help, typ string
+    metric
+}
+
+func (d described) write(w *bufio.Writer, name string) {
+    fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(d.help), name, d.typ)
+    d.metric.write(w, name)
+}
+
+// WriteText writes every metric in the text exposition format, sorted by
+// name.
+func (r *Registry) WriteText(out io.Writer) error {
+    r.mu.Lock()
+    names := make([]string, 0, len(r.metrics))
+    for name := range r.metrics { names = append(names, name) }
+    sort.Strings(names)
+    ms := make([]metric, len(names))
+    for i, name := range names { ms[i] = r.metrics[name] }
+    r.mu.Unlock()
+    w := bufio.NewWriter(out)
+    for i, name := range names { ms[i].write(w, name) }
+    return w.Flush()
+}
+
+// ServeHTTP serves the metrics for a Prometheus scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    _ = r.WriteText(w)
+}
+
+// vec keeps one child per combination of label values.
+type vec[C any] struct {
+    labels   []string
+    newChild func() *C
+    mu       sync.Mutex
+    children map[string]*C
+    values   map[string][]string
+}
+
+func newVec[C any](labels []string, newChild func() *C) *vec[C] {
+    return &vec[C]{labels: labels, newChild: newChild, children: map[string]*C{}, values: map[string][]string{}}
+}
+
+func (v *vec[C]) with(values []string) *C {
+    if len(values) != len(v.labels) { panic(fmt.Sprintf("metrics: got %d label values for %v", len(values), v.labels)) }
+    key := strings.Join(values, "\xff")
+    v.mu.Lock()
+    defer v.mu.Unlock()
+    c, ok := v.children[key]
+    if !ok {
+        c = v.newChild()
+        v.children[key] = c
+        v.values[key] = append([]string(nil), values...)
+    }
+    return c
+}
+
+// each calls f for every child, ordered by label values.
+func (v *vec[C]) each(f func(labels string, c *C)) {
+    v.mu.Lock()
+    keys := make([]string, 0, len(v.children))
+    for k := range v.children { keys = append(keys, k) }
+    sort.Strings(keys)
+    children := make([]*C, len(keys))
+    labels := make([]string, len(keys))
+    for i, k := range keys {
+        children[i] = v.children[k]
+        labels[i] = formatLabels(v.labels, v.values[k])
+    }
+    v.mu.Unlock()
+    for i := range keys { f(labels[i], children[i]) }
+}
+
+// value is a float64 updated atomically.
+type value struct{ bits atomic.Uint64 }
+
+func (v *value) add(d float64) {
+    for {
+        old := v.bits.Load()
+        if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+d)) { return }
+    }
+}
+
+func (v *value) set(f float64) { v.bits.Store(math.Float64bits(f)) }
+
+func (v *value) get() float64 { return math.Float64frombits(v.bits.Load()) }
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct{ v *vec[value] }
+
+// Counter registers a counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+    c := &Counter{newVec(labels, func() *value { return &value{} })}
+    r.register(name, help, "counter", c)
+    return c
+}
+
+// Add adds d, which must not be negative, for the label values.
+func (c *Counter) Add(d float64, labelValues ...string) {
+    if d < 0 { panic("metrics: counter decreased") }
+    c.v.with(labelValues).add(d)
+}
+
+// Inc adds one for the label values.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+func (c *Counter) write(w *bufio.Writer, name string) {
+    c.v.each(func(labels string, v *value) { writeSample(w, name, labels, v.get()) })
+}
+
+// Gauge is a value that goes up and down per label combination.
+type Gauge struct{ v *vec[value] }
+
+// Gauge registers a gauge with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+    g := &Gauge{newVec(labels, func() *value { return &value{} })}
+    r.register(name, help, "gauge", g)
+    return g
+}
+
+// Set sets the value for the label values.
+func (g *Gauge) Set(f float64, labelValues ...string) { g.v.with(labelValues).set(f) }
+
+// Add adds d, which may be negative, for the label values.
+func (g *Gauge) Add(d float64, labelValues ...string) { g.v.with(labelValues).add(d) }
+
+func (g *Gauge) write(w *bufio.Writer, name string) {
+    g.v.each(func(labels string, v *value) { writeSample(w, name, labels, v.get()) })
+}
+
+type gaugeFunc func() float64
+
+func (f gaugeFunc) write(w *bufio.Writer, name string) { writeSample(w, name, "", f()) }
+
+// GaugeFunc registers a gauge whose value is read from f at each scrape.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+    r.register(name, help, "gauge", gaugeFunc(f))
+}
+
+// Histogram counts observations into cumulative buckets per label
+// combination.
+type Histogram struct {
+    buckets []float64 // upper bounds, ascending, without +Inf
+    v       *vec[histogramChild]
+}
+
+type histogramChild struct {
+    counts []atomic.Uint64 // per bucket, plus one for +Inf
+    sum    value
+}
+
+// DefBuckets suit latencies of a few milliseconds to ten seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets returns n bucket bounds starting at start, each
+// factor times the one before.
+func ExponentialBuckets(start, factor float64, n int) []float64 {
+    b := make([]float64, n)
+    for i := range b {
+        b[i] = start
+        start *= factor
+    }
+    return b
+}
+
+// Histogram registers a histogram with the given bucket upper bounds and
+// label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+    b := append([]float64(nil), buckets...)
+    sort.Float64s(b)
+    h := &Histogram{buckets: b}
+    h.v = newVec(labels, func() *histogramChild { return &histogramChild{counts: make([]atomic.Uint64, len(b)+1)} })
+    r.register(name, help, "histogram", h)
+    return h
+}
+
+// Observe records f for the label values.
+func (h *Histogram) Observe(f float64, labelValues ...string) {
+    c := h.v.with(labelValues)
+    c.counts[sort.SearchFloat64s(h.buckets, f)].Add(1)
+    c.sum.add(f)
+}
+
+func (h *Histogram) write(w *bufio.Writer, name string) {
+    h.v.each(func(labels string, c *histogramChild) {
+        var cum uint64
+        for i, le := range h.buckets {
+            cum += c.counts[i].Load()
+            writeSample(w, name+"_bucket", joinLabels(labels, `le="`+formatFloat(le)+`"`), float64(cum))
+        }
+        cum += c.counts[len(h.buckets)].Load()
+        writeSample(w, name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(cum))
+        writeSample(w, name+"_sum", labels, c.sum.get())
+        writeSample(w, name+"_count", labels, float64(cum))
+    })
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+    w.WriteString(name)
+    if labels != "" {
+        w.WriteByte('{')
+        w.WriteString(labels)
+        w.WriteByte('}')
+    }
+    w.WriteByte(' ')
+    w.WriteString(formatFloat(v))
+    w.WriteByte('\n')
+}
+
+func formatLabels(names, values []string) string {
+    parts := make([]string, len(names))
+    for i, n := range names { parts[i] = n + `="` + escapeLabel(values[i]) + `"` }
+    return strings.Join(parts, ",")
+}
+
+func joinLabels(a, b string) string {
+    if a == "" { return b }
+    return a + "," + b
+}
+
+func formatFloat(f float64) string {
+    switch {
+    case math.IsInf(f, 1):
+        return "+Inf"
+    case math.IsInf(f, -1):
+        return "-Inf"
+    case math.IsNaN(f):
+        return "NaN"
+    }
+    return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+    labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+    helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+    "io"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+    r := NewRegistry()
+    c := r.Counter("requests_total", "Requests served.", "route", "status")
+    c.Inc("/api", "200")
+    c.Add(2, "/api", "200")
+    c.Inc("/a\"b", "500")
+    g := r.Gauge("in_flight", "Requests in flight.")
+    g.Add(3)
+    g.Add(-1)
+    r.GaugeFunc("queue", "Jobs waiting.\nSecond line.", func() float64 { return 4 })
+    h := r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+    for _, v := range []float64{0.05, 0.1, 0.5, 3} { h.Observe(v, "/api") }
+
+    srv := httptest.NewServer(r)
+    defer srv.Close()
+    resp, err := srv.Client().Get(srv.URL)
+    if err != nil { t.Fatal(err) }
+    defer resp.Body.Close()
+    body, _ := io.ReadAll(resp.Body)
+    if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") { t.Errorf("Content-Type = %q", ct) }
+
+    want := `# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/api",le="0.1"} 2
+latency_seconds_bucket{route="/api",le="1"} 3
+latency_seconds_bucket{route="/api",le="+Inf"} 4
+latency_seconds_sum{route="/api"} 3.65
+latency_seconds_count{route="/api"} 4
+# HELP queue Jobs waiting.\nSecond line.
+# TYPE queue gauge
+queue 4
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/a\"b",status="500"} 1
+requests_total{route="/api",status="200"} 3
+`
+    if string(body) != want { t.Fatalf("got:\n%s\nwant:\n%s", body, want) }
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+    r := NewRegistry()
+    c := r.Counter("x_total", "X.", "a")
+    for name, f := range map[string]func(){
+        "duplicate":         func() { r.Counter("x_total", "X.") },
+        "wrong label count": func() { c.Inc("1", "2") },
+        "negative counter":  func() { c.Add(-1, "1") },
+    } {
+        func() {
+            defer func() {
+                if recover() == nil { t.Errorf("%s did not panic", name) }
+            }()
+            f()
+        }()
+    }
+}
@@ -0,0 +1,241 @@
+package trace
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// OTLP/JSON encoding of spans: IDs are hex and 64-bit integers decimal
+// strings, as the OTLP specification prescribes for JSON.
+type (
+    otlpRequest struct {
+        ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+    }
+    otlpResourceSpans struct {
+        Resource   otlpResource     `json:"resource"`
+        ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+    }
+    otlpResource struct {
+        Attributes []otlpAttr `json:"attributes"`
+    }
+    otlpScopeSpans struct {
+        Scope struct {
+            Name string `json:"name"`
+        } `json:"scope"`
+        Spans []otlpSpan `json:"spans"`
+    }
+    otlpSpan struct {
+        TraceID      string     `json:"traceId"`
+        SpanID       string     `json:"spanId"`
+        ParentSpanID string     `json:"parentSpanId,omitempty"`
+        Name         string     `json:"name"`
+        Kind         Kind       `json:"kind"`
+        Start        string     `json:"startTimeUnixNano"`
+        End          string     `json:"endTimeUnixNano"`
+        Attributes   []otlpAttr `json:"attributes,omitempty"`
+        Status       otlpStatus `json:"status"`
+    }
+    otlpAttr struct {
+        Key   string    `json:"key"`
+        Value otlpValue `json:"value"`
+    }
+    otlpValue struct {
+        String *string  `json:"stringValue,omitempty"`
+        Int    *string  `json:"intValue,omitempty"`
+        Double *float64 `json:"doubleValue,omitempty"`
+        Bool   *bool    `json:"boolValue,omitempty"`
+    }
+    otlpStatus struct {
+        Code    int    `json:"code,omitempty"` // 2 for error, unset otherwise
+        Message string `json:"message,omitempty"`
+    }
+)
+
+func encodeSpan(d SpanData) otlpSpan {
+    s := otlpSpan{
+        TraceID: d.TraceID.String(),
+        SpanID:  d.SpanID.String(),
+        Name:    d.Name,
+        Kind:    d.Kind,
+        Start:   strconv.FormatInt(d.Start.UnixNano(), 10),
+        End:     strconv.FormatInt(d.End.UnixNano(), 10),
+    }
+    if d.Parent != (SpanID{}) { s.ParentSpanID = d.Parent.String() }
+    for _, a := range d.Attrs { s.Attributes = append(s.Attributes, encodeAttr(a)) }
+    if d.Err != "" { s.Status = otlpStatus{Code: 2, Message: d.Err} }
+    return s
+}
+
+func encodeAttr(a Attr) otlpAttr {
+    var v otlpValue
+    switch x := a.Value.(type) {
+    case string:
+        v.String = &x
+    case int64:
+        s := strconv.FormatInt(x, 10)
+        v.Int = &s
+    case float64:
+        if math.IsInf(x, 0) || math.IsNaN(x) {
+            s := strconv.FormatFloat(x, 'g', -1, 64)
+            v.String = &s
+        } else {
+            v.Double = &x
+        }
+    case bool:
+        v.Bool = &x
+    default:
+        s := fmt.Sprint(x)
+        v.String = &s
+    }
+    return otlpAttr{Key: a.Key, Value: v}
+}
+
+// WriterExporter writes each span as a line of OTLP/JSON to W, for
+// debugging and tests.
+type WriterExporter struct {
+    W  io.Writer
+    mu sync.Mutex
+}
+
+func (e *WriterExporter) Export(d SpanData) {
+    b, err := json.Marshal(encodeSpan(d))
+    if err != nil { return }
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    _, _ = e.W.Write(append(b, '\n'))
+}
+
+func (e *WriterExporter) Shutdown(context.Context) error { return nil }
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector over
+// OTLP/HTTP with JSON encoding. Spans are dropped, and reported to OnError,
+// when the collector cannot keep up.
+type OTLPExporter struct {
+    // Endpoint is the collector's base URL, such as
+    // http://localhost:4318; /v1/traces is appended unless present.
+    Endpoint string
+    Service  string            // service.name resource attribute
+    Headers  map[string]string // sent with each export, e.g. for auth
+    Client   *http.Client      // nil for a client with a 10s timeout
+    OnError  func(error)       // nil to ignore export failures
+
+    start    sync.Once
+    stopOnce sync.Once
+    queue    chan SpanData
+    stop     chan struct{}
+    stopped  chan struct{}
+    dropped  atomic.Int64
+}
+
+const (
+    otlpQueueSize = 2048
+    otlpBatchSize = 256
+    otlpInterval  = 5 * time.Second
+)
+
+func (e *OTLPExporter) init() {
+    e.queue = make(chan SpanData, otlpQueueSize)
+    e.stop = make(chan struct{})
+    e.stopped = make(chan struct{})
+    go e.run()
+}
+
+func (e *OTLPExporter) Export(d SpanData) {
+    e.start.Do(e.init)
+    select {
+    case e.queue <- d:
+    default:
+        e.dropped.Add(1)
+    }
+}
+
+// Shutdown sends the queued spans, giving up when ctx ends.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+    e.start.Do(func() {}) // an exporter never used has nothing to send
+    if e.stop == nil { return nil }
+    e.stopOnce.Do(func() { close(e.stop) })
+    select {
+    case <-e.stopped:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func (e *OTLPExporter) run() {
+    defer close(e.stopped)
+    tick := time.NewTicker(otlpInterval)
+    defer tick.Stop()
+    batch := make([]SpanData, 0, otlpBatchSize)
+    flush := func() {
+        if len(batch) > 0 {
+            e.report(e.send(batch))
+            batch = batch[:0]
+        }
+        if n := e.dropped.Swap(0); n > 0 { e.report(fmt.Errorf("dropped %d spans: export queue full", n)) }
+    }
+    for {
+        select {
+        case d := <-e.queue:
+            batch = append(batch, d)
+            if len(batch) == otlpBatchSize { flush() }
+        case <-tick.C:
+            flush()
+        case <-e.stop:
+            for {
+                select {
+                case d := <-e.queue:
+                    batch = append(batch, d)
+                    if len(batch) == otlpBatchSize { flush() }
+                default:
+                    flush()
+                    return
+                }
+            }
+        }
+    }
+}
+
+func (e *OTLPExporter) report(err error) {
+    if err != nil && e.OnError != nil { e.OnError(err) }
+}
+
+// send posts one batch.
+func (e *OTLPExporter) send(batch []SpanData) error {
+    var ss otlpScopeSpans
+    ss.Scope.Name = "gosper"
+    for _, d := range batch { ss.Spans = append(ss.Spans, encodeSpan(d)) }
+    service := e.Service
+    if service == "" { service = "gosper" }
+    req := otlpRequest{ResourceSpans: []otlpResourceSpans{{
+        Resource:   otlpResource{Attributes: []otlpAttr{encodeAttr(String("service.name", service))}},
+        ScopeSpans: []otlpScopeSpans{ss},
+    }}}
+    body, err := json.Marshal(req)
+    if err != nil { return fmt.Errorf("encode spans: %w", err) }
+
+    url := strings.TrimSuffix(e.Endpoint, "/")
+    if !strings.HasSuffix(url, "/v1/traces") { url += "/v1/traces" }
+    r, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+    if err != nil { return fmt.Errorf("export spans: %w", err) }
+    r.Header.Set("Content-Type", "application/json")
+    for k, v := range e.Headers { r.Header.Set(k, v) }
+    client := e.Client
+    if client == nil { client = &http.Client{Timeout: 10 * time.Second} }
+    resp, err := client.Do(r)
+    if err != nil { return fmt.Errorf("export spans: %w", err) }
+    defer resp.Body.Close()
+    _, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+    if resp.StatusCode/100 != 2 { return fmt.Errorf("export %d spans: collector answered %s", len(batch), resp.Status) }
+    return nil
+}
@@ -0,0 +1,238 @@
+// Package trace records spans in the OpenTelemetry data model and exports
+// them as OTLP/HTTP JSON or JSON lines, without the OpenTelemetry SDK.
+// Traces are continued from and propagated with W3C traceparent headers.
+package trace
+
+import (
+    "context"
+    "encoding/hex"
+    "math/rand/v2"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+    TraceID TraceID
+    SpanID  SpanID
+    Sampled bool
+}
+
+// Valid reports whether both IDs are set.
+func (sc SpanContext) Valid() bool { return sc.TraceID != TraceID{} && sc.SpanID != SpanID{} }
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+    flags := "00"
+    if sc.Sampled { flags = "01" }
+    return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value. Versions after
+// 00 are accepted as long as they start with the version 00 fields.
+func ParseTraceparent(v string) (SpanContext, bool) {
+    var sc SpanContext
+    if len(v) < 55 || v[2] != '-' || v[35] != '-' || v[52] != '-' { return sc, false }
+    var version, flags [1]byte
+    if !decodeHex(version[:], v[:2]) || version[0] == 0xff { return sc, false }
+    if version[0] == 0 && len(v) != 55 { return sc, false }
+    if len(v) > 55 && v[55] != '-' { return sc, false }
+    if !decodeHex(sc.TraceID[:], v[3:35]) || !decodeHex(sc.SpanID[:], v[36:52]) || !decodeHex(flags[:], v[53:55]) { return sc, false }
+    sc.Sampled = flags[0]&1 == 1
+    return sc, sc.Valid()
+}
+
+// decodeHex decodes lowercase hex only, as traceparent requires.
+func decodeHex(dst []byte, s string) bool {
+    for i := 0; i < len(s); i++ {
+        if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') { return false }
+    }
+    _, err := hex.Decode(dst, []byte(s))
+    return err == nil
+}
+
+// Attr is a span attribute. Values are strings, int64s, float64s or bools.
+type Attr struct {
+    Key   string
+    Value any
+}
+
+// String, Int, Float and Bool make attributes of each value type.
+func String(k, v string) Attr { return Attr{k, v} }
+func Int(k string, v int64) Attr { return Attr{k, v} }
+func Float(k string, v float64) Attr { return Attr{k, v} }
+func Bool(k string, v bool) Attr { return Attr{k, v} }
+
+// Seconds is a duration attribute in seconds.
+func Seconds(k string, d time.Duration) Attr { return Attr{k, d.Seconds()} }
+
+// Kind is the OpenTelemetry span kind.
+type Kind int
+
+const (
+    KindInternal Kind = 1
+    KindServer   Kind = 2
+)
+
+// SpanData is a finished span as handed to an exporter.
+type SpanData struct {
+    SpanContext
+    Parent     SpanID // zero for a root span
+    Name       string
+    Kind       Kind
+    Start, End time.Time
+    Attrs      []Attr
+    Err        string // set when the span failed
+}
+
+// Exporter receives finished, sampled spans.
+type Exporter interface {
+    Export(SpanData)
+    // Shutdown exports what is still queued.
+    Shutdown(ctx context.Context) error
+}
+
+// Tracer starts the server spans of requests entering the service. Spans
+// below them are started with the package-level Start.
+type Tracer struct {
+    Exporter Exporter
+    // SampleRatio is the share of new traces recorded. Traces continued
+    // from a traceparent follow the caller's sampling decision.
+    SampleRatio float64
+}
+
+// Start starts a server span, continuing the remote trace in ctx if
+// ContextWithRemote put one there.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+    s := &Span{tracer: t, kind: KindServer, name: name, start: time.Now(), attrs: attrs}
+    if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok && remote.Valid() {
+        s.sc.TraceID, s.sc.Sampled, s.parent = remote.TraceID, remote.Sampled, remote.SpanID
+    } else {
+        s.sc.TraceID = newTraceID()
+        s.sc.Sampled = t.SampleRatio >= 1 || rand.Float64() < t.SampleRatio
+    }
+    s.sc.SpanID = newSpanID()
+    return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Shutdown flushes the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error { return t.Exporter.Shutdown(ctx) }
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithRemote returns ctx carrying a parent span from another
+// process, from which Tracer.Start continues the trace.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+    return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Start starts a child of the span in ctx. Without one, tracing is off for
+// this call: it returns ctx and a nil span, whose methods do nothing.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+    parent, _ := ctx.Value(spanKey{}).(*Span)
+    if parent == nil { return ctx, nil }
+    s := &Span{tracer: parent.tracer, kind: KindInternal, name: name, start: time.Now(), attrs: attrs, parent: parent.sc.SpanID}
+    s.sc = SpanContext{TraceID: parent.sc.TraceID, SpanID: newSpanID(), Sampled: parent.sc.Sampled}
+    return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+    s, _ := ctx.Value(spanKey{}).(*Span)
+    return s
+}
+
+// Inject sets the traceparent header for an outgoing request made within
+// the span in ctx.
+func Inject(ctx context.Context, h http.Header) {
+    if s := FromContext(ctx); s != nil { h.Set("Traceparent", s.sc.Traceparent()) }
+}
+
+// Span is an operation being timed. A nil *Span is valid and records
+// nothing.
+type Span struct {
+    tracer *Tracer
+    sc     SpanContext
+    parent SpanID
+    kind   Kind
+    name   string
+    start  time.Time
+
+    mu    sync.Mutex
+    attrs []Attr
+    err   string
+    ended bool
+}
+
+// SpanContext returns the span's IDs; zero for a nil span.
+func (s *Span) SpanContext() SpanContext {
+    if s == nil { return SpanContext{} }
+    return s.sc
+}
+
+// SetAttrs adds attributes to the span.
+func (s *Span) SetAttrs(attrs ...Attr) {
+    if s == nil { return }
+    s.mu.Lock()
+    s.attrs = append(s.attrs, attrs...)
+    s.mu.Unlock()
+}
+
+// SetName renames the span, for names known only once it has run.
+func (s *Span) SetName(name string) {
+    if s == nil { return }
+    s.mu.Lock()
+    s.name = name
+    s.mu.Unlock()
+}
+
+// Fail marks the span as failed with err; a nil err does nothing.
+func (s *Span) Fail(err error) {
+    if s == nil || err == nil { return }
+    s.mu.Lock()
+    s.err = err.Error()
+    s.mu.Unlock()
+}
+
+// End finishes the span and exports it if its trace is sampled. Only the
+// first call counts.
+func (s *Span) End() {
+    if s == nil { return }
+    end := time.Now()
+    s.mu.Lock()
+    if s.ended {
+        s.mu.Unlock()
+        return
+    }
+    s.ended = true
+    d := SpanData{SpanContext: s.sc, Parent: s.parent, Name: s.name, Kind: s.kind, Start: s.start, End: end, Attrs: s.attrs, Err: s.err}
+    s.mu.Unlock()
+    if s.sc.Sampled && s.tracer.Exporter != nil { s.tracer.Exporter.Export(d) }
+}
+
+func newTraceID() (id TraceID) {
+    for id == (TraceID{}) {
+        hi, lo := rand.Uint64(), rand.Uint64()
+        for i := 0; i < 8; i++ { id[i], id[8+i] = byte(hi>>(56-8*i)), byte(lo>>(56-8*i)) }
+    }
+    return id
+}
+
+func newSpanID() (id SpanID) {
+    for id == (SpanID{}) {
+        v := rand.Uint64()
+        for i := range id { id[i] = byte(v >> (56 - 8*i)) }
+    }
+    return id
+}
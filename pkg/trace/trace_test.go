@@ -0,0 +1,117 @@
+package trace
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+    const id = "4bf92f3577b34da6a3ce929d0e0e4736"
+    for v, want := range map[string]bool{
+        "00-" + id + "-00f067aa0ba902b7-01":       true,
+        "00-" + id + "-00f067aa0ba902b7-00":       true,
+        "01-" + id + "-00f067aa0ba902b7-01-extra": true,  // a later version may add fields
+        "00-" + id + "-00f067aa0ba902b7-01-extra": false, // version 00 may not
+        "ff-" + id + "-00f067aa0ba902b7-01":       false,
+        "00-" + strings.ToUpper(id) + "-00f067aa0ba902b7-01": false,
+        "00-00000000000000000000000000000000-00f067aa0ba902b7-01": false,
+        "00-" + id + "-0000000000000000-01":       false,
+        "00-" + id + "-00f067aa0ba902b7":          false,
+        "":                                        false,
+    } {
+        sc, ok := ParseTraceparent(v)
+        if ok != want { t.Errorf("ParseTraceparent(%q) ok = %v, want %v", v, ok, want) }
+        if ok && v[:2] == "00" && sc.Traceparent() != v { t.Errorf("round trip of %q gave %q", v, sc.Traceparent()) }
+    }
+}
+
+func TestSpans(t *testing.T) {
+    var out bytes.Buffer
+    tr := &Tracer{Exporter: &WriterExporter{W: &out}, SampleRatio: 1}
+    remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+    ctx, root := tr.Start(ContextWithRemote(context.Background(), remote), "POST /api/transcribe")
+    _, child := Start(ctx, "audio.decode", Int("audio.sample_rate", 44100))
+    child.Fail(errors.New("bad header"))
+    child.End()
+    root.SetAttrs(Int("http.response.status_code", 422))
+    root.End()
+    root.End() // ignored
+
+    h := http.Header{}
+    Inject(ctx, h)
+    if got := h.Get("Traceparent"); got != root.SpanContext().Traceparent() { t.Errorf("injected %q", got) }
+
+    lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+    if len(lines) != 2 { t.Fatalf("exported %d spans:\n%s", len(lines), out.String()) }
+    var c, r otlpSpan
+    _ = json.Unmarshal([]byte(lines[0]), &c)
+    _ = json.Unmarshal([]byte(lines[1]), &r)
+    if r.TraceID != remote.TraceID.String() || r.ParentSpanID != remote.SpanID.String() || r.Kind != KindServer {
+        t.Errorf("root span %+v does not continue %v", r, remote)
+    }
+    if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || c.Kind != KindInternal || c.Status.Code != 2 || c.Status.Message != "bad header" {
+        t.Errorf("child span %+v", c)
+    }
+    if len(c.Attributes) != 1 || c.Attributes[0].Value.Int == nil || *c.Attributes[0].Value.Int != "44100" {
+        t.Errorf("child attributes %+v", c.Attributes)
+    }
+}
+
+func TestUnsampledAndUntraced(t *testing.T) {
+    var out bytes.Buffer
+    tr := &Tracer{Exporter: &WriterExporter{W: &out}, SampleRatio: 0}
+    ctx, root := tr.Start(context.Background(), "GET /api/models")
+    _, child := Start(ctx, "models.list")
+    child.End()
+    root.End()
+    if out.Len() != 0 || root.SpanContext().Sampled { t.Errorf("unsampled trace exported: %s", out.String()) }
+
+    ctx, s := Start(context.Background(), "orphan")
+    if s != nil || FromContext(ctx) != nil { t.Fatal("span started without a tracer") }
+    s.SetAttrs(String("k", "v")) // nil spans are safe to use
+    s.Fail(errors.New("x"))
+    s.End()
+}
+
+func TestOTLPExporter(t *testing.T) {
+    got := make(chan otlpRequest, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer t" {
+            http.Error(w, "bad request", http.StatusBadRequest)
+            return
+        }
+        b, _ := io.ReadAll(r.Body)
+        var req otlpRequest
+        _ = json.Unmarshal(b, &req)
+        got <- req
+    }))
+    defer srv.Close()
+
+    var errs []error
+    exp := &OTLPExporter{Endpoint: srv.URL, Service: "gosper-test", Headers: map[string]string{"Authorization": "Bearer t"}, OnError: func(err error) { errs = append(errs, err) }}
+    tr := &Tracer{Exporter: exp, SampleRatio: 1}
+    _, s := tr.Start(context.Background(), "GET /livez", Float("audio.duration", 1.5))
+    s.End()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := tr.Shutdown(ctx); err != nil { t.Fatal(err) }
+    if len(errs) > 0 { t.Fatalf("export errors: %v", errs) }
+    req := <-got
+    rs := req.ResourceSpans[0]
+    if v := rs.Resource.Attributes[0].Value.String; v == nil || *v != "gosper-test" { t.Errorf("resource %+v", rs.Resource) }
+    spans := rs.ScopeSpans[0].Spans
+    if len(spans) != 1 || spans[0].Name != "GET /livez" || spans[0].TraceID != s.SpanContext().TraceID.String() {
+        t.Errorf("spans %+v", spans)
+    }
+    if err := (&OTLPExporter{Endpoint: srv.URL}).Shutdown(ctx); err != nil { t.Errorf("shutdown of unused exporter: %v", err) }
+}
@@ -0,0 +1,36 @@
+// Package units parses human-readable quantities used in configuration.
+package units
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+var byteUnits = []struct {
+    suffix string
+    mult   int64
+}{
+    {"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40},
+    {"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9}, {"tb", 1e12},
+    {"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30}, {"t", 1 << 40},
+    {"b", 1},
+}
+
+// ParseBytes parses a size such as "500M", "2GiB", "1.5g" or "1048576".
+// Single-letter and IEC suffixes are powers of 1024, SI suffixes ("GB") are
+// powers of 1000; case is ignored. An empty string is 0.
+func ParseBytes(s string) (int64, error) {
+    v := strings.ToLower(strings.TrimSpace(s))
+    if v == "" { return 0, nil }
+    mult := int64(1)
+    for _, u := range byteUnits {
+        if strings.HasSuffix(v, u.suffix) {
+            v, mult = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.mult
+            break
+        }
+    }
+    n, err := strconv.ParseFloat(v, 64)
+    if err != nil || n < 0 { return 0, fmt.Errorf("invalid size %q", s) }
+    return int64(n * float64(mult)), nil
+}
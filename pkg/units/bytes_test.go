@@ -0,0 +1,28 @@
+package units
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+    tests := []struct {
+        in   string
+        want int64
+        ok   bool
+    }{
+        {"", 0, true},
+        {"1048576", 1 << 20, true},
+        {"500M", 500 << 20, true},
+        {"2GiB", 2 << 30, true},
+        {"1.5g", 3 << 29, true},
+        {"2 GB", 2e9, true},
+        {"10kb", 10000, true},
+        {"7b", 7, true},
+        {"-1G", 0, false},
+        {"lots", 0, false},
+    }
+    for _, tt := range tests {
+        got, err := ParseBytes(tt.in)
+        if (err == nil) != tt.ok || got != tt.want {
+            t.Errorf("ParseBytes(%q) = %d, %v", tt.in, got, err)
+        }
+    }
+}